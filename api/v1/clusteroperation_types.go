@@ -0,0 +1,82 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterOperationSpec defines the operation which the plugin server has performed.
+type ClusterOperationSpec struct {
+	// ClusterVersion is the name of the ClusterVersion which has requested this operation.
+	ClusterVersion string `json:"clusterVersion"`
+	ClusterID      string `json:"clusterID"`
+	OperationID    string `json:"operationID"`
+	OperationType  string `json:"operationType"`
+}
+
+// ClusterOperationPhase shows the phase of the operation.
+type ClusterOperationPhase string
+
+const (
+	// ClusterOperationRunning shows the operation is running.
+	ClusterOperationRunning ClusterOperationPhase = "Running"
+	// ClusterOperationSucceeded shows the operation has done.
+	ClusterOperationSucceeded ClusterOperationPhase = "Succeeded"
+	// ClusterOperationFailed shows the operation has failed.
+	ClusterOperationFailed ClusterOperationPhase = "Failed"
+)
+
+// ClusterOperationStatus defines the observed state of ClusterOperation
+type ClusterOperationStatus struct {
+	// +optional
+	Phase ClusterOperationPhase `json:"phase,omitempty"`
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// +optional
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="ClusterVersion",type="string",JSONPath=".spec.clusterVersion"
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterID"
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.operationType"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+
+// ClusterOperation is the Schema for the clusteroperations API
+// It's the audit record of an operation performed for a ClusterVersion.
+type ClusterOperation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterOperationSpec   `json:"spec,omitempty"`
+	Status ClusterOperationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterOperationList contains a list of ClusterOperation
+type ClusterOperationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterOperation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterOperation{}, &ClusterOperationList{})
+}
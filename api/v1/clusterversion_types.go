@@ -17,7 +17,16 @@ limitations under the License.
 package v1
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"strings"
+	"time"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -34,6 +43,212 @@ type ClusterVersionSpec struct {
 
 	// +kubebuilder:validation:Minimum=1
 	RequiredAvailableCount int `json:"requiredAvailableCount"`
+
+	// HistoryLimit is the number of completed operations kept in status.history.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	HistoryLimit *int `json:"historyLimit,omitempty"`
+
+	// Hooks are the Jobs which run around the operations of each cluster.
+	// +optional
+	Hooks *Hooks `json:"hooks,omitempty"`
+
+	// Workloads are the core workloads upgraded in every cluster after the master and the node pools.
+	// +optional
+	Workloads []Workload `json:"workloads,omitempty"`
+
+	// Drain makes the controller cordon and drain the nodes of each node pool before upgrading it.
+	// +optional
+	Drain *DrainPolicy `json:"drain,omitempty"`
+
+	// Verification makes the controller verify each upgraded cluster before servicing it in.
+	// +optional
+	Verification *VerificationPolicy `json:"verification,omitempty"`
+
+	// VersionPolicy makes the controller resolve the desired version of each cluster from the available versions.
+	// +optional
+	VersionPolicy *VersionPolicy `json:"versionPolicy,omitempty"`
+}
+
+// VersionPolicyMode is how the desired version of each cluster is resolved.
+// +kubebuilder:validation:Enum=Pinned;LatestPatch;Channel
+type VersionPolicyMode string
+
+const (
+	// VersionPolicyPinned uses spec.clusters[].version as it is.
+	VersionPolicyPinned VersionPolicyMode = "Pinned"
+	// VersionPolicyLatestPatch uses the latest available version which spec.clusters[].version is a prefix of, e.g. "1.18.12-gke.1210" for "1.18".
+	VersionPolicyLatestPatch VersionPolicyMode = "LatestPatch"
+	// VersionPolicyChannel uses the latest version of the release channel which spec.clusters[].version is a prefix of.
+	VersionPolicyChannel VersionPolicyMode = "Channel"
+)
+
+// VersionPolicy defines how the desired version of each cluster is resolved.
+type VersionPolicy struct {
+	Mode VersionPolicyMode `json:"mode"`
+	// Channel is the release channel of the provider, e.g. "REGULAR" for GKE. It's required by the Channel mode.
+	// +optional
+	Channel string `json:"channel,omitempty"`
+	// MaintenanceWindow is when the rollouts of the resolved versions may start. They may start anytime if it's nil.
+	// The rollout which has started continues after the window closes.
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+}
+
+// maintenanceWindowStartLayout is the layout of MaintenanceWindow.Start.
+const maintenanceWindowStartLayout = "15:04"
+
+// Weekday is the day of the week.
+// +kubebuilder:validation:Enum=Sunday;Monday;Tuesday;Wednesday;Thursday;Friday;Saturday
+type Weekday string
+
+// MaintenanceWindow is the time window in UTC which opens on the days.
+type MaintenanceWindow struct {
+	// Start is the time in UTC when the window opens, in "HH:MM" format.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+	// Duration is how long the window is open.
+	Duration metav1.Duration `json:"duration"`
+	// Days are the days of the week when the window opens. It opens every day if they're empty.
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+}
+
+// VerificationPolicy defines what the controller verifies in the cluster with its kubeconfig.
+// All nodes must be Ready at the desired kubelet version in addition to the following.
+type VerificationPolicy struct {
+	// Workloads are the Deployments and DaemonSets which must be fully rolled out.
+	// +optional
+	Workloads []WorkloadReference `json:"workloads,omitempty"`
+	// Namespaces are the namespaces where no pods may be in CrashLoopBackOff.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// WorkloadReference refers to a Deployment or a DaemonSet in the cluster.
+type WorkloadReference struct {
+	// +kubebuilder:validation:Enum=Deployment;DaemonSet
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// DrainPolicy defines how the controller drains the nodes of the node pool with the kubeconfig of the cluster.
+// PodDisruptionBudgets are respected because the pods are evicted.
+type DrainPolicy struct {
+	// NodePoolLabel is the node label whose value is the node pool name. default value is "cloud.google.com/gke-nodepool".
+	// +optional
+	NodePoolLabel string `json:"nodePoolLabel,omitempty"`
+	// Timeout is how long to wait for each node pool to be drained. The rollout halts when it's exceeded.
+	// default value is 1h.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Workload defines the core workload running in every cluster, e.g. Istio or an ingress controller.
+type Workload struct {
+	// Name identifies the workload for the Operator.
+	Name string `json:"name"`
+	// Version is the desired version of the workload. It's the chart version for Helm workloads.
+	Version string `json:"version"`
+	// Helm makes the controller upgrade the Helm release directly with the kubeconfig of the cluster
+	// instead of the plugin server.
+	// +optional
+	Helm *HelmWorkload `json:"helm,omitempty"`
+}
+
+// HelmWorkload defines the Helm release of the workload.
+type HelmWorkload struct {
+	// ReleaseName is the name of the Helm release.
+	ReleaseName string `json:"releaseName"`
+	// Namespace is the namespace of the Helm release in the cluster.
+	Namespace string `json:"namespace"`
+	// Chart is the chart name in the repository.
+	Chart string `json:"chart"`
+	// RepoURL is the URL of the chart repository.
+	RepoURL string `json:"repoURL"`
+	// Values are the values of the release. The release is upgraded when they differ from the deployed ones.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Values *runtime.RawExtension `json:"values,omitempty"`
+	// Timeout is how long to wait for the upgraded resources to be ready. default value is 5m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// Hooks defines the Jobs run in the cluster where this controller runs.
+// The controller waits for the Job to succeed before continuing the operations of the cluster.
+// CLUSTER_ID, TARGET_VERSION, CLUSTER_VERSION_NAME and HOOK are passed to every container as env vars.
+// Each hook is a JobTemplateSpec. The schema isn't embedded in the CRD to keep it small enough to apply,
+// so the webhook validates them instead.
+type Hooks struct {
+	// PreServiceOut runs before the cluster is serviced out.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PreServiceOut *runtime.RawExtension `json:"preServiceOut,omitempty"`
+	// PreUpgrade runs once per cluster before the first of the master, the node pools and the workloads is upgraded,
+	// not before each of them.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PreUpgrade *runtime.RawExtension `json:"preUpgrade,omitempty"`
+	// PostUpgrade runs after the master, all node pools and all workloads of the cluster are upgraded.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PostUpgrade *runtime.RawExtension `json:"postUpgrade,omitempty"`
+	// PreServiceIn runs before the cluster is serviced in.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	PreServiceIn *runtime.RawExtension `json:"preServiceIn,omitempty"`
+}
+
+// HookType shows the timing which the hook runs at.
+type HookType string
+
+const (
+	// HookPreServiceOut runs before the cluster is serviced out.
+	HookPreServiceOut HookType = "preServiceOut"
+	// HookPreUpgrade runs once per cluster before the first of the master, the node pools and the workloads is upgraded.
+	HookPreUpgrade HookType = "preUpgrade"
+	// HookPostUpgrade runs after the master, all node pools and all workloads of the cluster are upgraded.
+	HookPostUpgrade HookType = "postUpgrade"
+	// HookPreServiceIn runs before the cluster is serviced in.
+	HookPreServiceIn HookType = "preServiceIn"
+)
+
+// HookTypes are all hook types in the order of running.
+var HookTypes = []HookType{HookPreServiceOut, HookPreUpgrade, HookPostUpgrade, HookPreServiceIn}
+
+func (in *Hooks) raw(t HookType) *runtime.RawExtension {
+	if in == nil {
+		return nil
+	}
+	switch t {
+	case HookPreServiceOut:
+		return in.PreServiceOut
+	case HookPreUpgrade:
+		return in.PreUpgrade
+	case HookPostUpgrade:
+		return in.PostUpgrade
+	case HookPreServiceIn:
+		return in.PreServiceIn
+	}
+	return nil
+}
+
+// Get returns the Job template of the hook type, or nil if it's not defined.
+// Unknown fields are reported as an error.
+func (in *Hooks) Get(t HookType) (*batchv1beta1.JobTemplateSpec, error) {
+	raw := in.raw(t)
+	if raw == nil || len(raw.Raw) == 0 {
+		return nil, nil
+	}
+	tmpl := &batchv1beta1.JobTemplateSpec{}
+	dec := json.NewDecoder(bytes.NewReader(raw.Raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
 }
 
 // Cluster defines the cluster spec
@@ -42,12 +257,51 @@ type ClusterVersionSpec struct {
 type Cluster struct {
 	ID      string `json:"id"`
 	Version string `json:"version"`
+	// KubeconfigSecretRef is the key of the Secret in the same namespace which holds the kubeconfig of the cluster.
+	// It's required by the operations which talk to the cluster directly, e.g. Helm workloads.
+	// +optional
+	KubeconfigSecretRef *corev1.SecretKeySelector `json:"kubeconfigSecretRef,omitempty"`
+	// OpsEndpoint overrides spec.opsEndpoint for the cluster, e.g. for the clusters of another provider.
+	// +optional
+	OpsEndpoint *OpsEndpoint `json:"opsEndpoint,omitempty"`
+}
+
+// GetOpsEndpoint returns the endpoint of the plugin server which operates the cluster.
+func (s ClusterVersionSpec) GetOpsEndpoint(clusterID string) OpsEndpoint {
+	for _, c := range s.Clusters {
+		if c.ID == clusterID && c.OpsEndpoint != nil {
+			return *c.OpsEndpoint
+		}
+	}
+	return s.OpsEndpoint
 }
 
+// OpsProtocol is the protocol of the plugin server.
+// +kubebuilder:validation:Enum=grpc;http
+type OpsProtocol string
+
+const (
+	// OpsProtocolGRPC is the gRPC plugin protocol.
+	OpsProtocolGRPC OpsProtocol = "grpc"
+	// OpsProtocolHTTP is the HTTP/JSON plugin protocol.
+	OpsProtocolHTTP OpsProtocol = "http"
+)
+
 // OpsEndpoint defines the endpoint spec for the gRPC server which performs specific operations.
 type OpsEndpoint struct {
-	Endpoint string `json:"endpoint"`
-	Insecure bool   `json:"insecure"`
+	// Provider is the name of the Operator registered in the controller, e.g. "grpc".
+	// The gRPC plugin server at the endpoint is used if it's empty.
+	// +optional
+	Provider string `json:"provider,omitempty"`
+	// Protocol is the protocol which the plugin server at the endpoint speaks. default value is "grpc".
+	// +optional
+	Protocol OpsProtocol `json:"protocol,omitempty"`
+	// Endpoint is the endpoint of the plugin server. It's required by the "grpc" provider.
+	// It's the base URL, e.g. "https://ops.example.com/plugin", with the "http" protocol.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
 }
 
 // ClusterVersionStatus defines the observed state of ClusterVersion
@@ -57,6 +311,151 @@ type ClusterVersionStatus struct {
 	ClusterID     string `json:"ClusterID"`
 	OperationID   string `json:"OperationID"`
 	OperationType string `json:"OperationType"`
+
+	// OperationStartTime is the time when the running operation has started.
+	// +optional
+	OperationStartTime *metav1.Time `json:"operationStartTime,omitempty"`
+
+	// CurrentOperation shows the progress of the running operation reported by the operator.
+	// +optional
+	CurrentOperation *CurrentOperation `json:"currentOperation,omitempty"`
+
+	// PendingOperation is the operation which has been requested but isn't recorded as running yet.
+	// +optional
+	PendingOperation *PendingOperation `json:"pendingOperation,omitempty"`
+
+	// OperationCount is the number of operations started so far. It makes the idempotency keys of the retried steps differ.
+	// +optional
+	OperationCount int64 `json:"operationCount,omitempty"`
+
+	// RolloutStartTime is the time when the current rollout has started.
+	// +optional
+	RolloutStartTime *metav1.Time `json:"rolloutStartTime,omitempty"`
+
+	// RolloutTraceParent is the W3C traceparent of the span which traces the current rollout.
+	// +optional
+	RolloutTraceParent string `json:"rolloutTraceParent,omitempty"`
+
+	// History is the bounded list of completed operations, the newest first.
+	// +optional
+	History []OperationHistory `json:"history,omitempty"`
+
+	// Drain shows the progress of draining the node pool before upgrading it.
+	// +optional
+	Drain *DrainStatus `json:"drain,omitempty"`
+
+	// Conditions are the latest observations of the ClusterVersion, e.g. PostUpgradeVerified.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ResolvedVersions are the desired versions of the clusters resolved by spec.versionPolicy.
+	// They're kept during the rollout so that every cluster reaches the same version.
+	// +optional
+	ResolvedVersions []ResolvedVersion `json:"resolvedVersions,omitempty"`
+
+	// InPlaceUpgrades are the IDs of the clusters upgraded without servicing out in the current rollout.
+	// The post-upgrade hooks and the verification run for them after the upgrade, as for the serviced out clusters.
+	// +optional
+	InPlaceUpgrades []string `json:"inPlaceUpgrades,omitempty"`
+}
+
+// ResolvedVersion is the desired version of the cluster resolved by spec.versionPolicy.
+type ResolvedVersion struct {
+	ClusterID string `json:"clusterID"`
+	Version   string `json:"version"`
+}
+
+const (
+	// ConditionPostUpgradeVerified shows whether the upgraded cluster has passed the verification before servicing in.
+	ConditionPostUpgradeVerified = "PostUpgradeVerified"
+)
+
+// DrainStatus shows the progress of draining the node pool.
+type DrainStatus struct {
+	ClusterID  string      `json:"clusterID"`
+	NodePoolID string      `json:"nodePoolID"`
+	StartTime  metav1.Time `json:"startTime"`
+	// +optional
+	Nodes []NodeDrainStatus `json:"nodes,omitempty"`
+	// TimeoutReported is true once the timeout has been reported, so that it's reported once per node pool.
+	// +optional
+	TimeoutReported bool `json:"timeoutReported,omitempty"`
+}
+
+// NodeDrainStatus shows the progress of draining the node.
+type NodeDrainStatus struct {
+	Name string `json:"name"`
+	// RemainingPods is the number of pods which haven't been evicted from the node yet.
+	RemainingPods int `json:"remainingPods"`
+	// Blocked shows the pods which can't be evicted, e.g. the pods not managed by any controller.
+	// They have to be deleted by hand before the node pool is upgraded.
+	// +optional
+	Blocked []string `json:"blocked,omitempty"`
+}
+
+// OperationResultType shows the outcome of a completed operation.
+type OperationResultType string
+
+const (
+	// OperationResultSucceeded shows the operation has done.
+	OperationResultSucceeded OperationResultType = "Succeeded"
+	// OperationResultFailed shows the operation has failed.
+	OperationResultFailed OperationResultType = "Failed"
+)
+
+// OperationHistory is the record of a completed operation.
+type OperationHistory struct {
+	ClusterID     string `json:"clusterID"`
+	OperationID   string `json:"operationID"`
+	OperationType string `json:"operationType"`
+	// +optional
+	StartTime *metav1.Time        `json:"startTime,omitempty"`
+	EndTime   metav1.Time         `json:"endTime"`
+	Result    OperationResultType `json:"result"`
+	// Message is the last message of the operation, or the error detail of the provider if it has failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PendingOperation is the intent to request an operation, which is recorded before calling the operator.
+// The request is retried with the same idempotency key until the operation is recorded as running,
+// so that the plugin server can return the existing operation instead of starting another one.
+type PendingOperation struct {
+	ClusterID string `json:"clusterID"`
+	// Step identifies the requested operation, e.g. "UPGRADE_NODE_POOL/<node pool ID>".
+	Step string `json:"step"`
+	// IdempotencyKey is sent with the request.
+	IdempotencyKey string `json:"idempotencyKey"`
+	// RequestTime is the time when the operation has been requested first.
+	RequestTime metav1.Time `json:"requestTime"`
+}
+
+// CurrentOperation shows the progress of the running operation reported by the operator.
+type CurrentOperation struct {
+	// Progress is the percentage of the operation. It's omitted if the operator doesn't report it.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Progress *int32 `json:"progress,omitempty"`
+	// Message is the human-readable status of the operation.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Error is the error detail of the provider when the operation has failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+	// LastUpdateTime is the time when the progress has changed last.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime"`
+}
+
+// Summary returns the error detail if any, otherwise the message.
+func (in *CurrentOperation) Summary() string {
+	if in == nil {
+		return ""
+	}
+	if in.Error != "" {
+		return in.Error
+	}
+	return in.Message
 }
 
 // +kubebuilder:object:root=true
@@ -84,8 +483,224 @@ func init() {
 	SchemeBuilder.Register(&ClusterVersion{}, &ClusterVersionList{})
 }
 
+const (
+	// DefaultHistoryLimit is the number of completed operations kept in status.history when historyLimit isn't given.
+	DefaultHistoryLimit = 10
+	// DefaultNodePoolLabel is the node label of the node pool name when nodePoolLabel isn't given.
+	DefaultNodePoolLabel = "cloud.google.com/gke-nodepool"
+	// DefaultDrainTimeout is how long to wait for the node pool to be drained when timeout isn't given.
+	DefaultDrainTimeout = time.Hour
+)
+
 func (in *ClusterVersionStatus) ResetStatus() {
 	in.OperationID = ""
 	in.OperationType = ""
 	in.ClusterID = ""
+	in.OperationStartTime = nil
+	in.CurrentOperation = nil
+	in.Drain = nil
+}
+
+// RecordHistory pushes the running operation to the history as completed with the given result.
+// The oldest records are dropped when the history exceeds the limit.
+func (in *ClusterVersionStatus) RecordHistory(result OperationResultType, now metav1.Time, limit int) {
+	h := OperationHistory{
+		ClusterID:     in.ClusterID,
+		OperationID:   in.OperationID,
+		OperationType: in.OperationType,
+		StartTime:     in.OperationStartTime,
+		EndTime:       now,
+		Result:        result,
+		Message:       in.CurrentOperation.Summary(),
+	}
+	in.History = append([]OperationHistory{h}, in.History...)
+	if len(in.History) > limit {
+		in.History = in.History[:limit]
+	}
+}
+
+// GetHistoryLimit returns the number of completed operations kept in status.history.
+func (in *ClusterVersionSpec) GetHistoryLimit() int {
+	if in.HistoryLimit == nil {
+		return DefaultHistoryLimit
+	}
+	return *in.HistoryLimit
+}
+
+// IsUpgradedInPlace returns true if the cluster has been upgraded without servicing out in the current rollout.
+func (in *ClusterVersionStatus) IsUpgradedInPlace(clusterID string) bool {
+	for _, id := range in.InPlaceUpgrades {
+		if id == clusterID {
+			return true
+		}
+	}
+	return false
+}
+
+// SetUpgradedInPlace records whether the cluster has been upgraded without servicing out in the current rollout.
+func (in *ClusterVersionStatus) SetUpgradedInPlace(clusterID string, upgraded bool) {
+	ids := make([]string, 0, len(in.InPlaceUpgrades)+1)
+	for _, id := range in.InPlaceUpgrades {
+		if id != clusterID {
+			ids = append(ids, id)
+		}
+	}
+	if upgraded {
+		ids = append(ids, clusterID)
+	}
+	if len(ids) == 0 {
+		ids = nil
+	}
+	in.InPlaceUpgrades = ids
+}
+
+// GetResolvedVersion returns the resolved version of the cluster.
+func (in *ClusterVersionStatus) GetResolvedVersion(clusterID string) (string, bool) {
+	for _, v := range in.ResolvedVersions {
+		if v.ClusterID == clusterID {
+			return v.Version, true
+		}
+	}
+	return "", false
+}
+
+// GetMode returns how the desired versions are resolved.
+func (in *VersionPolicy) GetMode() VersionPolicyMode {
+	if in == nil || in.Mode == "" {
+		return VersionPolicyPinned
+	}
+	return in.Mode
+}
+
+// Resolve returns the desired version from the available versions.
+// The version is returned as it is in the Pinned mode, otherwise the latest available version which the version is a prefix of.
+func (in *VersionPolicy) Resolve(version string, available []string) (string, error) {
+	if in.GetMode() == VersionPolicyPinned {
+		return version, nil
+	}
+	var latest *utilversion.Version
+	resolved := ""
+	for _, v := range available {
+		if !hasVersionPrefix(v, version) {
+			continue
+		}
+		parsed, err := parseVersion(v)
+		if err != nil {
+			return "", err
+		}
+		if latest == nil || latest.LessThan(parsed) {
+			latest = parsed
+			resolved = v
+		}
+	}
+	if resolved == "" {
+		return "", fmt.Errorf("no available version matches %s", version)
+	}
+	return resolved, nil
+}
+
+// hasVersionPrefix returns true if the version is the prefix or its patch, e.g. "1.18.9-eks-d1db3c" for "1.18".
+func hasVersionPrefix(version string, prefix string) bool {
+	version = strings.TrimPrefix(version, "v")
+	prefix = strings.TrimPrefix(prefix, "v")
+	return prefix == "" || version == prefix || strings.HasPrefix(version, prefix+".") || strings.HasPrefix(version, prefix+"-")
+}
+
+// parseVersion parses the provider version, e.g. "1.18.12-gke.1210" as a semantic version to compare the suffixes too.
+func parseVersion(v string) (*utilversion.Version, error) {
+	if parsed, err := utilversion.ParseSemantic(v); err == nil {
+		return parsed, nil
+	}
+	return utilversion.ParseGeneric(v)
+}
+
+// opensOn returns true if the window opens on the day.
+func (in *MaintenanceWindow) opensOn(day time.Weekday) bool {
+	if len(in.Days) == 0 {
+		return true
+	}
+	for _, d := range in.Days {
+		if string(d) == day.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// openings returns the times when the window opens from the day before t until the week after t.
+func (in *MaintenanceWindow) openings(t time.Time) []time.Time {
+	start, err := time.Parse(maintenanceWindowStartLayout, in.Start)
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+	var ret []time.Time
+	for i := -1; i <= 7; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, start.Hour(), start.Minute(), 0, 0, time.UTC)
+		if in.opensOn(day.Weekday()) {
+			ret = append(ret, day)
+		}
+	}
+	return ret
+}
+
+// Contains returns true if the window is open at t. It's always open if it's nil.
+func (in *MaintenanceWindow) Contains(t time.Time) bool {
+	if in == nil {
+		return true
+	}
+	for _, open := range in.openings(t) {
+		if !t.Before(open) && t.Before(open.Add(in.Duration.Duration)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the time when the window opens next after t, or the zero time if it never opens.
+func (in *MaintenanceWindow) NextOpen(t time.Time) time.Time {
+	for _, open := range in.openings(t) {
+		if open.After(t) {
+			return open
+		}
+	}
+	return time.Time{}
+}
+
+// DesiredCluster returns the cluster with the desired version, which is the one in status.resolvedVersions
+// if it has been resolved by spec.versionPolicy, otherwise the one in spec.clusters.
+func (in *ClusterVersion) DesiredCluster(cluster Cluster) Cluster {
+	if version, ok := in.Status.GetResolvedVersion(cluster.ID); ok {
+		cluster.Version = version
+	}
+	return cluster
+}
+
+// GetNodePoolLabel returns the node label whose value is the node pool name.
+func (in *DrainPolicy) GetNodePoolLabel() string {
+	if in.NodePoolLabel == "" {
+		return DefaultNodePoolLabel
+	}
+	return in.NodePoolLabel
+}
+
+// GetTimeout returns how long to wait for each node pool to be drained.
+func (in *DrainPolicy) GetTimeout() time.Duration {
+	if in.Timeout == nil {
+		return DefaultDrainTimeout
+	}
+	return in.Timeout.Duration
+}
+
+// Drained returns true if the nodes have been found and no pods remain on any of them.
+func (in *DrainStatus) Drained() bool {
+	if len(in.Nodes) == 0 {
+		return false
+	}
+	for _, n := range in.Nodes {
+		if n.RemainingPods > 0 || len(n.Blocked) > 0 {
+			return false
+		}
+	}
+	return true
 }
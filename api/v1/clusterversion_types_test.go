@@ -0,0 +1,288 @@
+package v1_test
+
+import (
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestClusterVersionStatus_RecordHistory(t *testing.T) {
+	tc := []struct {
+		name        string
+		in          []v1.OperationHistory
+		limit       int
+		expectedIDs []string
+	}{
+		{
+			name:        "push to empty history",
+			in:          nil,
+			limit:       2,
+			expectedIDs: []string{"dummy-id"},
+		},
+		{
+			name: "push the newest first",
+			in: []v1.OperationHistory{
+				{OperationID: "old-1"},
+			},
+			limit:       2,
+			expectedIDs: []string{"dummy-id", "old-1"},
+		},
+		{
+			name: "drop the oldest over the limit",
+			in: []v1.OperationHistory{
+				{OperationID: "old-1"},
+				{OperationID: "old-2"},
+			},
+			limit:       2,
+			expectedIDs: []string{"dummy-id", "old-1"},
+		},
+		{
+			name: "keep nothing when limit is zero",
+			in: []v1.OperationHistory{
+				{OperationID: "old-1"},
+			},
+			limit:       0,
+			expectedIDs: []string{},
+		},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			mc := makeClusterVersionWithOperation("default", "history-clusters")
+			mc.Status.History = c.in
+			mc.Status.RecordHistory(v1.OperationResultSucceeded, metav1.Now(), c.limit)
+
+			ids := make([]string, len(mc.Status.History))
+			for i, h := range mc.Status.History {
+				ids[i] = h.OperationID
+			}
+			g.Expect(ids).Should(Equal(c.expectedIDs))
+			if len(mc.Status.History) > 0 && mc.Status.History[0].OperationID == "dummy-id" {
+				g.Expect(mc.Status.History[0].ClusterID).Should(Equal(mc.Spec.Clusters[0].ID))
+				g.Expect(mc.Status.History[0].OperationType).Should(Equal("DUMMY_OPERATION"))
+				g.Expect(mc.Status.History[0].Result).Should(Equal(v1.OperationResultSucceeded))
+			}
+		})
+	}
+}
+
+func TestClusterVersionStatus_SetUpgradedInPlace(t *testing.T) {
+	g := NewGomegaWithT(t)
+	st := v1.ClusterVersionStatus{}
+	st.SetUpgradedInPlace("cluster-1", true)
+	st.SetUpgradedInPlace("cluster-1", true)
+	st.SetUpgradedInPlace("cluster-2", true)
+	g.Expect(st.InPlaceUpgrades).Should(Equal([]string{"cluster-1", "cluster-2"}))
+	g.Expect(st.IsUpgradedInPlace("cluster-1")).Should(BeTrue())
+
+	st.SetUpgradedInPlace("cluster-1", false)
+	g.Expect(st.IsUpgradedInPlace("cluster-1")).Should(BeFalse())
+	st.SetUpgradedInPlace("cluster-2", false)
+	g.Expect(st.InPlaceUpgrades).Should(BeNil())
+}
+
+func TestCurrentOperation_Summary(t *testing.T) {
+	tc := []struct {
+		name     string
+		in       *v1.CurrentOperation
+		expected string
+	}{
+		{
+			name:     "ret empty for no operation",
+			in:       nil,
+			expected: "",
+		},
+		{
+			name:     "ret the message",
+			in:       &v1.CurrentOperation{Message: "upgrading nodes 2/3"},
+			expected: "upgrading nodes 2/3",
+		},
+		{
+			name:     "ret the error prior to the message",
+			in:       &v1.CurrentOperation{Message: "upgrading nodes 2/3", Error: "quota exceeded"},
+			expected: "quota exceeded",
+		},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			g.Expect(c.in.Summary()).Should(Equal(c.expected))
+		})
+	}
+}
+
+func TestClusterVersionSpec_GetOpsEndpoint(t *testing.T) {
+	spec := v1.ClusterVersionSpec{
+		OpsEndpoint: v1.OpsEndpoint{Endpoint: "gke-plugin:39000"},
+		Clusters: []v1.Cluster{
+			{ID: "gke-cluster"},
+			{ID: "eks-cluster", OpsEndpoint: &v1.OpsEndpoint{Endpoint: "eks-plugin:39000", Insecure: true}},
+		},
+	}
+	tc := []struct {
+		name      string
+		clusterID string
+		expected  v1.OpsEndpoint
+	}{
+		{
+			name:      "ret the default endpoint",
+			clusterID: "gke-cluster",
+			expected:  v1.OpsEndpoint{Endpoint: "gke-plugin:39000"},
+		},
+		{
+			name:      "ret the endpoint of the cluster",
+			clusterID: "eks-cluster",
+			expected:  v1.OpsEndpoint{Endpoint: "eks-plugin:39000", Insecure: true},
+		},
+		{
+			name:      "ret the default endpoint for unknown cluster",
+			clusterID: "unknown",
+			expected:  v1.OpsEndpoint{Endpoint: "gke-plugin:39000"},
+		},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			g.Expect(spec.GetOpsEndpoint(c.clusterID)).Should(Equal(c.expected))
+		})
+	}
+}
+
+func TestVersionPolicy_Resolve(t *testing.T) {
+	available := []string{"1.17.14-gke.400", "1.18.12-gke.900", "1.18.12-gke.1210", "1.18.9-gke.2501", "1.19.4-gke.1600"}
+	tc := []struct {
+		name           string
+		policy         *v1.VersionPolicy
+		version        string
+		expected       string
+		expectedHasErr bool
+	}{
+		{
+			name:     "ret the version as it is without policy",
+			policy:   nil,
+			version:  "1.18",
+			expected: "1.18",
+		},
+		{
+			name:     "ret the version as it is in Pinned mode",
+			policy:   &v1.VersionPolicy{Mode: v1.VersionPolicyPinned},
+			version:  "1.18.9-gke.2501",
+			expected: "1.18.9-gke.2501",
+		},
+		{
+			name:     "ret the latest patch of the minor version",
+			policy:   &v1.VersionPolicy{Mode: v1.VersionPolicyLatestPatch},
+			version:  "1.18",
+			expected: "1.18.12-gke.1210",
+		},
+		{
+			name:     "ret the latest version of the channel",
+			policy:   &v1.VersionPolicy{Mode: v1.VersionPolicyChannel, Channel: "REGULAR"},
+			version:  "1",
+			expected: "1.19.4-gke.1600",
+		},
+		{
+			name:           "ret error when no version matches",
+			policy:         &v1.VersionPolicy{Mode: v1.VersionPolicyLatestPatch},
+			version:        "1.20",
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			ret, err := c.policy.Resolve(c.version, available)
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+				return
+			}
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(ret).Should(Equal(c.expected))
+		})
+	}
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	// 2021-01-02 is Saturday
+	saturday := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	tc := []struct {
+		name     string
+		window   *v1.MaintenanceWindow
+		at       time.Time
+		expected bool
+	}{
+		{
+			name:     "ret true without window",
+			window:   nil,
+			at:       saturday,
+			expected: true,
+		},
+		{
+			name:     "ret true in the window",
+			window:   &v1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+			at:       saturday.Add(4 * time.Hour),
+			expected: true,
+		},
+		{
+			name:     "ret false after the window",
+			window:   &v1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+			at:       saturday.Add(5 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "ret true in the window opened the day before",
+			window:   &v1.MaintenanceWindow{Start: "22:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+			at:       saturday.Add(time.Hour),
+			expected: true,
+		},
+		{
+			name:     "ret true in the window of the day",
+			window:   &v1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}, Days: []v1.Weekday{"Saturday"}},
+			at:       saturday.Add(4 * time.Hour),
+			expected: true,
+		},
+		{
+			name:     "ret false in the window of another day",
+			window:   &v1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}, Days: []v1.Weekday{"Sunday"}},
+			at:       saturday.Add(4 * time.Hour),
+			expected: false,
+		},
+		{
+			name:     "ret true in the time zone other than UTC",
+			window:   &v1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+			at:       saturday.Add(4 * time.Hour).In(time.FixedZone("JST", 9*60*60)),
+			expected: true,
+		},
+	}
+	for _, c := range tc {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			g.Expect(c.window.Contains(c.at)).Should(Equal(c.expected))
+		})
+	}
+}
+
+func TestMaintenanceWindow_NextOpen(t *testing.T) {
+	g := NewGomegaWithT(t)
+	// 2021-01-02 is Saturday
+	saturday := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	window := &v1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}}
+	g.Expect(window.NextOpen(saturday)).Should(Equal(saturday.Add(3 * time.Hour)))
+	g.Expect(window.NextOpen(saturday.Add(4 * time.Hour))).Should(Equal(saturday.Add(27 * time.Hour)))
+
+	window.Days = []v1.Weekday{"Friday"}
+	g.Expect(window.NextOpen(saturday)).Should(Equal(saturday.Add(6*24*time.Hour + 3*time.Hour)))
+}
+
+func TestClusterVersion_DesiredCluster(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := &v1.ClusterVersion{}
+	obj.Spec.Clusters = []v1.Cluster{{ID: "cluster-1", Version: "1.18"}, {ID: "cluster-2", Version: "1.18"}}
+	obj.Status.ResolvedVersions = []v1.ResolvedVersion{{ClusterID: "cluster-1", Version: "1.18.12-gke.1210"}}
+
+	g.Expect(obj.DesiredCluster(obj.Spec.Clusters[0]).Version).Should(Equal("1.18.12-gke.1210"))
+	g.Expect(obj.DesiredCluster(obj.Spec.Clusters[1]).Version).Should(Equal("1.18"))
+	g.Expect(obj.Spec.Clusters[0].Version).Should(Equal("1.18"), "the spec shouldn't be modified")
+}
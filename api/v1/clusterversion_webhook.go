@@ -25,11 +25,16 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"time"
 )
 
 // log is for logging in this package.
 var clusterversionlog = logf.Log.WithName("clusterversion-resource")
 
+// SpecValidator validates the spec against what the plugin servers support, e.g. the versions the clusters can be
+// upgraded to. It's set by the controller, since this package can't depend on the operators. Nothing is validated if it's nil.
+var SpecValidator func(r *ClusterVersion) field.ErrorList
+
 func (r *ClusterVersion) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
@@ -70,10 +75,65 @@ func (r *ClusterVersion) validateDuplicate() *field.Error {
 	return nil
 }
 
+func (r *ClusterVersion) validateDuplicateWorkload() *field.Error {
+	path := field.NewPath("spec").Child("workloads")
+	names := map[string]bool{}
+	for _, w := range r.Spec.Workloads {
+		if names[w.Name] {
+			return field.Invalid(path, w.Name, "duplicate workload name")
+		}
+		names[w.Name] = true
+	}
+	return nil
+}
+
+func (r *ClusterVersion) validateHooks() field.ErrorList {
+	errList := field.ErrorList{}
+	path := field.NewPath("spec").Child("hooks")
+	for _, t := range HookTypes {
+		if _, err := r.Spec.Hooks.Get(t); err != nil {
+			errList = append(errList, field.Invalid(path.Child(string(t)), "", err.Error()))
+		}
+	}
+	return errList
+}
+
+func (r *ClusterVersion) validateVersionPolicy() field.ErrorList {
+	errList := field.ErrorList{}
+	path := field.NewPath("spec").Child("versionPolicy")
+	policy := r.Spec.VersionPolicy
+	if policy.GetMode() == VersionPolicyChannel && policy.Channel == "" {
+		errList = append(errList, field.Required(path.Child("channel"), "channel is required by the Channel mode"))
+	}
+	if policy == nil || policy.MaintenanceWindow == nil {
+		return errList
+	}
+	window := policy.MaintenanceWindow
+	path = path.Child("maintenanceWindow")
+	if _, err := time.Parse(maintenanceWindowStartLayout, window.Start); err != nil {
+		errList = append(errList, field.Invalid(path.Child("start"), window.Start, "start must be in HH:MM format"))
+	}
+	if window.Duration.Duration <= 0 {
+		errList = append(errList, field.Invalid(path.Child("duration"), window.Duration.Duration.String(), "duration must be positive"))
+	}
+	return errList
+}
+
 func (r *ClusterVersion) validateClusters() error {
 	errList := field.ErrorList{}
 	if err := r.validateDuplicate(); err != nil {
 		errList = append(errList, err)
+	}
+	if err := r.validateDuplicateWorkload(); err != nil {
+		errList = append(errList, err)
+	}
+	errList = append(errList, r.validateHooks()...)
+	errList = append(errList, r.validateVersionPolicy()...)
+	// the plugin servers aren't asked about the spec which is invalid anyway
+	if len(errList) == 0 && SpecValidator != nil {
+		errList = append(errList, SpecValidator(r)...)
+	}
+	if len(errList) > 0 {
 		return apierr.NewInvalid(schema.GroupKind{
 			Group: "multicluster-ops.io",
 			Kind:  "ClusterVersion",
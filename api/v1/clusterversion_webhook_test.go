@@ -5,6 +5,8 @@ import (
 	"fmt"
 	. "github.com/onsi/gomega"
 	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"testing"
 )
 
@@ -40,6 +42,38 @@ func makeClusterVersionWithDuplicate(namespace, name string) *v1.ClusterVersion
 	return mc
 }
 
+func makeClusterVersionWithInvalidHook(namespace, name string) *v1.ClusterVersion {
+	mc := makeClusterVersion(namespace, name)
+	mc.Spec.Hooks = &v1.Hooks{
+		PreUpgrade: &runtime.RawExtension{Raw: []byte(`{"spec":{"template":{"spec":{"containerz":[]}}}}`)},
+	}
+	return mc
+}
+
+func makeClusterVersionWithDuplicateWorkload(namespace, name string) *v1.ClusterVersion {
+	mc := makeClusterVersion(namespace, name)
+	mc.Spec.Workloads = []v1.Workload{
+		{Name: "istio", Version: "1.8.1"},
+		{Name: "istio", Version: "1.8.2"},
+	}
+	return mc
+}
+
+func makeClusterVersionWithoutChannel(namespace, name string) *v1.ClusterVersion {
+	mc := makeClusterVersion(namespace, name)
+	mc.Spec.VersionPolicy = &v1.VersionPolicy{Mode: v1.VersionPolicyChannel}
+	return mc
+}
+
+func makeClusterVersionWithInvalidWindow(namespace, name string) *v1.ClusterVersion {
+	mc := makeClusterVersion(namespace, name)
+	mc.Spec.VersionPolicy = &v1.VersionPolicy{
+		Mode:              v1.VersionPolicyLatestPatch,
+		MaintenanceWindow: &v1.MaintenanceWindow{Start: "3:00 AM"},
+	}
+	return mc
+}
+
 func TestClusterVersion_ValidateCreate(t *testing.T) {
 	tc := []struct {
 		name     string
@@ -56,6 +90,26 @@ func TestClusterVersion_ValidateCreate(t *testing.T) {
 			in:       makeClusterVersionWithDuplicate("default", "duplicate-clusters"),
 			expected: errors.New("ClusterVersion.multicluster-ops.io \"duplicate-clusters\" is invalid: spec.clusters: Invalid value: \"duplicate-clusters/cluster-1\": duplicate cluster id"),
 		},
+		{
+			name:     "work as invalid hook error",
+			in:       makeClusterVersionWithInvalidHook("default", "invalid-hook"),
+			expected: errors.New("ClusterVersion.multicluster-ops.io \"invalid-hook\" is invalid: spec.hooks.preUpgrade: Invalid value: \"\": json: unknown field \"containerz\""),
+		},
+		{
+			name:     "work as duplicate workload error",
+			in:       makeClusterVersionWithDuplicateWorkload("default", "duplicate-workloads"),
+			expected: errors.New("ClusterVersion.multicluster-ops.io \"duplicate-workloads\" is invalid: spec.workloads: Invalid value: \"istio\": duplicate workload name"),
+		},
+		{
+			name:     "work as missing channel error",
+			in:       makeClusterVersionWithoutChannel("default", "missing-channel"),
+			expected: errors.New("ClusterVersion.multicluster-ops.io \"missing-channel\" is invalid: spec.versionPolicy.channel: Required value: channel is required by the Channel mode"),
+		},
+		{
+			name:     "work as invalid maintenance window error",
+			in:       makeClusterVersionWithInvalidWindow("default", "invalid-window"),
+			expected: errors.New("ClusterVersion.multicluster-ops.io \"invalid-window\" is invalid: [spec.versionPolicy.maintenanceWindow.start: Invalid value: \"3:00 AM\": start must be in HH:MM format, spec.versionPolicy.maintenanceWindow.duration: Invalid value: \"0s\": duration must be positive]"),
+		},
 	}
 	for _, c := range tc {
 		t.Run(c.name, func(t *testing.T) {
@@ -71,6 +125,28 @@ func TestClusterVersion_ValidateCreate(t *testing.T) {
 	}
 }
 
+func TestClusterVersion_ValidateCreate_SpecValidator(t *testing.T) {
+	g := NewGomegaWithT(t)
+	called := 0
+	v1.SpecValidator = func(r *v1.ClusterVersion) field.ErrorList {
+		called++
+		return field.ErrorList{field.NotSupported(field.NewPath("spec").Child("clusters").Index(0).Child("version"), r.Spec.Clusters[0].Version, []string{"1.16.15-gke.4301"})}
+	}
+	defer func() {
+		v1.SpecValidator = nil
+	}()
+
+	ret := makeClusterVersion("default", "unavailable-version").ValidateCreate()
+	g.Expect(ret).ShouldNot(BeNil())
+	g.Expect(ret.Error()).Should(Equal("ClusterVersion.multicluster-ops.io \"unavailable-version\" is invalid: spec.clusters[0].version: Unsupported value: \"1.16.13-gke.404\": supported values: \"1.16.15-gke.4301\""))
+	g.Expect(called).Should(Equal(1))
+
+	// the invalid spec isn't validated by the plugin servers
+	ret = makeClusterVersionWithDuplicate("default", "duplicate-clusters").ValidateCreate()
+	g.Expect(ret).ShouldNot(BeNil())
+	g.Expect(called).Should(Equal(1))
+}
+
 func TestClusterVersion_ValidateUpdate(t *testing.T) {
 	tc := []struct {
 		name     string
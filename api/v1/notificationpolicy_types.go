@@ -0,0 +1,111 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationEventType shows the type of rollout lifecycle event.
+// +kubebuilder:validation:Enum=RolloutStarted;ServiceOut;OperationFailed;RolloutHalted;RolloutCompleted
+type NotificationEventType string
+
+const (
+	// NotificationRolloutStarted is sent when the first operation of a rollout has started.
+	NotificationRolloutStarted NotificationEventType = "RolloutStarted"
+	// NotificationServiceOut is sent when a cluster has been requested to service out.
+	NotificationServiceOut NotificationEventType = "ServiceOut"
+	// NotificationOperationFailed is sent when an operation has failed.
+	NotificationOperationFailed NotificationEventType = "OperationFailed"
+	// NotificationRolloutHalted is sent when the rollout can't proceed.
+	NotificationRolloutHalted NotificationEventType = "RolloutHalted"
+	// NotificationRolloutCompleted is sent when all clusters have reached the desired versions.
+	NotificationRolloutCompleted NotificationEventType = "RolloutCompleted"
+)
+
+// NotificationSinkType shows the protocol of the notification sink.
+// +kubebuilder:validation:Enum=Webhook;Slack;CloudEvents
+type NotificationSinkType string
+
+const (
+	// NotificationSinkWebhook posts the event as a generic JSON.
+	NotificationSinkWebhook NotificationSinkType = "Webhook"
+	// NotificationSinkSlack posts the event to a Slack-compatible incoming webhook.
+	NotificationSinkSlack NotificationSinkType = "Slack"
+	// NotificationSinkCloudEvents posts the event as a structured CloudEvent over HTTP.
+	NotificationSinkCloudEvents NotificationSinkType = "CloudEvents"
+)
+
+// NotificationSink defines the destination of notifications.
+type NotificationSink struct {
+	Name string               `json:"name"`
+	Type NotificationSinkType `json:"type"`
+	// URLSecretRef refers to the key of the Secret in the same namespace which holds the destination URL.
+	URLSecretRef corev1.SecretKeySelector `json:"urlSecretRef"`
+	// Template is the Go template of the message text. The event is given as the data.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// NotificationPolicySpec defines the desired state of NotificationPolicy
+type NotificationPolicySpec struct {
+	// Selector selects ClusterVersions in the same namespace. All ClusterVersions are selected if it's empty.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Events are the event types to notify. All event types are notified if it's empty.
+	// +optional
+	Events []NotificationEventType `json:"events,omitempty"`
+	// +kubebuilder:validation:MinItems=1
+	Sinks []NotificationSink `json:"sinks"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationPolicy is the Schema for the notificationpolicies API
+type NotificationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NotificationPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NotificationPolicyList contains a list of NotificationPolicy
+type NotificationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationPolicy{}, &NotificationPolicyList{})
+}
+
+// Subscribes returns true if the policy notifies the given event type.
+func (in *NotificationPolicySpec) Subscribes(t NotificationEventType) bool {
+	if len(in.Events) == 0 {
+		return true
+	}
+	for _, e := range in.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
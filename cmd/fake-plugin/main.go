@@ -0,0 +1,79 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// fake-plugin is the plugin server with an in-memory fleet to run the controller end-to-end without a cloud account.
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var log = ctrl.Log.WithName("fake-plugin")
+
+func main() {
+	var addr string
+	var adminAddr string
+	var configPath string
+	var debug bool
+	flag.StringVar(&addr, "addr", ":39000", "The address the gRPC plugin server binds to.")
+	flag.StringVar(&adminAddr, "admin-addr", ":39001", "The address the admin HTTP API binds to. The admin API is disabled if it's empty.")
+	flag.StringVar(&configPath, "config", "fleet.yaml", "The YAML file of the fleet and the settings.")
+	flag.BoolVar(&debug, "debug", false, "Enable debug mode. if debug is true, the server outputs logs of debug level.")
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseDevMode(debug)))
+
+	cfg, err := fakeplugin.LoadConfig(configPath)
+	if err != nil {
+		log.Error(err, "unable to load config")
+		os.Exit(1)
+	}
+	server := fakeplugin.NewServer(*cfg)
+
+	if adminAddr != "" {
+		go func() {
+			log.Info("starting admin API", "addr", adminAddr)
+			if err := http.ListenAndServe(adminAddr, fakeplugin.NewAdminHandler(server)); err != nil {
+				log.Error(err, "admin API stopped")
+				os.Exit(1)
+			}
+		}()
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Error(err, "unable to listen", "addr", addr)
+		os.Exit(1)
+	}
+	s := grpc.NewServer()
+	plugin.RegisterClusterServer(s, server)
+	pluginext.RegisterClusterExtensionServer(s, server)
+	log.Info("starting plugin server", "addr", addr, "clusters", len(cfg.Clusters))
+	if err := s.Serve(lis); err != nil {
+		log.Error(err, "plugin server stopped")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// plugin-conformance runs the conformance scenario against a plugin server and reports the violations.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/conformance"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+)
+
+func main() {
+	var endpoint string
+	var insecure bool
+	var clusterID string
+	var version string
+	var nodePools int
+	var timeout time.Duration
+	var pollInterval time.Duration
+	flag.StringVar(&endpoint, "endpoint", "localhost:39000", "The endpoint of the plugin server.")
+	flag.BoolVar(&insecure, "insecure", false, "Communicate with the plugin server without TLS.")
+	flag.StringVar(&clusterID, "cluster-id", "", "The ID of the cluster to operate. It's serviced out and in, so it must not serve production traffic.")
+	flag.StringVar(&version, "version", "", "The version to upgrade the cluster to. The upgrade steps are skipped if it's empty.")
+	flag.IntVar(&nodePools, "node-pools", 0, "The number of node pools which the cluster has. It isn't checked if it's zero.")
+	flag.DurationVar(&timeout, "timeout", time.Minute*30, "How long to wait for each operation.")
+	flag.DurationVar(&pollInterval, "poll-interval", time.Second*5, "The interval of getting the operation status.")
+	flag.Parse()
+
+	if clusterID == "" {
+		fmt.Fprintln(os.Stderr, "--cluster-id is required")
+		os.Exit(2)
+	}
+	cluster := opsv1.Cluster{
+		ID:          clusterID,
+		Version:     version,
+		OpsEndpoint: &opsv1.OpsEndpoint{Endpoint: endpoint, Insecure: insecure},
+	}
+	report := conformance.Run(context.Background(), ops.NewPluginOperator(ops.DefaultNewFunc), conformance.Scenario{
+		Cluster:      cluster,
+		NodePools:    nodePools,
+		Timeout:      timeout,
+		PollInterval: pollInterval,
+	})
+	for _, s := range report.Steps {
+		if len(s.Violations) == 0 {
+			fmt.Printf("PASS %s\n", s.Name)
+			continue
+		}
+		fmt.Printf("FAIL %s\n", s.Name)
+		for _, v := range s.Violations {
+			fmt.Printf("     - %s\n", v)
+		}
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+)
+
+const (
+	reasonOperationAdopted = "OperationAdopted"
+)
+
+// adoptOperation records the operation of the step running in the provider for the cluster as the running operation,
+// instead of starting another one which would conflict with it, e.g. the operation whose ID has been lost or which
+// has been started manually in the console. It returns false if there's nothing to adopt.
+func (r *ClusterVersionReconciler) adoptOperation(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, step string, log logr.Logger) (ctrl.Result, bool, error) {
+	lo, ok := r.Operator.(ops.OperationListOperator)
+	if !ok {
+		return ctrl.Result{}, false, nil
+	}
+	operations, err := lo.ListOperations(ctx, *obj, cluster)
+	if errors.Is(err, ops.ErrNotSupported) {
+		return ctrl.Result{}, false, nil
+	}
+	if err != nil {
+		// don't start another operation while the running ones are unknown
+		log.Error(err, "failed to list operations")
+		return ctrl.Result{}, true, nil
+	}
+	kind, target := splitStep(step)
+	for _, op := range operations {
+		if op.Status != ops.OperationStatusRunning || op.OperationType != kind || op.Target != target {
+			continue
+		}
+		log.Info("adopt the running operation", "cluster", cluster.ID, "operation_id", op.OperationID, "operation_type", op.OperationType, "target", op.Target)
+		r.Recorder.Eventf(obj, corev1.EventTypeNormal, reasonOperationAdopted, "operation_type: %s, operation_id: %s", op.OperationType, op.OperationID)
+		ret, err := r.startOperation(ctx, obj, cluster, &ops.OperationResult{
+			OperationID:   op.OperationID,
+			OperationType: op.OperationType,
+		}, log)
+		return ret, true, err
+	}
+	return ctrl.Result{}, false, nil
+}
+
+// splitStep returns the kind and the target of the step, e.g. "UPGRADE_NODE_POOL" and the node pool ID for
+// "UPGRADE_NODE_POOL/<node pool ID>". The target is empty for the steps without it.
+func splitStep(step string) (string, string) {
+	i := strings.Index(step, "/")
+	if i < 0 {
+		return step, ""
+	}
+	return step[:i], step[i+1:]
+}
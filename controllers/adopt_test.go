@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("adoptOperation", func() {
+	var (
+		obj *opsv1.ClusterVersion
+		m   *mockOperator
+		r   *ClusterVersionReconciler
+		id  string
+		ctx = context.Background()
+		log = ctrl.Log.WithName("test")
+	)
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "adoption-test", ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{{ID: "cluster-1", Version: "1.16.13-gke.404"}, {ID: "cluster-2", Version: "1.16.13-gke.404"}},
+			},
+		}
+		m = newMockOperator()
+		id = m.AddRunningOperation("cluster-1", "1.16.13-gke.404")
+		r = &ClusterVersionReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, obj.DeepCopy()),
+			Operator: m,
+			Recorder: record.NewFakeRecorder(10),
+		}
+	})
+
+	It("adopts nothing for the cluster without running operations", func() {
+		_, adopted, err := r.adoptOperation(ctx, obj, obj.Spec.Clusters[1], stepUpgradeMaster, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeFalse())
+	})
+
+	It("adopts nothing if the running operation is of another kind than the step", func() {
+		_, adopted, err := r.adoptOperation(ctx, obj, obj.Spec.Clusters[0], stepUpgradeNodePool+"/default-pool", log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeFalse())
+	})
+
+	It("adopts nothing if the operator can't list the operations", func() {
+		r.Operator = struct{ ops.Operator }{m}
+
+		_, adopted, err := r.adoptOperation(ctx, obj, obj.Spec.Clusters[0], stepUpgradeMaster, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeFalse())
+	})
+
+	It("adopts the running operation started outside of the controller, e.g. in the console", func() {
+		Expect(obj.Status.PendingOperation).Should(BeNil())
+
+		_, adopted, err := r.adoptOperation(ctx, obj, obj.Spec.Clusters[0], stepUpgradeMaster, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeTrue())
+
+		By("[check] the operation is recorded in status without starting another one")
+		Expect(obj.Status.ClusterID).Should(Equal("cluster-1"))
+		Expect(obj.Status.OperationID).Should(Equal(id))
+		Expect(obj.Status.OperationType).Should(Equal("UPGRADE_MASTER"))
+		Expect(m.executedOperations[obj.Name]).Should(BeEmpty())
+	})
+
+	It("adopts the running operation of the requested step whose ID has been lost", func() {
+		obj.Status.PendingOperation = &opsv1.PendingOperation{ClusterID: "cluster-1", Step: stepUpgradeMaster, IdempotencyKey: "key"}
+
+		_, adopted, err := r.adoptOperation(ctx, obj, obj.Spec.Clusters[0], stepUpgradeMaster, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeTrue())
+		Expect(obj.Status.OperationID).Should(Equal(id))
+		Expect(obj.Status.PendingOperation).Should(BeNil())
+	})
+
+	It("adopts the node pool upgrade only for the same node pool", func() {
+		poolA := "cluster-1/nodePools/pool-a"
+		poolB := "cluster-1/nodePools/pool-b"
+		poolID := m.AddRunningNodePoolOperation("cluster-1", poolA, "1.16.13-gke.404")
+
+		_, adopted, err := r.adoptOperation(ctx, obj, obj.Spec.Clusters[0], stepUpgradeNodePool+"/"+poolB, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeFalse())
+
+		_, adopted, err = r.adoptOperation(ctx, obj, obj.Spec.Clusters[0], stepUpgradeNodePool+"/"+poolA, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(adopted).Should(BeTrue())
+		Expect(obj.Status.OperationID).Should(Equal(poolID))
+		Expect(obj.Status.OperationType).Should(Equal("UPGRADE_NODE_POOL"))
+	})
+})
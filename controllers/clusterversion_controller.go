@@ -22,18 +22,34 @@ import (
 	"fmt"
 	"github.com/go-logr/logr"
 	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/notify"
 	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/remote"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/tracing"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+	"hash/fnv"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
 )
 
 const (
 	reasonOperationFailed    = "OperationFailed"
 	reasonClusterUnavailable = "ClusterUnavailable"
+	reasonNotSupported       = "NotSupported"
+	reasonVersionUnavailable = "VersionUnavailable"
 )
 
 type operationFunc func() (ctrl.Result, error)
@@ -45,10 +61,29 @@ type ClusterVersionReconciler struct {
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
 	Operator ops.Operator
+	// RecordOperations enables recording every operation as a ClusterOperation resource.
+	RecordOperations bool
+	// Notifier notifies rollout lifecycle events. Nothing is notified if it's nil.
+	Notifier notify.Notifier
+	// RemoteClient connects to the clusters directly. It's required to drain node pools.
+	RemoteClient remote.ClientsetFunc
+	// MaxConcurrentReconciles is the number of ClusterVersions reconciled in parallel. It defaults to 1.
+	MaxConcurrentReconciles int
+	// RateLimiter limits how frequently a ClusterVersion is requeued. The controller-runtime default is used if it's nil.
+	RateLimiter workqueue.RateLimiter
+	// Lifecycle closes the operation streams when the controller stops or loses the leadership. It's optional.
+	Lifecycle *ops.Lifecycle
+
+	watches *operationWatches
 }
 
 // +kubebuilder:rbac:groups=multicluster-ops.io,resources=clusterversions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=multicluster-ops.io,resources=clusterversions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=multicluster-ops.io,resources=clusteroperations,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=multicluster-ops.io,resources=clusteroperations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=multicluster-ops.io,resources=notificationpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete;deletecollection
 
 func (r *ClusterVersionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -56,43 +91,74 @@ func (r *ClusterVersionReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 	log := r.Log.WithValues("multicluster", req.NamespacedName)
 	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
 		if k8serrors.IsNotFound(err) {
+			deleteInRollout(req.NamespacedName)
+			deleteClusterMetrics(req.NamespacedName)
+			r.stopWatchingOperation(req.NamespacedName)
+			if r.Notifier != nil {
+				r.Notifier.Forget(req.NamespacedName)
+			}
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "failed to get multi cluster")
 		return ctrl.Result{}, nil
 	}
+	ctx, span := tracing.Tracer().Start(tracing.ContextWithRollout(ctx, obj.Status.RolloutTraceParent), "Reconcile",
+		trace.WithAttributes(label.String("namespace", req.Namespace), label.String("name", req.Name)),
+	)
+	defer span.End()
+	// read the status and the versions of each cluster once in a reconciliation
+	ctx = ops.WithSnapshot(ctx)
+	ctx = withStatusBase(ctx, obj)
+	r.recordFleetMetrics(ctx, obj, r.prefetchFleetStatus(ctx, obj, log), log)
 	// Actual Operations
 	if obj.Status.OperationID != "" {
+		setInRollout(req.NamespacedName, true)
+		r.watchOperation(obj, log)
 		return r.reconcileOperationStatus(ctx, obj, log)
 	}
+	r.stopWatchingOperation(req.NamespacedName)
 	return r.reconcileClusterVersion(ctx, obj, log)
 }
 
 func (r *ClusterVersionReconciler) reconcileOperationStatus(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) (ctrl.Result, error) {
-	status, err := r.Operator.GetOperationStatus(ctx, *obj)
+	detail, err := r.operationDetail(ctx, obj)
 	if err != nil {
 		log.Error(err, "failed to get operation status")
 		return ctrl.Result{}, nil
 	}
 
-	switch status {
+	switch detail.Status {
 	case ops.OperationStatusRunning:
+		if setCurrentOperation(obj, detail) {
+			return r.updateStatus(ctx, obj, log)
+		}
 		return ctrl.Result{}, nil
 	case ops.OperationStatusDone:
 		log.Info(fmt.Sprintf("(operation_id %s, operation_type %s) is done.", obj.Status.OperationID, obj.Status.OperationType))
+		setCurrentOperation(obj, detail)
 		addSuccessOperation(obj.Status.OperationType)
-		obj.Status.ResetStatus()
-		return r.updateStatus(ctx, obj, log)
+		// the nodes which haven't been replaced by the upgrade stay cordoned otherwise
+		r.uncordonNodePool(ctx, obj, log)
+		return r.completeOperation(ctx, obj, opsv1.OperationResultSucceeded, log)
 	case ops.OperationStatusFailed:
 		opID := obj.Status.OperationID
 		opType := obj.Status.OperationType
+		setCurrentOperation(obj, detail)
+		summary := obj.Status.CurrentOperation.Summary()
 		// report as an error
 		err := errors.New("operation failed")
-		log.Error(err, fmt.Sprintf("operation_id %s failed. this operation type is %s", opID, opType))
-		r.Recorder.Eventf(obj, corev1.EventTypeWarning, reasonOperationFailed, "operation_type: %s, operation_id: %s", opType, opID)
+		log.Error(err, fmt.Sprintf("operation_id %s failed. this operation type is %s", opID, opType), "detail", summary)
+		msg := "operation failed"
+		if summary != "" {
+			msg = fmt.Sprintf("operation failed: %s", summary)
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, reasonOperationFailed, "operation_type: %s, operation_id: %s, detail: %s", opType, opID, summary)
+		} else {
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, reasonOperationFailed, "operation_type: %s, operation_id: %s", opType, opID)
+		}
+		r.notify(ctx, obj, opsv1.NotificationOperationFailed, obj.Status.ClusterID, msg)
 		addFailedOperation(obj.Status.OperationType)
-		obj.Status.ResetStatus()
-		return r.updateStatus(ctx, obj, log)
+		r.uncordonNodePool(ctx, obj, log)
+		return r.completeOperation(ctx, obj, opsv1.OperationResultFailed, log)
 	case ops.OperationStatusUnknown:
 		opID := obj.Status.OperationID
 		opType := obj.Status.OperationType
@@ -106,50 +172,222 @@ func (r *ClusterVersionReconciler) reconcileOperationStatus(ctx context.Context,
 }
 
 func (r *ClusterVersionReconciler) reconcileClusterVersion(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) (ctrl.Result, error) {
-	for _, cluster := range obj.Spec.Clusters {
+	name := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+	changed, err := r.resolveVersions(ctx, obj)
+	if err != nil {
+		log.Error(err, "failed to resolve versions")
+		if errors.Is(err, ops.ErrNotSupported) {
+			msg := "the operator can't list available versions"
+			r.Recorder.Event(obj, corev1.EventTypeWarning, reasonNotSupported, msg)
+			r.notify(ctx, obj, opsv1.NotificationRolloutHalted, "", msg)
+		}
+		return ctrl.Result{}, nil
+	}
+	if changed {
+		log.Info(fmt.Sprintf("desired versions are resolved: %v", obj.Status.ResolvedVersions))
+		return r.updateStatus(ctx, obj, log)
+	}
+	for _, c := range obj.Spec.Clusters {
+		cluster := obj.DesiredCluster(c)
+		caps, err := r.capabilities(ctx, obj, cluster)
+		if err != nil {
+			log.Error(err, "failed to get capabilities")
+			return ctrl.Result{}, nil
+		}
 		cv, err := r.Operator.GetClusterVersion(ctx, *obj, cluster)
 		if err != nil {
 			log.Error(err, "get cluster version")
 			return ctrl.Result{}, nil
 		}
 		if cv.Master.Version != cluster.Version {
+			if ret, wait := r.waitForMaintenanceWindow(obj, time.Now(), log); wait {
+				return ret, nil
+			}
+			setInRollout(name, true)
+			if !caps.IsVersionAvailable(cluster.Version) {
+				msg := fmt.Sprintf("version %s isn't available for cluster %s", cluster.Version, cluster.ID)
+				log.Info(msg)
+				r.Recorder.Event(obj, corev1.EventTypeWarning, reasonVersionUnavailable, msg)
+				r.notify(ctx, obj, opsv1.NotificationRolloutHalted, cluster.ID, msg)
+				return ctrl.Result{}, nil
+			}
 			return r.reconcileMasterVersion(ctx, obj, cluster, log)
 		}
+		// the node pools are upgraded together with the master if the operator can't upgrade them separately
 		for _, pool := range cv.NodePools {
-			if pool.Version != cluster.Version {
+			if caps.UpgradeNodePool && pool.Version != cluster.Version {
+				if ret, wait := r.waitForMaintenanceWindow(obj, time.Now(), log); wait {
+					return ret, nil
+				}
+				setInRollout(name, true)
 				return r.reconcileNodePoolVersion(ctx, obj, cluster, pool.NodePoolID, log)
 			}
 		}
+		workload, err := r.findWorkloadDiff(ctx, obj, cluster)
+		if err != nil {
+			log.Error(err, "failed to get workload version")
+			if errors.Is(err, ops.ErrNotSupported) {
+				msg := "the operator can't upgrade workloads"
+				r.Recorder.Event(obj, corev1.EventTypeWarning, reasonNotSupported, msg)
+				r.notify(ctx, obj, opsv1.NotificationRolloutHalted, cluster.ID, msg)
+			}
+			return ctrl.Result{}, nil
+		}
+		if workload != nil {
+			setInRollout(name, true)
+			return r.reconcileWorkloadVersion(ctx, obj, cluster, *workload, log)
+		}
 		cs, err := r.Operator.GetClusterStatus(ctx, *obj, cluster)
 		if err != nil {
 			log.Error(err, "failed to get cluster status")
 			return ctrl.Result{}, nil
 		}
+		if cs.Type == ops.ClusterStatusServiceOut {
+			setInRollout(name, true)
+		}
 		if !cs.Available {
+			// waiting for the cluster is a part of the rollout, so it isn't notified as a halt
 			log.Info(fmt.Sprintf("cluster %s hasn't been available yet", cluster.ID))
 			return ctrl.Result{}, nil
 		}
-		if cs.Type == ops.ClusterStatusServiceOut {
-			return r.serviceIn(ctx, obj, cluster, log)
+		if cs.Type == ops.ClusterStatusServiceOut || obj.Status.IsUpgradedInPlace(cluster.ID) {
+			return r.withHooks(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+				return r.withVerification(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+					return r.withHooks(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+						if cs.Type == ops.ClusterStatusServiceOut {
+							return r.serviceIn(ctx, obj, cluster, log)
+						}
+						return r.completeInPlaceUpgrade(ctx, obj, cluster, log)
+					}, opsv1.HookPreServiceIn)
+				})
+			}, opsv1.HookPostUpgrade)
 		}
 	}
+	setInRollout(name, false)
+	if obj.Status.RolloutStartTime != nil {
+		return r.completeRollout(ctx, obj, log)
+	}
 	return ctrl.Result{}, nil
 }
 
+// findWorkloadDiff returns the first workload whose version differs from the desired one in the cluster.
+func (r *ClusterVersionReconciler) findWorkloadDiff(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster) (*opsv1.Workload, error) {
+	if len(obj.Spec.Workloads) == 0 {
+		return nil, nil
+	}
+	wo, err := r.workloadOperator()
+	if err != nil {
+		return nil, err
+	}
+	for i, w := range obj.Spec.Workloads {
+		version, err := wo.GetWorkloadVersion(ctx, *obj, cluster, w)
+		if err != nil {
+			return nil, err
+		}
+		if version != w.Version {
+			return &obj.Spec.Workloads[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *ClusterVersionReconciler) workloadOperator() (ops.WorkloadOperator, error) {
+	wo, ok := r.Operator.(ops.WorkloadOperator)
+	if !ok {
+		return nil, ops.ErrNotSupported
+	}
+	return wo, nil
+}
+
+// capabilities returns the capabilities of the operator for the cluster, or the default ones if the operator doesn't report them.
+func (r *ClusterVersionReconciler) capabilities(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster) (*ops.Capabilities, error) {
+	co, ok := r.Operator.(ops.CapabilitiesOperator)
+	if !ok {
+		return ops.DefaultCapabilities(), nil
+	}
+	caps, err := co.GetCapabilities(ctx, *obj, cluster)
+	if errors.Is(err, ops.ErrNotSupported) {
+		return ops.DefaultCapabilities(), nil
+	}
+	return caps, err
+}
+
+// prefetchFleetStatus reads the versions and the statuses of all clusters in one call if the operator supports it,
+// and returns true if they have been read.
+// They're kept in the snapshot of the reconciliation by the caching Operator, and the clusters missing in the result
+// are read one by one as before.
+func (r *ClusterVersionReconciler) prefetchFleetStatus(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) bool {
+	fo, ok := r.Operator.(ops.FleetStatusOperator)
+	if !ok {
+		return false
+	}
+	_, err := fo.GetFleetStatus(ctx, *obj, obj.Spec.Clusters)
+	if err != nil {
+		if !errors.Is(err, ops.ErrNotSupported) {
+			log.Error(err, "failed to get fleet status")
+		}
+		return false
+	}
+	return true
+}
+
+// recordFleetMetrics records the versions and the statuses of all clusters in the metrics before any cluster is
+// reconciled, so that the clusters other than the one being upgraded are exported too, while an operation is running
+// as well. The clusters are read from the snapshot if the fleet has been prefetched, otherwise they're read one by one
+// at most once per fleetMetricsInterval. The clusters which can't be read are left as they were.
+func (r *ClusterVersionReconciler) recordFleetMetrics(ctx context.Context, obj *opsv1.ClusterVersion, prefetched bool, log logr.Logger) {
+	name := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+	retainClusterMetrics(name, obj.Spec.Clusters)
+	if !prefetched && !shouldRecordFleet(name, time.Now()) {
+		return
+	}
+	for _, cluster := range obj.Spec.Clusters {
+		cv, err := r.Operator.GetClusterVersion(ctx, *obj, cluster)
+		if err != nil {
+			log.Error(err, "get cluster version", "cluster", cluster.ID)
+			continue
+		}
+		setClusterVersion(name, cluster.ID, cv)
+		cs, err := r.Operator.GetClusterStatus(ctx, *obj, cluster)
+		if err != nil {
+			log.Error(err, "failed to get cluster status", "cluster", cluster.ID)
+			continue
+		}
+		setClusterServicedOut(name, cluster.ID, cs)
+	}
+}
+
+// withServiceOut performs the operation after the cluster has been serviced out.
+// If the operator can't service out the cluster, the operation is performed while the cluster is serviced in.
 func (r *ClusterVersionReconciler) withServiceOut(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger, op operationFunc) (ctrl.Result, error) {
+	caps, err := r.capabilities(ctx, obj, cluster)
+	if err != nil {
+		log.Error(err, "failed to get capabilities")
+		return ctrl.Result{}, nil
+	}
+	if !caps.ServiceOut {
+		// the post-upgrade steps run after the upgrade as if the cluster had been serviced out.
+		// it's recorded with the operation which op starts
+		obj.Status.SetUpgradedInPlace(cluster.ID, true)
+		return op()
+	}
 	cs, err := r.Operator.GetClusterStatus(ctx, *obj, cluster)
 	if err != nil {
 		log.Error(err, "failed to get cluster status")
 		return ctrl.Result{}, nil
 	}
+	setClusterServicedOut(types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, cluster.ID, cs)
 	if cs.Type == ops.ClusterStatusServiceOut {
 		return op()
 	}
 	if r.canServiceOut(ctx, obj, cluster) {
-		return r.serviceOut(ctx, obj, cluster, log)
+		return r.withHooks(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+			return r.serviceOut(ctx, obj, cluster, log)
+		}, opsv1.HookPreServiceOut)
 	}
 	// report as an warning event
 	msg := fmt.Sprintf("can't service out. currently available clusters less than required available count: %d", obj.Spec.RequiredAvailableCount)
+	// it proceeds once the other clusters are available again, so it isn't notified as a halt
 	r.Recorder.Event(obj, corev1.EventTypeWarning, reasonClusterUnavailable, msg)
 	return ctrl.Result{}, nil
 }
@@ -161,6 +399,9 @@ func (r *ClusterVersionReconciler) canServiceOut(ctx context.Context, obj *opsv1
 			continue
 		}
 		cs, err := r.Operator.GetClusterStatus(ctx, *obj, c)
+		if err == nil {
+			setClusterServicedOut(types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, c.ID, cs)
+		}
 		if err == nil && cs.Available {
 			availableCount += 1
 		}
@@ -171,76 +412,297 @@ func (r *ClusterVersionReconciler) canServiceOut(ctx context.Context, obj *opsv1
 	return false
 }
 
+// withHooks performs the operation after all the hooks have succeeded.
+func (r *ClusterVersionReconciler) withHooks(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger, op operationFunc, hooks ...opsv1.HookType) (ctrl.Result, error) {
+	for _, hook := range hooks {
+		done, err := r.runHook(ctx, obj, cluster, hook, log)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("failed to run %s hook", hook))
+			return ctrl.Result{}, nil
+		}
+		if !done {
+			return ctrl.Result{}, nil
+		}
+	}
+	return op()
+}
+
 func (r *ClusterVersionReconciler) reconcileMasterVersion(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger) (ctrl.Result, error) {
 	return r.withServiceOut(ctx, obj, cluster, log, func() (result ctrl.Result, err error) {
-		return r.upgradeMaster(ctx, obj, cluster, log)
+		return r.withHooks(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+			return r.upgradeMaster(ctx, obj, cluster, log)
+		}, opsv1.HookPreUpgrade)
 	})
 }
 
 func (r *ClusterVersionReconciler) reconcileNodePoolVersion(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string, log logr.Logger) (ctrl.Result, error) {
 	return r.withServiceOut(ctx, obj, cluster, log, func() (result ctrl.Result, err error) {
-		return r.upgradeNodePool(ctx, obj, cluster, nodePoolID, log)
+		return r.withHooks(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+			return r.withDrain(ctx, obj, cluster, nodePoolID, log, func() (ctrl.Result, error) {
+				return r.upgradeNodePool(ctx, obj, cluster, nodePoolID, log)
+			})
+		}, opsv1.HookPreUpgrade)
+	})
+}
+
+func (r *ClusterVersionReconciler) reconcileWorkloadVersion(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload, log logr.Logger) (ctrl.Result, error) {
+	return r.withServiceOut(ctx, obj, cluster, log, func() (result ctrl.Result, err error) {
+		return r.withHooks(ctx, obj, cluster, log, func() (ctrl.Result, error) {
+			return r.upgradeWorkload(ctx, obj, cluster, workload, log)
+		}, opsv1.HookPreUpgrade)
 	})
 }
 
 func (r *ClusterVersionReconciler) serviceIn(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger) (ctrl.Result, error) {
+	if ret, adopted, err := r.adoptOperation(ctx, obj, cluster, stepServiceIn, log); adopted {
+		return ret, err
+	}
+	ctx, err := r.requestOperation(ctx, obj, cluster, stepServiceIn, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	result, err := r.Operator.ServiceIn(ctx, *obj, cluster)
 	if err != nil {
 		log.Error(err, "failed to service in")
 		return ctrl.Result{}, nil
 	}
-	obj.Status.ClusterID = cluster.ID
-	obj.Status.OperationID = result.OperationID
-	obj.Status.OperationType = result.OperationType
+	return r.startOperation(ctx, obj, cluster, result, log)
+}
+
+// completeInPlaceUpgrade moves on to the next cluster after the post-upgrade steps of the cluster upgraded without servicing out.
+func (r *ClusterVersionReconciler) completeInPlaceUpgrade(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger) (ctrl.Result, error) {
+	log.Info(fmt.Sprintf("cluster %s has been upgraded without servicing out", cluster.ID))
+	obj.Status.SetUpgradedInPlace(cluster.ID, false)
 	return r.updateStatus(ctx, obj, log)
 }
 
 func (r *ClusterVersionReconciler) serviceOut(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger) (ctrl.Result, error) {
+	if ret, adopted, err := r.adoptOperation(ctx, obj, cluster, stepServiceOut, log); adopted {
+		return ret, err
+	}
+	ctx, err := r.requestOperation(ctx, obj, cluster, stepServiceOut, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	result, err := r.Operator.ServiceOut(ctx, *obj, cluster)
 	if err != nil {
 		log.Error(err, "failed to service out")
 		return ctrl.Result{}, nil
 	}
-	obj.Status.ClusterID = cluster.ID
-	obj.Status.OperationID = result.OperationID
-	obj.Status.OperationType = result.OperationType
-	return r.updateStatus(ctx, obj, log)
+	ret, err := r.startOperation(ctx, obj, cluster, result, log)
+	if err != nil {
+		return ret, err
+	}
+	r.notify(ctx, obj, opsv1.NotificationServiceOut, cluster.ID, "cluster is serviced out")
+	return ret, nil
 }
 
 func (r *ClusterVersionReconciler) upgradeMaster(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger) (ctrl.Result, error) {
+	if ret, adopted, err := r.adoptOperation(ctx, obj, cluster, stepUpgradeMaster, log); adopted {
+		return ret, err
+	}
+	ctx, err := r.requestOperation(ctx, obj, cluster, stepUpgradeMaster, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	result, err := r.Operator.UpgradeMaster(ctx, *obj, cluster)
 	if err != nil {
 		log.Error(err, "failed to upgrade master")
 		return ctrl.Result{}, nil
 	}
-	obj.Status.ClusterID = cluster.ID
-	obj.Status.OperationID = result.OperationID
-	obj.Status.OperationType = result.OperationType
-	return r.updateStatus(ctx, obj, log)
+	return r.startOperation(ctx, obj, cluster, result, log)
 }
 
 func (r *ClusterVersionReconciler) upgradeNodePool(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string, log logr.Logger) (ctrl.Result, error) {
+	step := fmt.Sprintf("%s/%s", stepUpgradeNodePool, nodePoolID)
+	if ret, adopted, err := r.adoptOperation(ctx, obj, cluster, step, log); adopted {
+		return ret, err
+	}
+	ctx, err := r.requestOperation(ctx, obj, cluster, step, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 	result, err := r.Operator.UpgradeNodePool(ctx, *obj, cluster, nodePoolID)
 	if err != nil {
 		log.Error(err, "failed to upgrade node pool")
 		return ctrl.Result{}, nil
 	}
+	return r.startOperation(ctx, obj, cluster, result, log)
+}
+
+func (r *ClusterVersionReconciler) upgradeWorkload(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload, log logr.Logger) (ctrl.Result, error) {
+	wo, err := r.workloadOperator()
+	if err != nil {
+		log.Error(err, "failed to upgrade workload")
+		return ctrl.Result{}, nil
+	}
+	step := fmt.Sprintf("%s/%s", stepUpgradeWorkload, workload.Name)
+	if ret, adopted, err := r.adoptOperation(ctx, obj, cluster, step, log); adopted {
+		return ret, err
+	}
+	ctx, err = r.requestOperation(ctx, obj, cluster, step, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	result, err := wo.UpgradeWorkload(ctx, *obj, cluster, workload)
+	if err != nil {
+		log.Error(err, "failed to upgrade workload")
+		return ctrl.Result{}, nil
+	}
+	return r.startOperation(ctx, obj, cluster, result, log)
+}
+
+func (r *ClusterVersionReconciler) startOperation(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, result *ops.OperationResult, log logr.Logger) (ctrl.Result, error) {
+	now := metav1.Now()
+	rolloutStarted := obj.Status.RolloutStartTime == nil
 	obj.Status.ClusterID = cluster.ID
 	obj.Status.OperationID = result.OperationID
 	obj.Status.OperationType = result.OperationType
+	obj.Status.OperationStartTime = &now
+	obj.Status.PendingOperation = nil
+	obj.Status.OperationCount++
+	obj.Status.CurrentOperation = nil
+	if result.Message != "" {
+		obj.Status.CurrentOperation = &opsv1.CurrentOperation{
+			Message:        result.Message,
+			LastUpdateTime: now,
+		}
+	}
+	if rolloutStarted {
+		obj.Status.RolloutStartTime = &now
+		obj.Status.RolloutTraceParent = tracing.NewRolloutTraceParent()
+	}
+	ret, err := r.updateStatus(ctx, obj, log)
+	if err != nil {
+		return ret, err
+	}
+	if r.RecordOperations {
+		r.recordOperation(ctx, obj, opsv1.ClusterOperationRunning, nil, log)
+	}
+	if rolloutStarted {
+		r.notify(ctx, obj, opsv1.NotificationRolloutStarted, cluster.ID, "rollout started")
+	}
+	return ret, nil
+}
+
+func (r *ClusterVersionReconciler) completeRollout(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) (ctrl.Result, error) {
+	started := obj.Status.RolloutStartTime.Time
+	traceParent := obj.Status.RolloutTraceParent
+	obj.Status.RolloutStartTime = nil
+	obj.Status.RolloutTraceParent = ""
+	obj.Status.InPlaceUpgrades = nil
+	ret, err := r.updateStatus(ctx, obj, log)
+	if err != nil {
+		return ret, err
+	}
+	if obj.Spec.Hooks != nil {
+		if err := r.deleteHookJobs(ctx, obj); err != nil {
+			log.Error(err, "failed to delete hook jobs")
+		}
+	}
+	tracing.EndRollout(ctx, traceParent, started, label.String("namespace", obj.Namespace), label.String("name", obj.Name))
+	msg := fmt.Sprintf("all clusters have reached the desired versions in %s", time.Since(started).Round(time.Second))
+	log.Info(msg)
+	r.notify(ctx, obj, opsv1.NotificationRolloutCompleted, "", msg)
+	return ret, nil
+}
+
+func (r *ClusterVersionReconciler) notify(ctx context.Context, obj *opsv1.ClusterVersion, t opsv1.NotificationEventType, clusterID string, msg string) {
+	if r.Notifier == nil {
+		return
+	}
+	r.Notifier.Notify(ctx, *obj, notify.Event{
+		Type:          t,
+		Namespace:     obj.Namespace,
+		Name:          obj.Name,
+		ClusterID:     clusterID,
+		OperationID:   obj.Status.OperationID,
+		OperationType: obj.Status.OperationType,
+		Message:       msg,
+		Time:          time.Now(),
+	})
+}
+
+func (r *ClusterVersionReconciler) completeOperation(ctx context.Context, obj *opsv1.ClusterVersion, result opsv1.OperationResultType, log logr.Logger) (ctrl.Result, error) {
+	now := metav1.Now()
+	if obj.Status.OperationStartTime != nil {
+		observeOperationDuration(obj.Status.OperationType, obj.Status.ClusterID, string(result), now.Sub(obj.Status.OperationStartTime.Time))
+	}
+	if r.RecordOperations {
+		phase := opsv1.ClusterOperationSucceeded
+		if result == opsv1.OperationResultFailed {
+			phase = opsv1.ClusterOperationFailed
+		}
+		r.recordOperation(ctx, obj, phase, &now, log)
+	}
+	obj.Status.RecordHistory(result, now, obj.Spec.GetHistoryLimit())
+	obj.Status.ResetStatus()
 	return r.updateStatus(ctx, obj, log)
 }
 
-func (r *ClusterVersionReconciler) updateStatus(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) (ctrl.Result, error) {
-	if err := r.Client.Status().Update(ctx, obj); err != nil {
-		log.Error(err, "failed to update status")
-		return ctrl.Result{}, err
+// recordOperation creates or updates the ClusterOperation for the running operation.
+// The record is for auditing, so failing to write it doesn't block the operations.
+func (r *ClusterVersionReconciler) recordOperation(ctx context.Context, obj *opsv1.ClusterVersion, phase opsv1.ClusterOperationPhase, endTime *metav1.Time, log logr.Logger) {
+	op := &opsv1.ClusterOperation{}
+	key := client.ObjectKey{Namespace: obj.Namespace, Name: clusterOperationName(obj)}
+	err := r.Get(ctx, key, op)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Error(err, "failed to get cluster operation")
+		return
 	}
-	return ctrl.Result{}, nil
+	if k8serrors.IsNotFound(err) {
+		op = &opsv1.ClusterOperation{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+			},
+			Spec: opsv1.ClusterOperationSpec{
+				ClusterVersion: obj.Name,
+				ClusterID:      obj.Status.ClusterID,
+				OperationID:    obj.Status.OperationID,
+				OperationType:  obj.Status.OperationType,
+			},
+		}
+		if err := ctrl.SetControllerReference(obj, op, r.Scheme); err != nil {
+			log.Error(err, "failed to set owner reference to cluster operation")
+			return
+		}
+		if err := r.Create(ctx, op); err != nil {
+			log.Error(err, "failed to create cluster operation")
+			return
+		}
+	}
+	op.Status.Phase = phase
+	op.Status.StartTime = obj.Status.OperationStartTime
+	op.Status.EndTime = endTime
+	if err := r.Status().Update(ctx, op); err != nil {
+		log.Error(err, "failed to update cluster operation status")
+	}
+}
+
+// clusterOperationName returns the deterministic name of the ClusterOperation for the running operation.
+// Operation IDs depend on the provider, so they're hashed to be a valid resource name.
+func clusterOperationName(obj *opsv1.ClusterVersion) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(obj.Status.ClusterID + "/" + obj.Status.OperationID))
+	return fmt.Sprintf("%s-%08x", obj.Name, h.Sum32())
 }
 
 func (r *ClusterVersionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.watches = newOperationWatches()
+	if r.Lifecycle != nil {
+		r.Lifecycle.OnStart(r.watches.start)
+		r.Lifecycle.OnStop(r.watches.stop)
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&opsv1.ClusterVersion{}).
+		Owns(&opsv1.ClusterOperation{}).
+		Owns(&batchv1.Job{}).
+		// reconcile as soon as the status of the watched operation changes
+		Watches(&source.Channel{Source: r.watches.events}, &handler.EnqueueRequestForObject{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+			RateLimiter:             r.RateLimiter,
+		}).
 		Complete(r)
 }
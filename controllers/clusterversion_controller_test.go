@@ -2,11 +2,19 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
 	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
 )
 
 func makeClusterVersion(namespace, name string) *opsv1.ClusterVersion {
@@ -120,10 +128,461 @@ var _ = Describe("Multi Cluster Reconciler", func() {
 
 				By("[check] complete servicein for second cluster")
 				Eventually(operator.HasServiceIn(mc.Spec.Clusters[1].ID)).Should(Equal(true))
+
+				By("[check] all operations are recorded in history")
+				Eventually(func() error {
+					obj := &opsv1.ClusterVersion{}
+					if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: mcName}, obj); err != nil {
+						return err
+					}
+					if len(obj.Status.History) != checkOperationAt+1 {
+						return fmt.Errorf("history length is %d", len(obj.Status.History))
+					}
+					latest := obj.Status.History[0]
+					if latest.OperationType != "SERVICE_IN" || latest.ClusterID != mc.Spec.Clusters[1].ID || latest.Result != opsv1.OperationResultSucceeded {
+						return fmt.Errorf("unexpected latest history: %#v", latest)
+					}
+					return nil
+				}).Should(Succeed())
+
+				By("[check] every operation is requested with its own idempotency key")
+				keys := map[string]bool{}
+				for _, key := range operator.IdempotencyKeys(mcName) {
+					Expect(key).ShouldNot(BeEmpty())
+					keys[key] = true
+				}
+				Expect(keys).Should(HaveLen(checkOperationAt + 1))
+
+				By("[check] all operations are recorded as cluster operations")
+				Eventually(func() error {
+					list := &opsv1.ClusterOperationList{}
+					if err := k8sClient.List(ctx, list, client.InNamespace(mcNamespace)); err != nil {
+						return err
+					}
+					count := 0
+					for _, op := range list.Items {
+						if op.Spec.ClusterVersion != mcName {
+							continue
+						}
+						if op.Status.Phase != opsv1.ClusterOperationSucceeded {
+							return fmt.Errorf("operation %s is %s", op.Name, op.Status.Phase)
+						}
+						count += 1
+					}
+					if count != checkOperationAt+1 {
+						return fmt.Errorf("cluster operations count is %d", count)
+					}
+					return nil
+				}).Should(Succeed())
 			})
 		})
 	})
 
+	Context("workload cases", func() {
+		It("upgrade workloads after node pools in the service out window", func() {
+			var mcName = "test-clusters-workload-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+			mc.Spec.Workloads = []opsv1.Workload{{Name: "istio", Version: "1.8.1"}}
+
+			By("[prepare] mock operation")
+			current := makeCurrentResourceDifferentState(*mc)
+			current[1].Master.Version = "1.16.13-gke.404"
+			current[1].NodePools = nil
+			operator.AddClusterVersion(current...)
+			operator.AddWorkloadVersion(mc.Spec.Clusters[0].ID, "istio", "1.7.5")
+			operator.AddWorkloadVersion(mc.Spec.Clusters[1].ID, "istio", "1.8.1")
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the workload is upgraded after the node pools")
+			for i, op := range []string{"SERVICE_OUT", "UPGRADE_MASTER", "UPGRADE_NODE_POOL", "UPGRADE_NODE_POOL", "UPGRADE_WORKLOAD"} {
+				Eventually(operator.HasExecutedAt(i, op, mcName)).Should(Equal(true))
+			}
+			Expect(operator.HasServiceOut(mc.Spec.Clusters[0].ID)()).Should(Equal(true))
+
+			By("[check] service in after the workload is upgraded")
+			Eventually(operator.HasExecutedAt(5, "SERVICE_IN", mcName)).Should(Equal(true))
+			Eventually(operator.HasServiceIn(mc.Spec.Clusters[0].ID)).Should(Equal(true))
+			Consistently(func() int {
+				return len(operator.executedOperations[mcName])
+			}).Should(Equal(6))
+		})
+	})
+
+	Context("drain cases", func() {
+		It("wait for the node pool to be drained before upgrading it", func() {
+			var mcName = "test-clusters-drain-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+			mc.Spec.Drain = &opsv1.DrainPolicy{NodePoolLabel: "test-clusters-drain-1/pool"}
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+
+			By("[prepare] nodes of the first node pool with a pod protected by the disruption budget")
+			remoteClient := remoteClients.Get(mc.Spec.Clusters[0].ID)
+			node := &corev1.Node{}
+			node.Name = "drain-node-1"
+			node.Labels = map[string]string{mc.Spec.Drain.NodePoolLabel: "node-pool-1"}
+			_, err := remoteClient.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			pod := &corev1.Pod{}
+			pod.Namespace = "default"
+			pod.Name = "drain-protected"
+			pod.Labels = map[string]string{labelProtected: ""}
+			pod.OwnerReferences = replicaSetOwner()
+			pod.Spec.NodeName = node.Name
+			_, err = remoteClient.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[prepare] create a multicluster resource")
+			err = k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the node pool isn't upgraded while the pod remains")
+			Eventually(operator.HasExecutedAt(1, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+			Eventually(func() ([]opsv1.NodeDrainStatus, error) {
+				obj := &opsv1.ClusterVersion{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: mcName}, obj); err != nil || obj.Status.Drain == nil {
+					return nil, err
+				}
+				return obj.Status.Drain.Nodes, nil
+			}).Should(Equal([]opsv1.NodeDrainStatus{{Name: node.Name, RemainingPods: 1}}))
+			Consistently(operator.LastExecutedOperationIs("UPGRADE_MASTER", mcName)).Should(Equal(true))
+			cordoned, err := remoteClient.CoreV1().Nodes().Get(ctx, node.Name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cordoned.Spec.Unschedulable).Should(Equal(true))
+
+			By("[prepare] the pod is gone")
+			err = remoteClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] upgrade the node pool after draining")
+			Eventually(operator.HasExecutedAt(2, "UPGRADE_NODE_POOL", mcName)).Should(Equal(true))
+		})
+	})
+
+	Context("verification cases", func() {
+		It("wait for the nodes to be ready at the desired version before service in", func() {
+			var mcName = "test-clusters-verification-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+			mc.Spec.Verification = &opsv1.VerificationPolicy{Namespaces: []string{"kube-system"}}
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+
+			By("[prepare] a node of the first cluster at the old version")
+			remoteClient := remoteClients.Get(mc.Spec.Clusters[0].ID)
+			node := &corev1.Node{}
+			node.Name = "verification-node-1"
+			node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+			node.Status.NodeInfo.KubeletVersion = "v1.16.13-gke.different"
+			_, err := remoteClient.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[prepare] create a multicluster resource")
+			err = k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the cluster isn't serviced in until it's verified")
+			Eventually(operator.HasExecutedAt(3, "UPGRADE_NODE_POOL", mcName)).Should(Equal(true))
+			Eventually(func() (string, error) {
+				obj := &opsv1.ClusterVersion{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: mcName}, obj); err != nil {
+					return "", err
+				}
+				c := meta.FindStatusCondition(obj.Status.Conditions, opsv1.ConditionPostUpgradeVerified)
+				if c == nil || c.Status != metav1.ConditionFalse {
+					return "", nil
+				}
+				return c.Reason, nil
+			}).Should(Equal("NodeNotReady"))
+			Consistently(operator.LastExecutedOperationIs("UPGRADE_NODE_POOL", mcName)).Should(Equal(true))
+
+			By("[prepare] the node is upgraded")
+			node.Status.NodeInfo.KubeletVersion = "v1.16.13-gke.404"
+			_, err = remoteClient.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] service in after the verification")
+			Eventually(operator.HasExecutedAt(4, "SERVICE_IN", mcName)).Should(Equal(true))
+		})
+	})
+
+	Context("hook cases", func() {
+		It("wait for the hook job to succeed before service out", func() {
+			var mcName = "test-clusters-hook-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+			mc.Spec.Hooks = &opsv1.Hooks{
+				PreServiceOut: &runtime.RawExtension{
+					Raw: []byte(`{"spec":{"template":{"spec":{"restartPolicy":"Never","containers":[{"name":"backup","image":"busybox"}]}}}}`),
+				},
+			}
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the hook job is created")
+			job := &batchv1.Job{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: hookJobName(mc, mc.Spec.Clusters[0], opsv1.HookPreServiceOut)}, job)
+			}).Should(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: "CLUSTER_ID", Value: mc.Spec.Clusters[0].ID}))
+
+			By("[check] operations won't start until the hook job succeeds")
+			Consistently(func() bool {
+				return len(operator.executedOperations[mc.Name]) == 0
+			}).Should(Equal(true))
+
+			By("[prepare] make the hook job succeed")
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, job)).Should(Succeed())
+
+			By("[check] start service out for first cluster")
+			Eventually(operator.HasExecutedAt(0, "SERVICE_OUT", mcName)).Should(Equal(true))
+		})
+	})
+
+	Context("capability cases", func() {
+		It("upgrade only masters without service out when the operator can't", func() {
+			var mcName = "test-clusters-capability-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			for _, c := range mc.Spec.Clusters {
+				operator.AddCapabilities(c.ID, &ops.Capabilities{ProtocolVersion: ops.ProtocolVersionV1})
+			}
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] upgrade the masters of both clusters")
+			Eventually(operator.HasExecutedAt(0, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+			Eventually(operator.HasExecutedAt(1, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+			Consistently(func() int {
+				return len(operator.executedOperations[mcName])
+			}).Should(Equal(2))
+		})
+
+		It("run the post-upgrade hook after upgrading without service out", func() {
+			var mcName = "test-clusters-capability-3"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+			mc.Spec.Hooks = &opsv1.Hooks{
+				PostUpgrade: &runtime.RawExtension{
+					Raw: []byte(`{"spec":{"template":{"spec":{"restartPolicy":"Never","containers":[{"name":"smoke-test","image":"busybox"}]}}}}`),
+				},
+			}
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			for _, c := range mc.Spec.Clusters {
+				operator.AddCapabilities(c.ID, &ops.Capabilities{ProtocolVersion: ops.ProtocolVersionV1})
+			}
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the hook job is created after the first cluster is upgraded")
+			Eventually(operator.HasExecutedAt(0, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+			job := &batchv1.Job{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: hookJobName(mc, mc.Spec.Clusters[0], opsv1.HookPostUpgrade)}, job)
+			}).Should(Succeed())
+
+			By("[check] the next cluster isn't upgraded until the hook job succeeds")
+			Consistently(func() int {
+				return len(operator.executedOperations[mcName])
+			}).Should(Equal(1))
+
+			By("[prepare] make the hook job succeed")
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, job)).Should(Succeed())
+
+			By("[check] upgrade the next cluster")
+			Eventually(operator.HasExecutedAt(1, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+		})
+
+		It("wouldn't upgrade to the version which isn't available", func() {
+			var mcName = "test-clusters-capability-2"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			caps := ops.DefaultCapabilities()
+			caps.AvailableVersions = []string{"1.17.14-gke.400"}
+			operator.AddCapabilities(mc.Spec.Clusters[0].ID, caps)
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] no operations are performed")
+			Consistently(func() int {
+				return len(operator.executedOperations[mcName])
+			}).Should(Equal(0))
+		})
+	})
+
+	Context("watch cases", func() {
+		It("upgrade with the operation status streamed by the operator", func() {
+			var mcName = "test-clusters-watch-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			operator.EnableWatch(mcName)
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the operations are watched until all clusters are serviced in")
+			Eventually(operator.HasExecutedAt(0, "SERVICE_OUT", mcName)).Should(Equal(true))
+			Eventually(operator.HasExecutedAt(1, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+			Eventually(operator.HasExecutedAt(9, "SERVICE_IN", mcName)).Should(Equal(true))
+			Eventually(operator.HasServiceIn(mc.Spec.Clusters[1].ID)).Should(Equal(true))
+			Expect(operator.WatchedCount(mcName)()).Should(BeNumerically(">=", 2))
+		})
+	})
+
+	Context("version policy cases", func() {
+		It("upgrade to the latest patch of the minor version", func() {
+			var mcName = "test-clusters-version-policy-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+			mc.Spec.VersionPolicy = &opsv1.VersionPolicy{Mode: opsv1.VersionPolicyLatestPatch}
+			for i := range mc.Spec.Clusters {
+				mc.Spec.Clusters[i].Version = "1.16"
+			}
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			for _, c := range mc.Spec.Clusters {
+				operator.AddAvailableVersions(c.ID, "1.16.13-gke.404", "1.16.15-gke.4301", "1.17.14-gke.400")
+			}
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the versions are resolved in status")
+			Eventually(func() ([]opsv1.ResolvedVersion, error) {
+				obj := &opsv1.ClusterVersion{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: mcName}, obj); err != nil {
+					return nil, err
+				}
+				return obj.Status.ResolvedVersions, nil
+			}).Should(Equal([]opsv1.ResolvedVersion{
+				{ClusterID: mc.Spec.Clusters[0].ID, Version: "1.16.15-gke.4301"},
+				{ClusterID: mc.Spec.Clusters[1].ID, Version: "1.16.15-gke.4301"},
+			}))
+
+			By("[check] upgrade master to the resolved version")
+			Eventually(operator.HasExecutedAt(1, "UPGRADE_MASTER", mcName)).Should(Equal(true))
+			Eventually(func() (string, error) {
+				cv, err := operator.GetClusterVersion(ctx, *mc, mc.Spec.Clusters[0])
+				if err != nil {
+					return "", err
+				}
+				return cv.Master.Version, nil
+			}).Should(Equal("1.16.15-gke.4301"))
+		})
+	})
+
+	Context("operation detail cases", func() {
+		It("record the error detail of the failed operation", func() {
+			var mcName = "test-clusters-detail-1"
+			var mcNamespace = "default"
+			var detail = "Insufficient regional quota to satisfy request"
+			mc := makeClusterVersion(mcNamespace, mcName)
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			operator.FailMasterUpgrade(mc.Spec.Clusters[0].ID, detail)
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the error detail is recorded in history")
+			Eventually(func() error {
+				obj := &opsv1.ClusterVersion{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: mcName}, obj); err != nil {
+					return err
+				}
+				for _, h := range obj.Status.History {
+					if h.OperationType == "UPGRADE_MASTER" && h.Result == opsv1.OperationResultFailed && h.Message == detail {
+						return nil
+					}
+				}
+				return fmt.Errorf("failed operation isn't recorded: %#v", obj.Status.History)
+			}).Should(Succeed())
+
+			By("[check] the error detail is in the event")
+			Eventually(func() error {
+				list := &corev1.EventList{}
+				if err := k8sClient.List(ctx, list, client.InNamespace(mcNamespace)); err != nil {
+					return err
+				}
+				for _, e := range list.Items {
+					if e.InvolvedObject.Name == mcName && e.Reason == reasonOperationFailed && strings.Contains(e.Message, detail) {
+						return nil
+					}
+				}
+				return errors.New("event isn't found")
+			}).Should(Succeed())
+		})
+	})
+
+	Context("adoption cases", func() {
+		It("adopt the operation whose response is lost instead of starting another one", func() {
+			var mcName = "test-clusters-adoption-1"
+			var mcNamespace = "default"
+			mc := makeClusterVersion(mcNamespace, mcName)
+
+			By("[prepare] mock operation")
+			operator.AddClusterVersion(makeCurrentResourceDifferentState(*mc)...)
+			adoptedID := operator.LoseMasterUpgradeResponse(mc.Spec.Clusters[0].ID)
+
+			By("[prepare] create a multicluster resource")
+			err := k8sClient.Create(ctx, mc)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("[check] the running operation is recorded in history")
+			Eventually(func() error {
+				obj := &opsv1.ClusterVersion{}
+				if err := k8sClient.Get(ctx, client.ObjectKey{Namespace: mcNamespace, Name: mcName}, obj); err != nil {
+					return err
+				}
+				for _, h := range obj.Status.History {
+					if h.OperationID == adoptedID && h.Result == opsv1.OperationResultSucceeded {
+						return nil
+					}
+				}
+				return fmt.Errorf("adopted operation isn't recorded: %#v", obj.Status.History)
+			}).Should(Succeed())
+
+			By("[check] the master of the first cluster isn't upgraded again")
+			Eventually(operator.HasExecutedAt(1, "UPGRADE_NODE_POOL", mcName)).Should(Equal(true))
+		})
+	})
+
 	Context("exception cases", func() {
 		It("when the cluster is unavailable, wouldn't service in", func() {
 			var mcName = "test-clusters-exception-1"
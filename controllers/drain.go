@@ -0,0 +1,129 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/drain"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+	"time"
+)
+
+const (
+	reasonDrainTimeout  = "DrainTimeout"
+	reasonNodesNotFound = "NodesNotFound"
+)
+
+// withDrain performs the operation after all nodes of the node pool have been drained if spec.drain is defined.
+// The progress is recorded in status.drain whenever it changes until the pods are gone.
+// If the operation can't be requested, the nodes are uncordoned so that they don't stay unschedulable.
+func (r *ClusterVersionReconciler) withDrain(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string, log logr.Logger, op operationFunc) (ctrl.Result, error) {
+	policy := obj.Spec.Drain
+	if policy == nil {
+		return op()
+	}
+	if r.RemoteClient == nil {
+		log.Error(errors.New("remote client isn't configured"), "failed to drain node pool")
+		return ctrl.Result{}, nil
+	}
+	client, err := r.RemoteClient(ctx, *obj, cluster)
+	if err != nil {
+		log.Error(err, "failed to connect to cluster")
+		return ctrl.Result{}, nil
+	}
+	nodes, err := drain.New(client, policy.GetNodePoolLabel()).Drain(ctx, nodePoolName(nodePoolID))
+	if err != nil {
+		log.Error(err, "failed to drain node pool")
+		return ctrl.Result{}, nil
+	}
+
+	st := obj.Status.Drain
+	changed := false
+	if st == nil || st.ClusterID != cluster.ID || st.NodePoolID != nodePoolID {
+		changed = true
+		st = &opsv1.DrainStatus{
+			ClusterID:  cluster.ID,
+			NodePoolID: nodePoolID,
+			StartTime:  metav1.Now(),
+		}
+		obj.Status.Drain = st
+		if len(nodes) == 0 {
+			r.Recorder.Eventf(obj, corev1.EventTypeWarning, reasonNodesNotFound, "no nodes of node pool %s have the label %s", nodePoolID, policy.GetNodePoolLabel())
+		}
+	}
+	if !reflect.DeepEqual(st.Nodes, nodes) {
+		st.Nodes = nodes
+		changed = true
+	}
+	if st.Drained() {
+		log.Info(fmt.Sprintf("node pool %s has been drained", nodePoolID))
+		ret, err := op()
+		if obj.Status.OperationID == "" {
+			r.uncordonNodePool(ctx, obj, log)
+		}
+		return ret, err
+	}
+	if elapsed := time.Since(st.StartTime.Time); elapsed > policy.GetTimeout() && !st.TimeoutReported {
+		msg := fmt.Sprintf("node pool %s hasn't been drained in %s", nodePoolID, elapsed.Round(time.Second))
+		r.Recorder.Event(obj, corev1.EventTypeWarning, reasonDrainTimeout, msg)
+		r.notify(ctx, obj, opsv1.NotificationRolloutHalted, cluster.ID, msg)
+		st.TimeoutReported = true
+		changed = true
+	}
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+	return r.updateStatus(ctx, obj, log)
+}
+
+// uncordonNodePool makes the nodes of the node pool in status.drain schedulable again after it has been upgraded,
+// or when it isn't upgraded after all. The nodes are cordoned again if the node pool is drained on the next try.
+func (r *ClusterVersionReconciler) uncordonNodePool(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) {
+	st := obj.Status.Drain
+	if st == nil || obj.Spec.Drain == nil || r.RemoteClient == nil {
+		return
+	}
+	for _, cluster := range obj.Spec.Clusters {
+		if cluster.ID != st.ClusterID {
+			continue
+		}
+		client, err := r.RemoteClient(ctx, *obj, cluster)
+		if err != nil {
+			log.Error(err, "failed to connect to cluster")
+			return
+		}
+		if err := drain.New(client, obj.Spec.Drain.GetNodePoolLabel()).Uncordon(ctx, nodePoolName(st.NodePoolID)); err != nil {
+			log.Error(err, "failed to uncordon node pool")
+			return
+		}
+		log.Info(fmt.Sprintf("node pool %s has been uncordoned", st.NodePoolID))
+		return
+	}
+}
+
+// nodePoolName returns the node pool name from the node pool ID, e.g. "pool-1" for ".../nodePools/pool-1".
+func nodePoolName(nodePoolID string) string {
+	return nodePoolID[strings.LastIndex(nodePoolID, "/")+1:]
+}
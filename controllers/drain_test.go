@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	clientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"time"
+)
+
+var _ = Describe("withDrain", func() {
+	const (
+		nodePoolID = "projects/test-project/locations/asia-northeast1/clusters/drain-cluster/nodePools/pool-1"
+		poolLabel  = "drain-test/pool"
+	)
+	var (
+		obj          *opsv1.ClusterVersion
+		r            *ClusterVersionReconciler
+		remoteClient *fake.Clientset
+		recorder     *record.FakeRecorder
+		notifier     *recordingNotifier
+		requested    int
+		ctx          = context.Background()
+		log          = ctrl.Log.WithName("test")
+	)
+	// started requests the operation which starts
+	started := func() (ctrl.Result, error) {
+		requested++
+		obj.Status.OperationID = "op-1"
+		return ctrl.Result{}, nil
+	}
+	// rejected requests the operation which is rejected by the provider
+	rejected := func() (ctrl.Result, error) {
+		requested++
+		return ctrl.Result{}, nil
+	}
+	addNode := func(name string) {
+		node := &corev1.Node{}
+		node.Name = name
+		node.Labels = map[string]string{poolLabel: "pool-1"}
+		_, err := remoteClient.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+	addProtectedPod := func(name string, nodeName string) {
+		pod := &corev1.Pod{}
+		pod.Namespace = "default"
+		pod.Name = name
+		pod.Labels = map[string]string{labelProtected: ""}
+		pod.OwnerReferences = replicaSetOwner()
+		pod.Spec.NodeName = nodeName
+		_, err := remoteClient.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+	}
+	unschedulable := func(name string) bool {
+		node, err := remoteClient.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+		return node.Spec.Unschedulable
+	}
+	events := func() []string {
+		var ret []string
+		for {
+			select {
+			case e := <-recorder.Events:
+				ret = append(ret, e)
+			default:
+				return ret
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "drain-test", ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{{ID: "projects/test-project/locations/asia-northeast1/clusters/drain-cluster", Version: "1.16.13-gke.404"}},
+				Drain:    &opsv1.DrainPolicy{NodePoolLabel: poolLabel, Timeout: &metav1.Duration{Duration: time.Minute}},
+			},
+		}
+		remoteClient = newMockRemoteClient()
+		recorder = record.NewFakeRecorder(10)
+		notifier = &recordingNotifier{}
+		requested = 0
+		r = &ClusterVersionReconciler{
+			Client:   clientfake.NewFakeClientWithScheme(s, obj.DeepCopy()),
+			Recorder: recorder,
+			Notifier: notifier,
+			RemoteClient: func(context.Context, opsv1.ClusterVersion, opsv1.Cluster) (kubernetes.Interface, error) {
+				return remoteClient, nil
+			},
+		}
+	})
+
+	It("doesn't upgrade the node pool without any nodes and emits the event once", func() {
+		for i := 0; i < 2; i++ {
+			_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		Expect(requested).Should(Equal(0))
+		Expect(obj.Status.Drain.Drained()).Should(BeFalse())
+		Expect(events()).Should(ConsistOf(HavePrefix("Warning " + reasonNodesNotFound)))
+	})
+
+	It("upgrades the node pool after it has been drained", func() {
+		addNode("node-1")
+
+		_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requested).Should(Equal(1))
+		Expect(unschedulable("node-1")).Should(BeTrue())
+	})
+
+	It("doesn't upgrade the node pool while the unmanaged pods remain", func() {
+		addNode("node-1")
+		pod := &corev1.Pod{}
+		pod.Namespace = "default"
+		pod.Name = "bare"
+		pod.Spec.NodeName = "node-1"
+		_, err := remoteClient.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requested).Should(Equal(0))
+		Expect(obj.Status.Drain.Nodes).Should(HaveLen(1))
+		Expect(obj.Status.Drain.Nodes[0].Blocked).Should(ConsistOf(ContainSubstring("default/bare")))
+		Expect(unschedulable("node-1")).Should(BeTrue())
+		_, err = remoteClient.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(remoteClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})).Should(Succeed())
+		_, err = r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requested).Should(Equal(1))
+	})
+
+	It("writes the status only when the progress changes", func() {
+		addNode("node-1")
+		addProtectedPod("app-1", "node-1")
+		writes := 0
+		r.Client = &conflictClient{Client: r.Client, interrupt: func(runtime.Object) { writes++ }}
+
+		for i := 0; i < 3; i++ {
+			_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		Expect(writes).Should(Equal(1))
+
+		addProtectedPod("app-2", "node-1")
+		_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(writes).Should(Equal(2))
+		Expect(obj.Status.Drain.Nodes).Should(Equal([]opsv1.NodeDrainStatus{{Name: "node-1", RemainingPods: 2}}))
+	})
+
+	It("reports the timeout once", func() {
+		addNode("node-1")
+		addProtectedPod("app-1", "node-1")
+		_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		obj.Status.Drain.StartTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+
+		for i := 0; i < 2; i++ {
+			_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		Expect(requested).Should(Equal(0))
+		Expect(obj.Status.Drain.TimeoutReported).Should(BeTrue())
+		Expect(events()).Should(ConsistOf(HavePrefix("Warning " + reasonDrainTimeout)))
+		Expect(notifier.events).Should(HaveLen(1))
+		Expect(notifier.events[0].Type).Should(Equal(opsv1.NotificationRolloutHalted))
+	})
+
+	It("uncordons the nodes if the upgrade is rejected", func() {
+		addNode("node-1")
+		addNode("node-2")
+
+		_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, rejected)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(requested).Should(Equal(1))
+		Expect(unschedulable("node-1")).Should(BeFalse())
+		Expect(unschedulable("node-2")).Should(BeFalse())
+	})
+
+	It("uncordons the nodes after the node pool has been upgraded", func() {
+		addNode("node-1")
+		_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(unschedulable("node-1")).Should(BeTrue())
+
+		m := newMockOperator()
+		m.operationStatusMap[obj.Status.OperationID] = ops.OperationStatusDone
+		r.Operator = m
+		obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+		obj.Status.OperationType = "UPGRADE_NODE_POOL"
+
+		_, err = r.reconcileOperationStatus(ctx, obj, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(unschedulable("node-1")).Should(BeFalse())
+		Expect(obj.Status.Drain).Should(BeNil())
+	})
+
+	It("uncordons the nodes if the upgrade fails", func() {
+		addNode("node-1")
+		_, err := r.withDrain(ctx, obj, obj.Spec.Clusters[0], nodePoolID, log, started)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(unschedulable("node-1")).Should(BeTrue())
+
+		m := newMockOperator()
+		m.operationStatusMap[obj.Status.OperationID] = ops.OperationStatusFailed
+		r.Operator = m
+		obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+		obj.Status.OperationType = "UPGRADE_NODE_POOL"
+
+		_, err = r.reconcileOperationStatus(ctx, obj, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(unschedulable("node-1")).Should(BeFalse())
+		Expect(obj.Status.Drain).Should(BeNil())
+	})
+})
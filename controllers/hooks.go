@@ -0,0 +1,172 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"hash/fnv"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	reasonHookFailed = "HookFailed"
+
+	// labelHookClusterVersion holds the hashed name of the ClusterVersion since the name can be longer than label values.
+	labelHookClusterVersion = "multicluster-ops.io/cluster-version"
+	labelHookType           = "multicluster-ops.io/hook"
+	// annotationHookFailureReported marks the failed Job whose failure has been reported, so that it's reported once.
+	annotationHookFailureReported = "multicluster-ops.io/failure-reported"
+
+	// jobNameMaxLength keeps the job-name label of the Pods valid.
+	jobNameMaxLength = 63
+	// labelValueMaxLength is the limit of label values.
+	labelValueMaxLength = 63
+)
+
+// runHook runs the hook Job of the cluster and returns true if it has succeeded or the hook isn't defined.
+// The Job is created only once per cluster and version, so it's kept until the rollout completes.
+// A failed Job halts the rollout until it's deleted. The failure is reported once per Job.
+func (r *ClusterVersionReconciler) runHook(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, hook opsv1.HookType, log logr.Logger) (bool, error) {
+	tmpl, err := obj.Spec.Hooks.Get(hook)
+	if err != nil {
+		return false, err
+	}
+	if tmpl == nil {
+		return true, nil
+	}
+	job := &batchv1.Job{}
+	key := client.ObjectKey{Namespace: obj.Namespace, Name: hookJobName(obj, cluster, hook)}
+	err = r.Get(ctx, key, job)
+	if k8serrors.IsNotFound(err) {
+		job = newHookJob(obj, cluster, hook, tmpl.ObjectMeta, tmpl.Spec)
+		job.Namespace = key.Namespace
+		job.Name = key.Name
+		if err := ctrl.SetControllerReference(obj, job, r.Scheme); err != nil {
+			return false, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return false, err
+		}
+		log.Info(fmt.Sprintf("%s hook job %s is created for cluster %s", hook, job.Name, cluster.ID))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, nil
+		case batchv1.JobFailed:
+			if _, ok := job.Annotations[annotationHookFailureReported]; ok {
+				return false, nil
+			}
+			// it's marked before reporting, so that a failed write doesn't report the failure twice
+			patch := client.MergeFrom(job.DeepCopy())
+			if job.Annotations == nil {
+				job.Annotations = map[string]string{}
+			}
+			job.Annotations[annotationHookFailureReported] = "true"
+			if err := r.Patch(ctx, job, patch); err != nil {
+				return false, err
+			}
+			msg := fmt.Sprintf("%s hook job %s failed for cluster %s: %s. delete the job %s/%s to retry it", hook, job.Name, cluster.ID, c.Message, job.Namespace, job.Name)
+			log.Info(msg)
+			r.Recorder.Event(obj, corev1.EventTypeWarning, reasonHookFailed, msg)
+			r.notify(ctx, obj, opsv1.NotificationRolloutHalted, cluster.ID, msg)
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// deleteHookJobs deletes all hook Jobs of the ClusterVersion so that the next rollout runs them again.
+func (r *ClusterVersionReconciler) deleteHookJobs(ctx context.Context, obj *opsv1.ClusterVersion) error {
+	return r.DeleteAllOf(ctx, &batchv1.Job{},
+		client.InNamespace(obj.Namespace),
+		client.MatchingLabels{labelHookClusterVersion: hookClusterVersionLabel(obj)},
+		client.PropagationPolicy(metav1.DeletePropagationBackground),
+	)
+}
+
+func newHookJob(obj *opsv1.ClusterVersion, cluster opsv1.Cluster, hook opsv1.HookType, meta metav1.ObjectMeta, spec batchv1.JobSpec) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{},
+			Annotations: meta.Annotations,
+		},
+		Spec: *spec.DeepCopy(),
+	}
+	for k, v := range meta.Labels {
+		job.Labels[k] = v
+	}
+	job.Labels[labelHookClusterVersion] = hookClusterVersionLabel(obj)
+	job.Labels[labelHookType] = string(hook)
+	env := []corev1.EnvVar{
+		{Name: "CLUSTER_ID", Value: cluster.ID},
+		{Name: "TARGET_VERSION", Value: cluster.Version},
+		{Name: "CLUSTER_VERSION_NAME", Value: obj.Name},
+		{Name: "HOOK", Value: string(hook)},
+	}
+	podSpec := &job.Spec.Template.Spec
+	for i := range podSpec.InitContainers {
+		podSpec.InitContainers[i].Env = append(podSpec.InitContainers[i].Env, env...)
+	}
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].Env = append(podSpec.Containers[i].Env, env...)
+	}
+	return job
+}
+
+// hookJobName returns the deterministic name of the hook Job for the cluster and the desired version.
+func hookJobName(obj *opsv1.ClusterVersion, cluster opsv1.Cluster, hook opsv1.HookType) string {
+	suffix := fmt.Sprintf("-%s-%08x", strings.ToLower(string(hook)), fnvHash(cluster.ID+"/"+cluster.Version))
+	return truncateName(obj.Name, jobNameMaxLength-len(suffix)) + suffix
+}
+
+// hookClusterVersionLabel returns the label value of the hook Jobs of the ClusterVersion.
+// It's the name with its hash so that it's valid and unique however long the name is.
+func hookClusterVersionLabel(obj *opsv1.ClusterVersion) string {
+	suffix := fmt.Sprintf("-%08x", fnvHash(obj.Name))
+	return truncateName(obj.Name, labelValueMaxLength-len(suffix)) + suffix
+}
+
+func fnvHash(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// truncateName cuts s to max characters without leaving "-" or "." at the end.
+func truncateName(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.TrimRight(s[:max], "-.")
+}
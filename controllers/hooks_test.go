@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+)
+
+var _ = Describe("hookJobName", func() {
+	var (
+		obj     *opsv1.ClusterVersion
+		cluster opsv1.Cluster
+	)
+
+	BeforeEach(func() {
+		obj = &opsv1.ClusterVersion{}
+		obj.Name = "hook-test"
+		cluster = opsv1.Cluster{ID: "hook-test/cluster-1", Version: "1.16.13-gke.404"}
+	})
+
+	It("is deterministic", func() {
+		name := hookJobName(obj, cluster, opsv1.HookPreServiceOut)
+		Expect(name).Should(HavePrefix("hook-test-preserviceout-"))
+		Expect(hookJobName(obj, cluster, opsv1.HookPreServiceOut)).Should(Equal(name))
+	})
+
+	It("changes by the version", func() {
+		name := hookJobName(obj, cluster, opsv1.HookPreServiceOut)
+		cluster.Version = "1.17.13-gke.1400"
+		Expect(hookJobName(obj, cluster, opsv1.HookPreServiceOut)).ShouldNot(Equal(name))
+	})
+
+	It("isn't longer than the limit", func() {
+		obj.Name = strings.Repeat("a", 60)
+		Expect(len(hookJobName(obj, cluster, opsv1.HookPreServiceOut))).Should(BeNumerically("<=", jobNameMaxLength))
+	})
+})
+
+var _ = Describe("hookClusterVersionLabel", func() {
+	It("is a valid label value however long the name is", func() {
+		obj := &opsv1.ClusterVersion{}
+		obj.Name = strings.Repeat("a", 100)
+		Expect(validation.IsValidLabelValue(hookClusterVersionLabel(obj))).Should(BeEmpty())
+	})
+
+	It("differs between the names which share the prefix", func() {
+		obj1 := &opsv1.ClusterVersion{}
+		obj1.Name = strings.Repeat("a", 100) + "-1"
+		obj2 := &opsv1.ClusterVersion{}
+		obj2.Name = strings.Repeat("a", 100) + "-2"
+		Expect(hookClusterVersionLabel(obj1)).ShouldNot(Equal(hookClusterVersionLabel(obj2)))
+	})
+})
+
+var _ = Describe("newHookJob", func() {
+	cluster := opsv1.Cluster{ID: "hook-test/cluster-1", Version: "1.16.13-gke.404"}
+	spec := batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "smoke-test", Image: "busybox", Env: []corev1.EnvVar{{Name: "FOO", Value: "bar"}}},
+				},
+			},
+		},
+	}
+
+	It("labels the Job and passes the cluster to the containers", func() {
+		obj := &opsv1.ClusterVersion{}
+		obj.Name = "hook-test"
+		job := newHookJob(obj, cluster, opsv1.HookPostUpgrade, metav1.ObjectMeta{Labels: map[string]string{"app": "smoke-test"}}, spec)
+
+		Expect(job.Labels).Should(Equal(map[string]string{
+			"app":                   "smoke-test",
+			labelHookClusterVersion: hookClusterVersionLabel(obj),
+			labelHookType:           "postUpgrade",
+		}))
+		Expect(job.Spec.Template.Spec.Containers[0].Env).Should(ConsistOf(
+			corev1.EnvVar{Name: "FOO", Value: "bar"},
+			corev1.EnvVar{Name: "CLUSTER_ID", Value: cluster.ID},
+			corev1.EnvVar{Name: "TARGET_VERSION", Value: cluster.Version},
+			corev1.EnvVar{Name: "CLUSTER_VERSION_NAME", Value: "hook-test"},
+			corev1.EnvVar{Name: "HOOK", Value: "postUpgrade"},
+		))
+		By("[check] the template isn't modified")
+		Expect(spec.Template.Spec.Containers[0].Env).Should(HaveLen(1))
+	})
+})
+
+var _ = Describe("deleteHookJobs", func() {
+	It("deletes only the Jobs of the ClusterVersion with the long name", func() {
+		s := runtime.NewScheme()
+		Expect(batchv1.AddToScheme(s)).Should(Succeed())
+		obj := &opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: strings.Repeat("a", 100) + "-1"}}
+		other := &opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: strings.Repeat("a", 100) + "-2"}}
+		cluster := opsv1.Cluster{ID: "hook-test/cluster-1", Version: "1.16.13-gke.404"}
+		var jobs []runtime.Object
+		for _, o := range []*opsv1.ClusterVersion{obj, other} {
+			cluster.Version += "-" + o.Name[len(o.Name)-1:]
+			job := newHookJob(o, cluster, opsv1.HookPreUpgrade, metav1.ObjectMeta{}, batchv1.JobSpec{})
+			job.Namespace = o.Namespace
+			job.Name = hookJobName(o, cluster, opsv1.HookPreUpgrade)
+			jobs = append(jobs, job)
+		}
+		r := &ClusterVersionReconciler{Client: fake.NewFakeClientWithScheme(s, jobs...)}
+
+		Expect(r.deleteHookJobs(context.Background(), obj)).Should(Succeed())
+
+		list := &batchv1.JobList{}
+		Expect(r.List(context.Background(), list, client.InNamespace("default"))).Should(Succeed())
+		Expect(list.Items).Should(HaveLen(1))
+		Expect(list.Items[0].Labels[labelHookClusterVersion]).Should(Equal(hookClusterVersionLabel(other)))
+	})
+})
+
+var _ = Describe("runHook", func() {
+	It("reports the failed Job once and names it", func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		Expect(batchv1.AddToScheme(s)).Should(Succeed())
+		obj := &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hook-test"},
+			Spec: opsv1.ClusterVersionSpec{
+				Hooks: &opsv1.Hooks{PreUpgrade: &runtime.RawExtension{Raw: []byte(`{"spec":{"template":{"spec":{"restartPolicy":"Never"}}}}`)}},
+			},
+		}
+		cluster := opsv1.Cluster{ID: "hook-test/cluster-1", Version: "1.16.13-gke.404"}
+		// failedJob returns the hook Job which has failed
+		failedJob := func() *batchv1.Job {
+			job := newHookJob(obj, cluster, opsv1.HookPreUpgrade, metav1.ObjectMeta{}, batchv1.JobSpec{})
+			job.Namespace = obj.Namespace
+			job.Name = hookJobName(obj, cluster, opsv1.HookPreUpgrade)
+			job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"}}
+			return job
+		}
+		recorder := record.NewFakeRecorder(10)
+		notifier := &recordingNotifier{}
+		r := &ClusterVersionReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, failedJob()),
+			Scheme:   s,
+			Recorder: recorder,
+			Notifier: notifier,
+		}
+		ctx := context.Background()
+		log := ctrl.Log.WithName("test")
+
+		for i := 0; i < 3; i++ {
+			done, err := r.runHook(ctx, obj, cluster, opsv1.HookPreUpgrade, log)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(done).Should(BeFalse())
+		}
+		Expect(recorder.Events).Should(HaveLen(1))
+		Expect(<-recorder.Events).Should(And(
+			HavePrefix("Warning "+reasonHookFailed),
+			ContainSubstring("delete the job default/"+failedJob().Name),
+		))
+		Expect(notifier.events).Should(HaveLen(1))
+		Expect(notifier.events[0].Type).Should(Equal(opsv1.NotificationRolloutHalted))
+
+		By("[check] the Job which fails again after it's recreated is reported again")
+		Expect(r.Delete(ctx, failedJob())).Should(Succeed())
+		Expect(r.Create(ctx, failedJob())).Should(Succeed())
+		_, err := r.runHook(ctx, obj, cluster, opsv1.HookPreUpgrade, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recorder.Events).Should(HaveLen(1))
+		Expect(notifier.events).Should(HaveLen(2))
+	})
+})
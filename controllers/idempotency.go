@@ -0,0 +1,54 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The steps of the operations which identify the requests by the idempotency keys.
+const (
+	stepServiceIn       = "SERVICE_IN"
+	stepServiceOut      = "SERVICE_OUT"
+	stepUpgradeMaster   = "UPGRADE_MASTER"
+	stepUpgradeNodePool = "UPGRADE_NODE_POOL"
+	stepUpgradeWorkload = "UPGRADE_WORKLOAD"
+)
+
+// requestOperation records the intent to request the step of the cluster before calling the Operator,
+// and returns the context which carries its idempotency key. The key is kept while the step is retried,
+// so the plugin server can return the operation it has started even if recording it as running has failed.
+// Once the spec is edited, the step is requested again with a new key.
+func (r *ClusterVersionReconciler) requestOperation(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, step string, log logr.Logger) (context.Context, error) {
+	key := ops.IdempotencyKey(*obj, cluster.ID, step)
+	if pending := obj.Status.PendingOperation; pending == nil || pending.IdempotencyKey != key {
+		obj.Status.PendingOperation = &opsv1.PendingOperation{
+			ClusterID:      cluster.ID,
+			Step:           step,
+			IdempotencyKey: key,
+			RequestTime:    metav1.Now(),
+		}
+		if _, err := r.updateStatus(ctx, obj, log); err != nil {
+			return nil, err
+		}
+	}
+	return ops.WithIdempotencyKey(ctx, obj.Status.PendingOperation.IdempotencyKey), nil
+}
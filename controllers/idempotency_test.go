@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("requestOperation", func() {
+	var (
+		obj     *opsv1.ClusterVersion
+		r       *ClusterVersionReconciler
+		cluster opsv1.Cluster
+		ctx     = context.Background()
+		log     = ctrl.Log.WithName("test")
+	)
+	// request requests the step and returns the idempotency key sent with it
+	request := func(step string) string {
+		reqCtx, err := r.requestOperation(ctx, obj, cluster, step, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		return ops.IdempotencyKeyFromContext(reqCtx)
+	}
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "idempotency-test", UID: "uid-1", Generation: 1, ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{{ID: "cluster-1", Version: "1.16.13-gke.404"}},
+			},
+		}
+		r = &ClusterVersionReconciler{Client: fake.NewFakeClientWithScheme(s, obj.DeepCopy())}
+		cluster = obj.Spec.Clusters[0]
+	})
+
+	It("records the intent with the key before the request", func() {
+		key := request(stepUpgradeMaster)
+		Expect(key).ShouldNot(BeEmpty())
+
+		stored := &opsv1.ClusterVersion{}
+		Expect(r.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: obj.Name}, stored)).Should(Succeed())
+		Expect(stored.Status.PendingOperation).ShouldNot(BeNil())
+		Expect(stored.Status.PendingOperation.IdempotencyKey).Should(Equal(key))
+	})
+
+	It("retries the request with the same key while the spec is unchanged", func() {
+		key := request(stepUpgradeMaster)
+
+		Expect(request(stepUpgradeMaster)).Should(Equal(key))
+	})
+
+	It("requests the step with another key after the spec has changed", func() {
+		key := request(stepUpgradeMaster)
+
+		obj.Generation = 2
+		obj.Spec.Clusters[0].Version = "1.17"
+		another := request(stepUpgradeMaster)
+		Expect(another).ShouldNot(Equal(key))
+		Expect(obj.Status.PendingOperation.IdempotencyKey).Should(Equal(another))
+	})
+
+	It("derives the same key from the status lost before the request", func() {
+		key := request(stepUpgradeMaster)
+
+		obj.Status.PendingOperation = nil
+		Expect(request(stepUpgradeMaster)).Should(Equal(key))
+	})
+
+	It("requests another step with another key", func() {
+		key := request(stepUpgradeMaster)
+
+		Expect(request(stepServiceIn)).ShouldNot(Equal(key))
+	})
+
+	It("requests the same step with another key after an operation has started", func() {
+		key := request(stepUpgradeMaster)
+
+		_, err := r.startOperation(ctx, obj, cluster, &ops.OperationResult{OperationID: "op-1", OperationType: "UPGRADE_MASTER"}, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(obj.Status.PendingOperation).Should(BeNil())
+		Expect(request(stepUpgradeMaster)).ShouldNot(Equal(key))
+	})
+})
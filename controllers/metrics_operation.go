@@ -2,7 +2,21 @@ package controllers
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sync"
+	"time"
+)
+
+const (
+	componentMaster   = "master"
+	componentNodePool = "node_pool"
+
+	// fleetMetricsInterval is the minimum interval of recording the clusters of a ClusterVersion one by one,
+	// which takes two operator calls per cluster.
+	fleetMetricsInterval = time.Minute
 )
 
 var (
@@ -21,8 +35,75 @@ var (
 		},
 		defaultLabels,
 	)
+	operationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "multicluster_clusterversion_operation_duration_seconds",
+			Help: "Duration of completed cluster operations",
+			// from 10 seconds to about 11 hours
+			Buckets: prometheus.ExponentialBuckets(10, 2, 13),
+		},
+		[]string{"operation", "cluster", "result"},
+	)
+	clusterVersionInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "multicluster_clusterversion_cluster_version_info",
+			Help: "Current version of each cluster's master and node pools. The value is always 1",
+		},
+		[]string{"namespace", "name", "cluster", "component", "node_pool", "version"},
+	)
+	clusterServicedOut = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "multicluster_clusterversion_cluster_serviced_out",
+			Help: "Whether the cluster is serviced out currently",
+		},
+		[]string{"namespace", "name", "cluster"},
+	)
+	clusterVersionInRollout = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "multicluster_clusterversion_in_rollout",
+			Help: "Whether the ClusterVersion is rolling out currently",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// observedClusters holds the series of each cluster per ClusterVersion to drop stale series.
+	observedClusters = map[types.NamespacedName]map[string]*clusterSeries{}
+	// fleetRecorded holds the time when the clusters of each ClusterVersion have been recorded one by one.
+	fleetRecorded        = map[types.NamespacedName]time.Time{}
+	observedClustersLock sync.Mutex
 )
 
+// clusterSeries holds the series of the cluster exported for the ClusterVersion.
+type clusterSeries struct {
+	// versions holds the labels of the last observed version per component and node pool.
+	versions    map[string]prometheus.Labels
+	servicedOut prometheus.Labels
+}
+
+// observedCluster returns the series of the cluster. It must be called with observedClustersLock held.
+func observedCluster(name types.NamespacedName, cluster string) *clusterSeries {
+	clusters, ok := observedClusters[name]
+	if !ok {
+		clusters = map[string]*clusterSeries{}
+		observedClusters[name] = clusters
+	}
+	series, ok := clusters[cluster]
+	if !ok {
+		series = &clusterSeries{versions: map[string]prometheus.Labels{}}
+		clusters[cluster] = series
+	}
+	return series
+}
+
+func (s *clusterSeries) delete() {
+	for _, labels := range s.versions {
+		clusterVersionInfo.Delete(labels)
+	}
+	if s.servicedOut != nil {
+		clusterServicedOut.Delete(s.servicedOut)
+	}
+}
+
 func addSuccessOperation(operation string) {
 	successOperation.With(prometheus.Labels{"operation": operation}).Inc()
 }
@@ -31,9 +112,105 @@ func addFailedOperation(operation string) {
 	failedOperation.With(prometheus.Labels{"operation": operation}).Inc()
 }
 
+func observeOperationDuration(operation, cluster, result string, d time.Duration) {
+	operationDuration.With(prometheus.Labels{"operation": operation, "cluster": cluster, "result": result}).Observe(d.Seconds())
+}
+
+func setClusterVersion(name types.NamespacedName, cluster string, cv *ops.ClusterVersion) {
+	setComponentVersion(name, cluster, componentMaster, "", cv.Master.Version)
+	for _, np := range cv.NodePools {
+		setComponentVersion(name, cluster, componentNodePool, np.NodePoolID, np.Version)
+	}
+}
+
+func setComponentVersion(name types.NamespacedName, cluster, component, nodePool, version string) {
+	observedClustersLock.Lock()
+	defer observedClustersLock.Unlock()
+
+	series := observedCluster(name, cluster)
+	key := component + "/" + nodePool
+	labels := prometheus.Labels{"namespace": name.Namespace, "name": name.Name, "cluster": cluster, "component": component, "node_pool": nodePool, "version": version}
+	if prev, ok := series.versions[key]; ok && prev["version"] != version {
+		clusterVersionInfo.Delete(prev)
+	}
+	series.versions[key] = labels
+	clusterVersionInfo.With(labels).Set(1)
+}
+
+func setClusterServicedOut(name types.NamespacedName, cluster string, cs *ops.ClusterStatus) {
+	observedClustersLock.Lock()
+	defer observedClustersLock.Unlock()
+
+	v := 0.0
+	if cs.Type == ops.ClusterStatusServiceOut {
+		v = 1
+	}
+	series := observedCluster(name, cluster)
+	series.servicedOut = prometheus.Labels{"namespace": name.Namespace, "name": name.Name, "cluster": cluster}
+	clusterServicedOut.With(series.servicedOut).Set(v)
+}
+
+// retainClusterMetrics drops the series of the clusters which have been removed from the ClusterVersion.
+func retainClusterMetrics(name types.NamespacedName, clusters []opsv1.Cluster) {
+	observedClustersLock.Lock()
+	defer observedClustersLock.Unlock()
+
+	ids := map[string]bool{}
+	for _, c := range clusters {
+		ids[c.ID] = true
+	}
+	for cluster, series := range observedClusters[name] {
+		if !ids[cluster] {
+			series.delete()
+			delete(observedClusters[name], cluster)
+		}
+	}
+}
+
+// shouldRecordFleet returns true if the clusters of the ClusterVersion haven't been recorded one by one
+// in fleetMetricsInterval, and records now as the time of the recording.
+func shouldRecordFleet(name types.NamespacedName, now time.Time) bool {
+	observedClustersLock.Lock()
+	defer observedClustersLock.Unlock()
+
+	if last, ok := fleetRecorded[name]; ok && now.Sub(last) < fleetMetricsInterval {
+		return false
+	}
+	fleetRecorded[name] = now
+	return true
+}
+
+// deleteClusterMetrics drops the series of all clusters of the deleted ClusterVersion.
+func deleteClusterMetrics(name types.NamespacedName) {
+	observedClustersLock.Lock()
+	defer observedClustersLock.Unlock()
+
+	for _, series := range observedClusters[name] {
+		series.delete()
+	}
+	delete(observedClusters, name)
+	delete(fleetRecorded, name)
+}
+
+func setInRollout(name types.NamespacedName, inRollout bool) {
+	v := 0.0
+	if inRollout {
+		v = 1
+	}
+	clusterVersionInRollout.With(prometheus.Labels{"namespace": name.Namespace, "name": name.Name}).Set(v)
+}
+
+func deleteInRollout(name types.NamespacedName) {
+	clusterVersionInRollout.Delete(prometheus.Labels{"namespace": name.Namespace, "name": name.Name})
+}
+
 func init() {
 	metrics.Registry.MustRegister(
 		successOperation,
 		failedOperation,
+		operationDuration,
+		clusterVersionInfo,
+		clusterServicedOut,
+		clusterVersionInRollout,
 	)
 }
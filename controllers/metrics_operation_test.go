@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// versionLabels returns the labels of the cluster_version_info series.
+func versionLabels(name types.NamespacedName, cluster, component, nodePool, version string) prometheus.Labels {
+	return prometheus.Labels{"namespace": name.Namespace, "name": name.Name, "cluster": cluster, "component": component, "node_pool": nodePool, "version": version}
+}
+
+// servicedOutLabels returns the labels of the cluster_serviced_out series.
+func servicedOutLabels(name types.NamespacedName, cluster string) prometheus.Labels {
+	return prometheus.Labels{"namespace": name.Namespace, "name": name.Name, "cluster": cluster}
+}
+
+var _ = Describe("setClusterVersion", func() {
+	const cluster = "metrics-test/cluster-1"
+	name := types.NamespacedName{Namespace: "default", Name: "metrics-test"}
+
+	It("exports the current versions and drops the previous ones", func() {
+		setClusterVersion(name, cluster, &ops.ClusterVersion{
+			Master:    ops.MasterVersion{ClusterID: cluster, Version: "1.16.13-gke.old"},
+			NodePools: []ops.NodePoolVersion{{NodePoolID: "node-pool-1", Version: "1.16.13-gke.old"}},
+		})
+		setClusterVersion(name, cluster, &ops.ClusterVersion{
+			Master:    ops.MasterVersion{ClusterID: cluster, Version: "1.16.13-gke.new"},
+			NodePools: []ops.NodePoolVersion{{NodePoolID: "node-pool-1", Version: "1.16.13-gke.old"}},
+		})
+
+		Expect(testutil.ToFloat64(clusterVersionInfo.With(versionLabels(name, cluster, componentMaster, "", "1.16.13-gke.new")))).Should(Equal(1.0))
+		Expect(testutil.ToFloat64(clusterVersionInfo.With(versionLabels(name, cluster, componentNodePool, "node-pool-1", "1.16.13-gke.old")))).Should(Equal(1.0))
+		Expect(clusterVersionInfo.Delete(versionLabels(name, cluster, componentMaster, "", "1.16.13-gke.old"))).Should(BeFalse())
+	})
+})
+
+var _ = Describe("recordFleetMetrics", func() {
+	var (
+		obj  *opsv1.ClusterVersion
+		m    *mockOperator
+		r    *ClusterVersionReconciler
+		name = types.NamespacedName{Namespace: "default", Name: "metrics-fleet-test"}
+	)
+
+	BeforeEach(func() {
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{
+					{ID: "metrics-fleet-test/cluster-1", Version: "1.16.15-gke.4301"},
+					{ID: "metrics-fleet-test/cluster-2", Version: "1.16.15-gke.4301"},
+					{ID: "metrics-fleet-test/cluster-3", Version: "1.16.15-gke.4301"},
+				},
+			},
+		}
+		m = newMockOperator()
+		m.AddClusterVersion(
+			&ops.ClusterVersion{Master: ops.MasterVersion{ClusterID: obj.Spec.Clusters[0].ID, Version: "1.16.13-gke.404"}},
+			&ops.ClusterVersion{Master: ops.MasterVersion{ClusterID: obj.Spec.Clusters[1].ID, Version: "1.16.13-gke.404"}},
+		)
+		m.clusterStatusMap[obj.Spec.Clusters[1].ID].Type = ops.ClusterStatusServiceOut
+		r = &ClusterVersionReconciler{Operator: m}
+		deleteClusterMetrics(name)
+	})
+
+	It("records all clusters even if the first one is being upgraded", func() {
+		r.recordFleetMetrics(context.Background(), obj, false, ctrl.Log.WithName("test"))
+
+		for _, c := range obj.Spec.Clusters[:2] {
+			Expect(testutil.ToFloat64(clusterVersionInfo.With(versionLabels(name, c.ID, componentMaster, "", "1.16.13-gke.404")))).Should(Equal(1.0))
+		}
+		Expect(testutil.ToFloat64(clusterServicedOut.With(servicedOutLabels(name, obj.Spec.Clusters[0].ID)))).Should(Equal(0.0))
+		Expect(testutil.ToFloat64(clusterServicedOut.With(servicedOutLabels(name, obj.Spec.Clusters[1].ID)))).Should(Equal(1.0))
+
+		By("[check] the cluster which can't be read isn't recorded")
+		Expect(clusterServicedOut.Delete(servicedOutLabels(name, obj.Spec.Clusters[2].ID))).Should(BeFalse())
+	})
+
+	It("drops the series of the clusters removed from the spec", func() {
+		r.recordFleetMetrics(context.Background(), obj, false, ctrl.Log.WithName("test"))
+		removed := obj.Spec.Clusters[0].ID
+		obj.Spec.Clusters = obj.Spec.Clusters[1:]
+
+		r.recordFleetMetrics(context.Background(), obj, false, ctrl.Log.WithName("test"))
+
+		Expect(clusterVersionInfo.Delete(versionLabels(name, removed, componentMaster, "", "1.16.13-gke.404"))).Should(BeFalse())
+		Expect(clusterServicedOut.Delete(servicedOutLabels(name, removed))).Should(BeFalse())
+		Expect(testutil.ToFloat64(clusterServicedOut.With(servicedOutLabels(name, obj.Spec.Clusters[0].ID)))).Should(Equal(1.0))
+	})
+
+	It("reads the clusters one by one at most once in the interval unless the fleet has been prefetched", func() {
+		r.recordFleetMetrics(context.Background(), obj, false, ctrl.Log.WithName("test"))
+		m.AddClusterVersion(&ops.ClusterVersion{Master: ops.MasterVersion{ClusterID: obj.Spec.Clusters[0].ID, Version: "1.16.15-gke.4301"}})
+
+		r.recordFleetMetrics(context.Background(), obj, false, ctrl.Log.WithName("test"))
+		Expect(testutil.ToFloat64(clusterVersionInfo.With(versionLabels(name, obj.Spec.Clusters[0].ID, componentMaster, "", "1.16.13-gke.404")))).Should(Equal(1.0))
+
+		r.recordFleetMetrics(context.Background(), obj, true, ctrl.Log.WithName("test"))
+		Expect(testutil.ToFloat64(clusterVersionInfo.With(versionLabels(name, obj.Spec.Clusters[0].ID, componentMaster, "", "1.16.15-gke.4301")))).Should(Equal(1.0))
+	})
+
+	It("records all clusters while an operation is running", func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+		obj.Status.OperationID = m.AddRunningOperation(obj.Spec.Clusters[0].ID, "1.16.15-gke.4301")
+		obj.Status.OperationType = "UPGRADE_MASTER"
+		r.Client = fake.NewFakeClientWithScheme(s, obj)
+		r.Log = ctrl.Log.WithName("test")
+
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: name})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(testutil.ToFloat64(clusterServicedOut.With(servicedOutLabels(name, obj.Spec.Clusters[1].ID)))).Should(Equal(1.0))
+	})
+
+	It("drops the series of all clusters when the ClusterVersion is deleted", func() {
+		r.recordFleetMetrics(context.Background(), obj, false, ctrl.Log.WithName("test"))
+
+		deleteClusterMetrics(name)
+
+		for _, c := range obj.Spec.Clusters[:2] {
+			Expect(clusterVersionInfo.Delete(versionLabels(name, c.ID, componentMaster, "", "1.16.13-gke.404"))).Should(BeFalse())
+			Expect(clusterServicedOut.Delete(servicedOutLabels(name, c.ID))).Should(BeFalse())
+		}
+	})
+})
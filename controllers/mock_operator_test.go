@@ -19,11 +19,39 @@ type mockOperator struct {
 	clusterStatusMap   map[string]*ClusterStatus
 	operationStatusMap map[string]OperationStatus
 	executedOperations map[string][]*OperationResult
+	workloadVersionMap map[string]map[string]string
+	capabilitiesMap    map[string]*Capabilities
+	versionsMap        map[string][]string
+	// watchable holds the resource names whose operations can be watched, and how many times they were watched.
+	watchable map[string]int
+	// masterFailures holds the error details of the clusters whose master upgrades fail.
+	masterFailures     map[string]string
+	operationDetailMap map[string]*OperationDetail
+	idempotencyKeys    map[string][]string
+	// runningOperations holds the operations started outside of the reconciler per cluster.
+	runningOperations map[string][]*runningOperation
+	// lostResponses holds the IDs of the master upgrades whose responses are lost per cluster.
+	lostResponses map[string]string
 
 	lock sync.RWMutex
 }
 
 var _ Operator = &mockOperator{}
+var _ WorkloadOperator = &mockOperator{}
+var _ CapabilitiesOperator = &mockOperator{}
+var _ VersionOperator = &mockOperator{}
+var _ WatchOperator = &mockOperator{}
+var _ OperationDetailOperator = &mockOperator{}
+var _ OperationListOperator = &mockOperator{}
+
+// runningOperation is the operation which upgrades the master, or the node pool of target, to version after it's listed.
+type runningOperation struct {
+	id      string
+	opType  string
+	target  string
+	version string
+	listed  bool
+}
 
 func newMockOperator() *mockOperator {
 	return &mockOperator{
@@ -31,6 +59,15 @@ func newMockOperator() *mockOperator {
 		clusterStatusMap:   map[string]*ClusterStatus{},
 		operationStatusMap: map[string]OperationStatus{},
 		executedOperations: map[string][]*OperationResult{},
+		workloadVersionMap: map[string]map[string]string{},
+		capabilitiesMap:    map[string]*Capabilities{},
+		versionsMap:        map[string][]string{},
+		watchable:          map[string]int{},
+		masterFailures:     map[string]string{},
+		operationDetailMap: map[string]*OperationDetail{},
+		idempotencyKeys:    map[string][]string{},
+		runningOperations:  map[string][]*runningOperation{},
+		lostResponses:      map[string]string{},
 	}
 }
 
@@ -46,6 +83,90 @@ func (m *mockOperator) AddClusterVersion(clusters ...*ClusterVersion) {
 	}
 }
 
+func (m *mockOperator) AddWorkloadVersion(clusterID string, name string, version string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.workloadVersionMap[clusterID] == nil {
+		m.workloadVersionMap[clusterID] = map[string]string{}
+	}
+	m.workloadVersionMap[clusterID][name] = version
+}
+
+func (m *mockOperator) AddCapabilities(clusterID string, caps *Capabilities) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.capabilitiesMap[clusterID] = caps
+}
+
+func (m *mockOperator) AddAvailableVersions(clusterID string, versions ...string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.versionsMap[clusterID] = append(m.versionsMap[clusterID], versions...)
+}
+
+func (m *mockOperator) FailMasterUpgrade(clusterID string, detail string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.masterFailures[clusterID] = detail
+}
+
+// AddRunningOperation adds the master upgrade started outside of the reconciler, and returns the operation ID.
+// The operation completes soon after it's listed first.
+func (m *mockOperator) AddRunningOperation(clusterID string, version string) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := string(uuid.NewUUID())
+	m.operationStatusMap[id] = OperationStatusRunning
+	m.runningOperations[clusterID] = append(m.runningOperations[clusterID], &runningOperation{id: id, opType: "UPGRADE_MASTER", version: version})
+	return id
+}
+
+// AddRunningNodePoolOperation adds the node pool upgrade started outside of the reconciler, and returns the operation ID.
+// The operation completes soon after it's listed first.
+func (m *mockOperator) AddRunningNodePoolOperation(clusterID string, nodePoolID string, version string) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := string(uuid.NewUUID())
+	m.operationStatusMap[id] = OperationStatusRunning
+	m.runningOperations[clusterID] = append(m.runningOperations[clusterID], &runningOperation{id: id, opType: "UPGRADE_NODE_POOL", target: nodePoolID, version: version})
+	return id
+}
+
+// LoseMasterUpgradeResponse makes the next master upgrade of the cluster start the operation but fail to respond,
+// and returns the operation ID. The operation completes soon after it's listed first.
+func (m *mockOperator) LoseMasterUpgradeResponse(clusterID string) string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := string(uuid.NewUUID())
+	m.lostResponses[clusterID] = id
+	return id
+}
+
+// recordIdempotencyKey records the key of the mutating call. It must be called with the lock.
+func (m *mockOperator) recordIdempotencyKey(ctx context.Context, resourceName string) {
+	m.idempotencyKeys[resourceName] = append(m.idempotencyKeys[resourceName], IdempotencyKeyFromContext(ctx))
+}
+
+func (m *mockOperator) IdempotencyKeys(resourceName string) []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]string{}, m.idempotencyKeys[resourceName]...)
+}
+
+func (m *mockOperator) EnableWatch(resourceName string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.watchable[resourceName] = 0
+}
+
+func (m *mockOperator) WatchedCount(resourceName string) func() int {
+	return func() int {
+		m.lock.RLock()
+		defer m.lock.RUnlock()
+		return m.watchable[resourceName]
+	}
+}
+
 func (m *mockOperator) LastExecutedOperationIs(operationType string, resourceName string) func() bool {
 	return func() bool {
 		m.lock.RLock()
@@ -139,9 +260,24 @@ func (m *mockOperator) GetOperationStatus(_ context.Context, obj opsv1.ClusterVe
 	return v, nil
 }
 
-func (m *mockOperator) ServiceIn(_ context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+func (m *mockOperator) GetOperationDetail(_ context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if detail, ok := m.operationDetailMap[obj.Status.OperationID]; ok {
+		return detail, nil
+	}
+	v, ok := m.operationStatusMap[obj.Status.OperationID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &OperationDetail{Status: v}, nil
+}
+
+func (m *mockOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	m.recordIdempotencyKey(ctx, obj.Name)
 
 	id := string(uuid.NewUUID())
 	m.operationStatusMap[id] = OperationStatusRunning
@@ -166,9 +302,10 @@ func (m *mockOperator) ServiceIn(_ context.Context, obj opsv1.ClusterVersion, cl
 	return or, nil
 }
 
-func (m *mockOperator) ServiceOut(_ context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+func (m *mockOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	m.recordIdempotencyKey(ctx, obj.Name)
 
 	id := string(uuid.NewUUID())
 	m.operationStatusMap[id] = OperationStatusRunning
@@ -193,9 +330,17 @@ func (m *mockOperator) ServiceOut(_ context.Context, obj opsv1.ClusterVersion, c
 	return or, nil
 }
 
-func (m *mockOperator) UpgradeMaster(_ context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+func (m *mockOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	m.recordIdempotencyKey(ctx, obj.Name)
+
+	if id, ok := m.lostResponses[cluster.ID]; ok {
+		delete(m.lostResponses, cluster.ID)
+		m.operationStatusMap[id] = OperationStatusRunning
+		m.runningOperations[cluster.ID] = append(m.runningOperations[cluster.ID], &runningOperation{id: id, opType: "UPGRADE_MASTER", version: cluster.Version})
+		return nil, errors.New("connection reset before the response")
+	}
 
 	id := string(uuid.NewUUID())
 	m.operationStatusMap[id] = OperationStatusRunning
@@ -204,18 +349,18 @@ func (m *mockOperator) UpgradeMaster(_ context.Context, obj opsv1.ClusterVersion
 		m.lock.Lock()
 		defer m.lock.Unlock()
 
+		if detail, ok := m.masterFailures[cluster.ID]; ok {
+			progress := int32(40)
+			m.operationStatusMap[id] = OperationStatusFailed
+			m.operationDetailMap[id] = &OperationDetail{Status: OperationStatusFailed, Progress: &progress, Error: detail}
+			return
+		}
 		current, ok := m.clusterVersionMap[cluster.ID]
 		if !ok {
 			return
 		}
 
-		var desiredVersion string
-		for _, cl := range obj.Spec.Clusters {
-			if cl.ID == cluster.ID {
-				desiredVersion = cl.Version
-			}
-		}
-		current.Master.Version = desiredVersion
+		current.Master.Version = cluster.Version
 		m.operationStatusMap[id] = OperationStatusDone
 	})
 
@@ -228,9 +373,10 @@ func (m *mockOperator) UpgradeMaster(_ context.Context, obj opsv1.ClusterVersion
 	return or, nil
 }
 
-func (m *mockOperator) UpgradeNodePool(_ context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+func (m *mockOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
+	m.recordIdempotencyKey(ctx, obj.Name)
 
 	id := string(uuid.NewUUID())
 	m.operationStatusMap[id] = OperationStatusRunning
@@ -243,15 +389,9 @@ func (m *mockOperator) UpgradeNodePool(_ context.Context, obj opsv1.ClusterVersi
 		if !ok {
 			return
 		}
-		var desiredVersion string
-		for _, cl := range obj.Spec.Clusters {
-			if cl.ID == cluster.ID {
-				desiredVersion = cl.Version
-			}
-		}
 		for i, np := range current.NodePools {
 			if np.NodePoolID == nodePoolID {
-				current.NodePools[i].Version = desiredVersion
+				current.NodePools[i].Version = cluster.Version
 			}
 		}
 		m.operationStatusMap[id] = OperationStatusDone
@@ -265,3 +405,136 @@ func (m *mockOperator) UpgradeNodePool(_ context.Context, obj opsv1.ClusterVersi
 	m.executedOperations[obj.Name] = append(results, or)
 	return or, nil
 }
+
+func (m *mockOperator) GetWorkloadVersion(_ context.Context, _ opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	v, ok := m.workloadVersionMap[cluster.ID][workload.Name]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return v, nil
+}
+
+func (m *mockOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.recordIdempotencyKey(ctx, obj.Name)
+
+	id := string(uuid.NewUUID())
+	m.operationStatusMap[id] = OperationStatusRunning
+
+	time.AfterFunc(operationWaitTime, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+
+		if _, ok := m.workloadVersionMap[cluster.ID]; !ok {
+			return
+		}
+		m.workloadVersionMap[cluster.ID][workload.Name] = workload.Version
+		m.operationStatusMap[id] = OperationStatusDone
+	})
+
+	or := &OperationResult{
+		OperationID:   id,
+		OperationType: "UPGRADE_WORKLOAD",
+	}
+	results := m.executedOperations[obj.Name]
+	m.executedOperations[obj.Name] = append(results, or)
+	return or, nil
+}
+
+func (m *mockOperator) GetCapabilities(_ context.Context, _ opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	caps, ok := m.capabilitiesMap[cluster.ID]
+	if !ok {
+		return DefaultCapabilities(), nil
+	}
+	return caps, nil
+}
+
+func (m *mockOperator) GetAvailableVersions(_ context.Context, _ opsv1.ClusterVersion, cluster opsv1.Cluster, _ string) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	versions, ok := m.versionsMap[cluster.ID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return versions, nil
+}
+
+func (m *mockOperator) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if _, ok := m.watchable[obj.Name]; !ok {
+		return nil, ErrNotSupported
+	}
+	m.watchable[obj.Name]++
+	ch := make(chan OperationStatus)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(operationWaitTime / 2)
+		defer ticker.Stop()
+		for {
+			m.lock.RLock()
+			status := m.operationStatusMap[obj.Status.OperationID]
+			m.lock.RUnlock()
+			select {
+			case ch <- status:
+			case <-ctx.Done():
+				return
+			}
+			if status == OperationStatusDone || status == OperationStatusFailed {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (m *mockOperator) ListOperations(_ context.Context, _ opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var ret []Operation
+	for _, op := range m.runningOperations[cluster.ID] {
+		ret = append(ret, Operation{
+			OperationID:   op.id,
+			OperationType: op.opType,
+			Target:        op.target,
+			Status:        m.operationStatusMap[op.id],
+		})
+		if op.listed {
+			continue
+		}
+		op.listed = true
+		id, target, version := op.id, op.target, op.version
+		time.AfterFunc(operationWaitTime, func() {
+			m.lock.Lock()
+			defer m.lock.Unlock()
+
+			if current, ok := m.clusterVersionMap[cluster.ID]; ok {
+				if target == "" {
+					current.Master.Version = version
+				}
+				for i, np := range current.NodePools {
+					if np.NodePoolID == target {
+						current.NodePools[i].Version = version
+					}
+				}
+			}
+			m.operationStatusMap[id] = OperationStatusDone
+		})
+	}
+	return ret, nil
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sync"
+)
+
+const labelProtected = "protected"
+
+// mockRemoteClients holds the clientset of each target cluster so that the test cases don't share nodes.
+type mockRemoteClients struct {
+	clients map[string]*fake.Clientset
+	lock    sync.Mutex
+}
+
+func newMockRemoteClients() *mockRemoteClients {
+	return &mockRemoteClients{clients: map[string]*fake.Clientset{}}
+}
+
+// Get returns the clientset of the cluster, creating it on the first call.
+func (m *mockRemoteClients) Get(clusterID string) *fake.Clientset {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.clients[clusterID]; !ok {
+		m.clients[clusterID] = newMockRemoteClient()
+	}
+	return m.clients[clusterID]
+}
+
+// replicaSetOwner returns the owner reference of the pods managed by a ReplicaSet, which are evicted in drains.
+func replicaSetOwner() []metav1.OwnerReference {
+	controller := true
+	return []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app", Controller: &controller}}
+}
+
+// newMockRemoteClient returns the clientset of the target clusters.
+// Evictions delete the pod at once unless the pod has the protected label, as if its PodDisruptionBudget blocked it.
+func newMockRemoteClient() *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		obj, err := client.Tracker().Get(corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		if _, ok := obj.(*corev1.Pod).Labels[labelProtected]; ok {
+			return true, nil, k8serrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		}
+		return true, nil, client.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+	})
+	return client
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/notify"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// recordingNotifier records the notified events and the forgotten ClusterVersions.
+type recordingNotifier struct {
+	events    []notify.Event
+	forgotten []types.NamespacedName
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, _ opsv1.ClusterVersion, ev notify.Event) {
+	n.events = append(n.events, ev)
+}
+
+func (n *recordingNotifier) Forget(name types.NamespacedName) {
+	n.forgotten = append(n.forgotten, name)
+}
+
+var _ = Describe("rollout halts", func() {
+	const clusterID = "projects/test-project/locations/asia-northeast1/clusters/halt-cluster"
+	var (
+		obj      *opsv1.ClusterVersion
+		m        *mockOperator
+		r        *ClusterVersionReconciler
+		notifier *recordingNotifier
+		ctx      = context.Background()
+		log      = ctrl.Log.WithName("test")
+	)
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "halt-test", ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{{ID: clusterID, Version: "1.16.13-gke.404"}},
+			},
+		}
+		m = newMockOperator()
+		m.AddClusterVersion(&ops.ClusterVersion{Master: ops.MasterVersion{ClusterID: clusterID, Version: "1.16.13-gke.404"}})
+		notifier = &recordingNotifier{}
+		r = &ClusterVersionReconciler{
+			Client:   fake.NewFakeClientWithScheme(s, obj.DeepCopy()),
+			Log:      log,
+			Operator: m,
+			Recorder: record.NewFakeRecorder(10),
+			Notifier: notifier,
+		}
+	})
+
+	It("doesn't notify the wait for the cluster to be available", func() {
+		m.ChangeAvailability(clusterID, false)
+
+		_, err := r.reconcileClusterVersion(ctx, obj, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(notifier.events).Should(BeEmpty())
+	})
+
+	It("notifies the halt when the operator can't upgrade workloads", func() {
+		obj.Spec.Workloads = []opsv1.Workload{{Name: "istio", Version: "1.8.0"}}
+		r.Operator = struct{ ops.Operator }{m}
+
+		_, err := r.reconcileClusterVersion(ctx, obj, log)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(notifier.events).Should(HaveLen(1))
+		Expect(notifier.events[0].Type).Should(Equal(opsv1.NotificationRolloutHalted))
+	})
+
+	It("forgets the deleted ClusterVersion", func() {
+		name := types.NamespacedName{Namespace: "default", Name: "deleted"}
+
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: name})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(notifier.forgotten).Should(Equal([]types.NamespacedName{name}))
+	})
+})
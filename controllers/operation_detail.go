@@ -0,0 +1,64 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+)
+
+// operationDetail gets the status of the running operation with its progress if the Operator can report it.
+func (r *ClusterVersionReconciler) operationDetail(ctx context.Context, obj *opsv1.ClusterVersion) (*ops.OperationDetail, error) {
+	if do, ok := r.Operator.(ops.OperationDetailOperator); ok {
+		detail, err := do.GetOperationDetail(ctx, *obj)
+		if !errors.Is(err, ops.ErrNotSupported) {
+			return detail, err
+		}
+	}
+	status, err := r.Operator.GetOperationStatus(ctx, *obj)
+	if err != nil {
+		return nil, err
+	}
+	return &ops.OperationDetail{Status: status}, nil
+}
+
+// setCurrentOperation copies the progress into status.currentOperation, and returns true if it has changed.
+func setCurrentOperation(obj *opsv1.ClusterVersion, detail *ops.OperationDetail) bool {
+	progress := detail.Progress
+	if progress != nil && (*progress < 0 || *progress > 100) {
+		// ignore the invalid progress not to fail updating the status
+		progress = nil
+	}
+	current := obj.Status.CurrentOperation
+	if current == nil && progress == nil && detail.Message == "" && detail.Error == "" {
+		return false
+	}
+	if current != nil && reflect.DeepEqual(current.Progress, progress) && current.Message == detail.Message && current.Error == detail.Error {
+		return false
+	}
+	obj.Status.CurrentOperation = &opsv1.CurrentOperation{
+		Progress:       progress,
+		Message:        detail.Message,
+		Error:          detail.Error,
+		LastUpdateTime: metav1.Now(),
+	}
+	return true
+}
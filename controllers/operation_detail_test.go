@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+var _ = Describe("operationDetail", func() {
+	const clusterID = "projects/test-project/locations/asia-northeast1/clusters/detail-cluster"
+	var (
+		server *fakeplugin.Server
+		obj    *opsv1.ClusterVersion
+	)
+
+	BeforeEach(func() {
+		server = fakeplugin.NewServer(fakeplugin.Config{
+			Settings: fakeplugin.Settings{OperationDuration: &metav1.Duration{Duration: time.Minute}},
+			Clusters: []fakeplugin.ClusterConfig{{ID: clusterID, Version: "1.16.13-gke.404"}},
+		})
+		op, err := server.UpgradeMaster(context.Background(), &plugin.MasterVersion{ClusterID: clusterID, Version: "1.16.15-gke.4301"})
+		Expect(err).ShouldNot(HaveOccurred())
+		obj = &opsv1.ClusterVersion{
+			Status: opsv1.ClusterVersionStatus{ClusterID: clusterID, OperationID: op.OperationID, OperationType: op.Type},
+		}
+	})
+
+	It("gets the progress from the plugin server", func() {
+		r := &ClusterVersionReconciler{Operator: ops.NewInProcessOperator(server)}
+
+		detail, err := r.operationDetail(context.Background(), obj)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(detail.Status).Should(Equal(ops.OperationStatusRunning))
+		Expect(detail.Progress).ShouldNot(BeNil())
+		Expect(detail.Message).Should(Equal("UPGRADE_MASTER is running"))
+	})
+
+	It("gets only the status from the plugin server of the protocol v1", func() {
+		r := &ClusterVersionReconciler{Operator: ops.NewInProcessOperator(struct{ plugin.ClusterServer }{server})}
+
+		detail, err := r.operationDetail(context.Background(), obj)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(detail).Should(Equal(&ops.OperationDetail{Status: ops.OperationStatusRunning}))
+	})
+})
+
+var _ = Describe("setCurrentOperation", func() {
+	progress := func(v int32) *int32 { return &v }
+
+	table.DescribeTable("copies the progress into status.currentOperation",
+		func(current *opsv1.CurrentOperation, detail ops.OperationDetail, expectedChanged bool, expectedProgress *int32) {
+			obj := &opsv1.ClusterVersion{}
+			obj.Status.CurrentOperation = current
+
+			Expect(setCurrentOperation(obj, &detail)).Should(Equal(expectedChanged))
+			if expectedProgress == nil {
+				if obj.Status.CurrentOperation != nil {
+					Expect(obj.Status.CurrentOperation.Progress).Should(BeNil())
+				}
+				return
+			}
+			Expect(obj.Status.CurrentOperation.Progress).Should(Equal(expectedProgress))
+		},
+		table.Entry("keep nothing without any progress",
+			nil, ops.OperationDetail{Status: ops.OperationStatusRunning}, false, nil),
+		table.Entry("set the progress",
+			nil, ops.OperationDetail{Status: ops.OperationStatusRunning, Progress: progress(30), Message: "upgrading nodes"}, true, progress(30)),
+		table.Entry("keep the same progress",
+			&opsv1.CurrentOperation{Progress: progress(30), Message: "upgrading nodes"},
+			ops.OperationDetail{Status: ops.OperationStatusRunning, Progress: progress(30), Message: "upgrading nodes"}, false, progress(30)),
+		table.Entry("update the progress",
+			&opsv1.CurrentOperation{Progress: progress(30), Message: "upgrading nodes"},
+			ops.OperationDetail{Status: ops.OperationStatusRunning, Progress: progress(60), Message: "upgrading nodes"}, true, progress(60)),
+		table.Entry("ignore the invalid progress",
+			nil, ops.OperationDetail{Status: ops.OperationStatusRunning, Progress: progress(120)}, false, nil),
+	)
+})
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"time"
+)
+
+// defaultSpecValidationTimeout is shorter than the timeout of the admission webhook, 10s by default.
+const defaultSpecValidationTimeout = time.Second * 5
+
+// SpecValidator validates the specs of the ClusterVersions against the capabilities of the operator of each cluster.
+// Validate is set to opsv1.SpecValidator, since the API package can't depend on the operators.
+type SpecValidator struct {
+	Operator ops.Operator
+	Log      logr.Logger
+	// Timeout is the timeout of the calls for a spec. default value is 5s.
+	Timeout time.Duration
+}
+
+// Validate rejects the clusters whose plugin servers speak an unsupported protocol version, can't upgrade them to
+// the desired version, can't upgrade the workloads which aren't Helm releases, or can't list the available versions
+// for spec.versionPolicy.
+// The spec is accepted if the plugin servers can't be asked, e.g. they're unreachable,
+// since the controller checks them again before each step.
+func (v *SpecValidator) Validate(obj *opsv1.ClusterVersion) field.ErrorList {
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = defaultSpecValidationTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errList := v.validateCapabilities(ctx, obj)
+	if err := v.validateVersionPolicy(ctx, obj); err != nil {
+		errList = append(errList, err)
+	}
+	return errList
+}
+
+func (v *SpecValidator) validateCapabilities(ctx context.Context, obj *opsv1.ClusterVersion) field.ErrorList {
+	co, ok := v.Operator.(ops.CapabilitiesOperator)
+	if !ok {
+		return nil
+	}
+	errList := field.ErrorList{}
+	path := field.NewPath("spec").Child("clusters")
+	noWorkloads := []string{}
+	for i, cluster := range obj.Spec.Clusters {
+		caps, err := co.GetCapabilities(ctx, *obj, cluster)
+		if errors.Is(err, ops.ErrUnsupportedProtocol) {
+			errList = append(errList, field.Invalid(path.Index(i).Child("opsEndpoint"), obj.Spec.GetOpsEndpoint(cluster.ID).Endpoint, err.Error()))
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ops.ErrNotSupported) {
+				v.Log.Error(err, "failed to get capabilities, the spec is accepted", "name", obj.Name, "cluster", cluster.ID)
+			}
+			continue
+		}
+		// the versions resolved by spec.versionPolicy are checked by the controller
+		if obj.Spec.VersionPolicy.GetMode() == opsv1.VersionPolicyPinned && !caps.IsVersionAvailable(cluster.Version) {
+			errList = append(errList, field.NotSupported(path.Index(i).Child("version"), cluster.Version, caps.AvailableVersions))
+		}
+		if !caps.Workloads {
+			noWorkloads = append(noWorkloads, cluster.ID)
+		}
+	}
+	if len(noWorkloads) == 0 {
+		return errList
+	}
+	for i, w := range obj.Spec.Workloads {
+		if w.Helm == nil {
+			msg := fmt.Sprintf("the plugin servers of the clusters %v can't upgrade workloads", noWorkloads)
+			errList = append(errList, field.Required(field.NewPath("spec").Child("workloads").Index(i).Child("helm"), msg))
+		}
+	}
+	return errList
+}
+
+// validateVersionPolicy rejects spec.versionPolicy if the operator of a cluster can't list the available versions.
+func (v *SpecValidator) validateVersionPolicy(ctx context.Context, obj *opsv1.ClusterVersion) *field.Error {
+	policy := obj.Spec.VersionPolicy
+	if policy.GetMode() == opsv1.VersionPolicyPinned {
+		return nil
+	}
+	path := field.NewPath("spec").Child("versionPolicy").Child("mode")
+	vo, ok := v.Operator.(ops.VersionOperator)
+	if !ok {
+		return field.Invalid(path, policy.GetMode(), "the operator can't list the available versions")
+	}
+	for _, cluster := range obj.Spec.Clusters {
+		_, err := vo.GetAvailableVersions(ctx, *obj, cluster, policy.Channel)
+		if errors.Is(err, ops.ErrNotSupported) {
+			return field.Invalid(path, policy.GetMode(), fmt.Sprintf("the operator of cluster %s can't list the available versions", cluster.ID))
+		}
+		if err != nil {
+			v.Log.Error(err, "failed to get available versions, the spec is accepted", "name", obj.Name, "cluster", cluster.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"net/http"
+	"net/http/httptest"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("SpecValidator", func() {
+	It("rejects the versions the clusters can't be upgraded to", func() {
+		mc := makeClusterVersion("default", "spec-validator-mc-1")
+		server := fakeplugin.NewServer(fakeplugin.Config{
+			Clusters: []fakeplugin.ClusterConfig{
+				{ID: mc.Spec.Clusters[0].ID, Version: "1.16.13-gke.404", AvailableVersions: []string{"1.16.15-gke.4301"}},
+				{ID: mc.Spec.Clusters[1].ID, Version: "1.16.13-gke.404"},
+			},
+		})
+		v := &SpecValidator{Operator: ops.NewInProcessOperator(server), Log: ctrl.Log}
+
+		errList := v.Validate(mc)
+		Expect(errList).Should(HaveLen(1))
+		Expect(errList[0].Field).Should(Equal("spec.clusters[0].version"))
+
+		By("the versions resolved by the version policy aren't validated")
+		mc.Spec.VersionPolicy = &opsv1.VersionPolicy{Mode: opsv1.VersionPolicyLatestPatch}
+		Expect(v.Validate(mc)).Should(BeEmpty())
+	})
+
+	It("rejects the version policy if the plugin server can't list the available versions", func() {
+		mc := makeClusterVersion("default", "spec-validator-mc-4")
+		mc.Spec.VersionPolicy = &opsv1.VersionPolicy{Mode: opsv1.VersionPolicyLatestPatch}
+		server := fakeplugin.NewServer(fakeplugin.Config{
+			Clusters: []fakeplugin.ClusterConfig{
+				{ID: mc.Spec.Clusters[0].ID, Version: "1.16.13-gke.404"},
+				{ID: mc.Spec.Clusters[1].ID, Version: "1.16.13-gke.404"},
+			},
+		})
+
+		By("the plugin server of the protocol v2 is accepted")
+		v := &SpecValidator{Operator: ops.NewInProcessOperator(server), Log: ctrl.Log}
+		Expect(v.Validate(mc)).Should(BeEmpty())
+
+		By("the plugin server of the protocol v1 is rejected")
+		v = &SpecValidator{Operator: ops.NewInProcessOperator(struct{ plugin.ClusterServer }{server}), Log: ctrl.Log}
+		errList := v.Validate(mc)
+		Expect(errList).Should(HaveLen(1))
+		Expect(errList[0].Field).Should(Equal("spec.versionPolicy.mode"))
+	})
+
+	It("rejects the workloads if the plugin server can't upgrade them", func() {
+		mc := makeClusterVersion("default", "spec-validator-mc-5")
+		mc.Spec.Workloads = []opsv1.Workload{
+			{Name: "istio", Version: "1.8.0"},
+			{Name: "nginx", Version: "3.4.0", Helm: &opsv1.HelmWorkload{ReleaseName: "nginx", Namespace: "ingress", Chart: "ingress-nginx", RepoURL: "https://kubernetes.github.io/ingress-nginx"}},
+		}
+		server := fakeplugin.NewServer(fakeplugin.Config{
+			Clusters: []fakeplugin.ClusterConfig{
+				{ID: mc.Spec.Clusters[0].ID, Version: "1.16.13-gke.404"},
+				{ID: mc.Spec.Clusters[1].ID, Version: "1.16.13-gke.404"},
+			},
+		})
+
+		By("the plugin server of the protocol v2 is accepted")
+		v := &SpecValidator{Operator: ops.NewInProcessOperator(server), Log: ctrl.Log}
+		Expect(v.Validate(mc)).Should(BeEmpty())
+
+		By("the plugin server of the protocol v1 is rejected except for the Helm workloads")
+		v = &SpecValidator{Operator: ops.NewInProcessOperator(struct{ plugin.ClusterServer }{server}), Log: ctrl.Log}
+		errList := v.Validate(mc)
+		Expect(errList).Should(HaveLen(1))
+		Expect(errList[0].Field).Should(Equal("spec.workloads[0].helm"))
+	})
+
+	It("rejects the plugin server speaking an unsupported protocol version", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"protocolVersion": "v100"}`))
+		}))
+		defer server.Close()
+		mc := makeClusterVersion("default", "spec-validator-mc-2")
+		mc.Spec.OpsEndpoint = opsv1.OpsEndpoint{Protocol: opsv1.OpsProtocolHTTP, Endpoint: server.URL}
+		v := &SpecValidator{Operator: ops.NewHTTPOperator(nil), Log: ctrl.Log}
+
+		errList := v.Validate(mc)
+		Expect(errList).Should(HaveLen(2))
+		Expect(errList[0].Field).Should(Equal("spec.clusters[0].opsEndpoint"))
+	})
+
+	It("accepts the spec if the plugin server is unreachable", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		mc := makeClusterVersion("default", "spec-validator-mc-3")
+		mc.Spec.OpsEndpoint = opsv1.OpsEndpoint{Protocol: opsv1.OpsProtocolHTTP, Endpoint: server.URL}
+		v := &SpecValidator{Operator: ops.NewHTTPOperator(nil), Log: ctrl.Log}
+
+		Expect(v.Validate(mc)).Should(BeEmpty())
+	})
+})
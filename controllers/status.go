@@ -0,0 +1,129 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+type statusBaseContextKey struct{}
+
+// statusBase is the status of the ClusterVersion as last read or written in a reconciliation.
+type statusBase struct {
+	status opsv1.ClusterVersionStatus
+}
+
+// withStatusBase returns the context in which updateStatus writes only the status fields changed since obj was read,
+// so that a stale obj doesn't revert the fields written by others in the meantime.
+func withStatusBase(ctx context.Context, obj *opsv1.ClusterVersion) context.Context {
+	return context.WithValue(ctx, statusBaseContextKey{}, &statusBase{status: *obj.Status.DeepCopy()})
+}
+
+func statusBaseFromContext(ctx context.Context) *statusBase {
+	b, _ := ctx.Value(statusBaseContextKey{}).(*statusBase)
+	return b
+}
+
+// mergeStatus returns latest with the top-level fields of status which differ from base.
+func mergeStatus(latest, base, status opsv1.ClusterVersionStatus) (opsv1.ClusterVersionStatus, error) {
+	fields := make([]map[string]interface{}, 3)
+	for i, s := range []opsv1.ClusterVersionStatus{latest, base, status} {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return latest, err
+		}
+		if err := json.Unmarshal(b, &fields[i]); err != nil {
+			return latest, err
+		}
+	}
+	merged, before, after := fields[0], fields[1], fields[2]
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			delete(merged, k)
+		}
+	}
+	for k, v := range after {
+		if !reflect.DeepEqual(before[k], v) {
+			merged[k] = v
+		}
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return latest, err
+	}
+	ret := opsv1.ClusterVersionStatus{}
+	err = json.Unmarshal(b, &ret)
+	return ret, err
+}
+
+// updateStatus writes the fields of obj.Status changed in this reconciliation into the latest ClusterVersion,
+// and retries it on conflicts until it succeeds or ctx is done, so that e.g. the ID of the operation just started
+// isn't dropped because obj is stale. Every field which differs from the latest one is written if ctx has no base.
+// Only the metadata and the status of obj are refreshed, and the spec resolved in memory is kept.
+func (r *ClusterVersionReconciler) updateStatus(ctx context.Context, obj *opsv1.ClusterVersion, log logr.Logger) (ctrl.Result, error) {
+	status := obj.Status.DeepCopy()
+	base := statusBaseFromContext(ctx)
+	backoff := retry.DefaultBackoff
+	for {
+		latest := &opsv1.ClusterVersion{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: obj.Name}, latest)
+		if err == nil {
+			err = r.patchStatus(ctx, latest, base, *status)
+		}
+		if err == nil {
+			if base != nil {
+				base.status = *latest.Status.DeepCopy()
+			}
+			obj.ObjectMeta = latest.ObjectMeta
+			obj.Status = latest.Status
+			return ctrl.Result{}, nil
+		}
+		if !k8serrors.IsConflict(err) {
+			log.Error(err, "failed to update status")
+			return ctrl.Result{}, err
+		}
+		select {
+		case <-ctx.Done():
+			log.Error(ctx.Err(), "failed to update status")
+			return ctrl.Result{}, ctx.Err()
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+func (r *ClusterVersionReconciler) patchStatus(ctx context.Context, latest *opsv1.ClusterVersion, base *statusBase, status opsv1.ClusterVersionStatus) error {
+	patch := client.MergeFromWithOptions(latest.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if base == nil {
+		latest.Status = status
+	} else {
+		merged, err := mergeStatus(latest.Status, base.status, status)
+		if err != nil {
+			return err
+		}
+		latest.Status = merged
+	}
+	return r.Client.Status().Patch(ctx, latest, patch)
+}
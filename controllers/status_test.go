@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"time"
+)
+
+// conflictClient fails the status writes with a conflict as many times as conflicts.
+// interrupt is called before each status write, e.g. to write the status concurrently.
+type conflictClient struct {
+	client.Client
+	conflicts int
+	interrupt func(obj runtime.Object)
+}
+
+func (c *conflictClient) Status() client.StatusWriter {
+	return &conflictStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type conflictStatusWriter struct {
+	client.StatusWriter
+	client *conflictClient
+}
+
+func (w *conflictStatusWriter) conflict(obj runtime.Object) error {
+	if w.client.interrupt != nil {
+		w.client.interrupt(obj)
+	}
+	if w.client.conflicts == 0 {
+		return nil
+	}
+	w.client.conflicts--
+	return k8serrors.NewConflict(schema.GroupResource{Group: opsv1.GroupVersion.Group, Resource: "clusterversions"}, "status-test", nil)
+}
+
+func (w *conflictStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := w.conflict(obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *conflictStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.conflict(obj); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+var _ = Describe("updateStatus", func() {
+	var (
+		obj *opsv1.ClusterVersion
+		cl  *conflictClient
+		r   *ClusterVersionReconciler
+		key = client.ObjectKey{Namespace: "default", Name: "status-test"}
+		ctx = context.Background()
+		log = ctrl.Log.WithName("test")
+	)
+	// update writes the operation into the status and returns the stored ClusterVersion
+	update := func() *opsv1.ClusterVersion {
+		obj.Status.ClusterID = "cluster-1"
+		obj.Status.OperationID = "op-1"
+		obj.Status.OperationType = "UPGRADE_MASTER"
+		_, err := r.updateStatus(ctx, obj, log)
+		Expect(err).ShouldNot(HaveOccurred(), "the status should be written in retries")
+
+		stored := &opsv1.ClusterVersion{}
+		Expect(cl.Get(ctx, key, stored)).Should(Succeed())
+		Expect(stored.Status.OperationID).Should(Equal("op-1"))
+		Expect(stored.Status.OperationType).Should(Equal("UPGRADE_MASTER"))
+		Expect(obj.ResourceVersion).Should(Equal(stored.ResourceVersion), "the resource version should be refreshed")
+		Expect(obj.Spec.Clusters[0].Version).Should(Equal("1.16.13-gke.404"), "the spec in memory should be kept")
+		return stored
+	}
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name, ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{{ID: "cluster-1", Version: "1.16.13-gke.404"}},
+			},
+		}
+		cl = &conflictClient{Client: fake.NewFakeClientWithScheme(s, obj.DeepCopy())}
+		r = &ClusterVersionReconciler{Client: cl}
+	})
+
+	It("writes the status of the stale object without overwriting the spec written in the meantime", func() {
+		latest := &opsv1.ClusterVersion{}
+		Expect(cl.Get(ctx, key, latest)).Should(Succeed())
+		latest.Spec.Clusters[0].Version = "1.17.0"
+		Expect(cl.Update(ctx, latest)).Should(Succeed())
+
+		stored := update()
+		Expect(stored.Spec.Clusters[0].Version).Should(Equal("1.17.0"))
+	})
+
+	It("retries the conflicting writes", func() {
+		cl.conflicts = 3
+
+		update()
+		Expect(cl.conflicts).Should(Equal(0))
+	})
+
+	It("writes only the fields changed since the object has been read", func() {
+		ctx := withStatusBase(ctx, obj)
+		latest := &opsv1.ClusterVersion{}
+		Expect(cl.Get(ctx, key, latest)).Should(Succeed())
+		latest.Status.InPlaceUpgrades = []string{"cluster-2"}
+		Expect(cl.Status().Update(ctx, latest)).Should(Succeed())
+
+		obj.Status.OperationID = "op-1"
+		_, err := r.updateStatus(ctx, obj, log)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		stored := &opsv1.ClusterVersion{}
+		Expect(cl.Get(ctx, key, stored)).Should(Succeed())
+		Expect(stored.Status.OperationID).Should(Equal("op-1"))
+		Expect(stored.Status.InPlaceUpgrades).Should(Equal([]string{"cluster-2"}))
+		Expect(obj.Status.InPlaceUpgrades).Should(Equal([]string{"cluster-2"}))
+	})
+
+	It("gives up the conflicting writes when the context is done", func() {
+		cl.conflicts = 1 << 30
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		obj.Status.OperationID = "op-1"
+		_, err := r.updateStatus(ctx, obj, log)
+		Expect(err).Should(Equal(context.DeadlineExceeded))
+	})
+})
+
+var _ = Describe("startOperation", func() {
+	const clusterID = "projects/test-project/locations/asia-northeast1/clusters/status-cluster"
+	var (
+		cl  *conflictClient
+		m   *mockOperator
+		r   *ClusterVersionReconciler
+		key = client.ObjectKey{Namespace: "default", Name: "start-test"}
+		ctx = context.Background()
+	)
+
+	BeforeEach(func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		obj := &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name, ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters: []opsv1.Cluster{{ID: clusterID, Version: "1.17.13-gke.1400"}},
+			},
+		}
+		cl = &conflictClient{Client: fake.NewFakeClientWithScheme(s, obj.DeepCopy())}
+		m = newMockOperator()
+		m.AddClusterVersion(&ops.ClusterVersion{Master: ops.MasterVersion{ClusterID: clusterID, Version: "1.16.13-gke.404"}})
+		m.AddCapabilities(clusterID, &ops.Capabilities{ProtocolVersion: ops.ProtocolVersionV1})
+		r = &ClusterVersionReconciler{
+			Client:   cl,
+			Log:      ctrl.Log.WithName("test"),
+			Recorder: record.NewFakeRecorder(10),
+			Operator: m,
+		}
+	})
+
+	It("neither loses nor starts again the operation whose status write has conflicted", func() {
+		By("writing the status concurrently when the operation ID is written")
+		cl.interrupt = func(o runtime.Object) {
+			if o.(*opsv1.ClusterVersion).Status.OperationID == "" {
+				return
+			}
+			cl.interrupt = nil
+			latest := &opsv1.ClusterVersion{}
+			Expect(cl.Get(ctx, key, latest)).Should(Succeed())
+			latest.Status.InPlaceUpgrades = []string{"other-cluster"}
+			Expect(cl.Client.Status().Update(ctx, latest)).Should(Succeed())
+		}
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.executedOperations[key.Name]).Should(HaveLen(1))
+		started := m.executedOperations[key.Name][0]
+
+		stored := &opsv1.ClusterVersion{}
+		Expect(cl.Get(ctx, key, stored)).Should(Succeed())
+		Expect(stored.Status.OperationID).Should(Equal(started.OperationID))
+		Expect(stored.Status.PendingOperation).Should(BeNil())
+		Expect(stored.Status.InPlaceUpgrades).Should(Equal([]string{"other-cluster"}))
+
+		By("reconciling again after the operation has completed")
+		Eventually(func() ops.OperationStatus {
+			status, _ := m.GetOperationStatus(ctx, *stored)
+			return status
+		}).Should(Equal(ops.OperationStatusDone))
+		_, err = r.Reconcile(ctrl.Request{NamespacedName: key})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(m.executedOperations[key.Name]).Should(HaveLen(1))
+		Expect(cl.Get(ctx, key, stored)).Should(Succeed())
+		Expect(stored.Status.History).Should(HaveLen(1))
+		Expect(stored.Status.History[0].OperationID).Should(Equal(started.OperationID))
+		Expect(stored.Status.History[0].Result).Should(Equal(opsv1.OperationResultSucceeded))
+	})
+})
@@ -17,8 +17,10 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"path/filepath"
@@ -43,6 +45,7 @@ var k8sClient client.Client
 var testEnv *envtest.Environment
 var stopCh chan struct{}
 var operator = newMockOperator()
+var remoteClients = newMockRemoteClients()
 var syncPeriod = time.Millisecond * 100
 
 func TestAPIs(t *testing.T) {
@@ -90,6 +93,11 @@ var _ = BeforeSuite(func(done Done) {
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("clusterversion_controller"),
 		Operator: operator,
+
+		RecordOperations: true,
+		RemoteClient: func(_ context.Context, _ opsv1.ClusterVersion, cluster opsv1.Cluster) (kubernetes.Interface, error) {
+			return remoteClients.Get(cluster.ID), nil
+		},
 	}
 	err = rc.SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
@@ -0,0 +1,80 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/verify"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	reasonVerificationFailed = "VerificationFailed"
+)
+
+// withVerification performs the operation after the upgraded cluster has passed the verification if spec.verification is defined.
+// The result is recorded in the PostUpgradeVerified condition, and the cluster stays serviced out until it passes.
+func (r *ClusterVersionReconciler) withVerification(ctx context.Context, obj *opsv1.ClusterVersion, cluster opsv1.Cluster, log logr.Logger, op operationFunc) (ctrl.Result, error) {
+	policy := obj.Spec.Verification
+	if policy == nil {
+		return op()
+	}
+	if r.RemoteClient == nil {
+		log.Error(errors.New("remote client isn't configured"), "failed to verify cluster")
+		return ctrl.Result{}, nil
+	}
+	client, err := r.RemoteClient(ctx, *obj, cluster)
+	if err != nil {
+		log.Error(err, "failed to connect to cluster")
+		return ctrl.Result{}, nil
+	}
+	result, err := verify.Verify(ctx, client, cluster.Version, *policy)
+	if err != nil {
+		log.Error(err, "failed to verify cluster")
+		return ctrl.Result{}, nil
+	}
+
+	status := metav1.ConditionFalse
+	if result.Verified {
+		status = metav1.ConditionTrue
+	}
+	msg := fmt.Sprintf("cluster %s: %s", cluster.ID, result.Message)
+	if !result.Verified && !meta.IsStatusConditionPresentAndEqual(obj.Status.Conditions, opsv1.ConditionPostUpgradeVerified, status) {
+		r.Recorder.Event(obj, corev1.EventTypeWarning, reasonVerificationFailed, msg)
+		r.notify(ctx, obj, opsv1.NotificationRolloutHalted, cluster.ID, msg)
+	}
+	meta.SetStatusCondition(&obj.Status.Conditions, metav1.Condition{
+		Type:               opsv1.ConditionPostUpgradeVerified,
+		Status:             status,
+		Reason:             result.Reason,
+		Message:            msg,
+		ObservedGeneration: obj.Generation,
+	})
+	if result.Verified {
+		log.Info(fmt.Sprintf("cluster %s has been verified", cluster.ID))
+		return op()
+	}
+	log.Info(msg)
+	return r.updateStatus(ctx, obj, log)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
+)
+
+// resolveVersions records the versions resolved by spec.versionPolicy in status.resolvedVersions, and returns true
+// if they have changed. The desired versions are read from there by opsv1.ClusterVersion.DesiredCluster.
+// The versions resolved before are kept during the rollout, so a newer patch starts the next rollout after it completes.
+func (r *ClusterVersionReconciler) resolveVersions(ctx context.Context, obj *opsv1.ClusterVersion) (bool, error) {
+	policy := obj.Spec.VersionPolicy
+	if policy.GetMode() == opsv1.VersionPolicyPinned {
+		changed := len(obj.Status.ResolvedVersions) > 0
+		obj.Status.ResolvedVersions = nil
+		return changed, nil
+	}
+	vo, ok := r.Operator.(ops.VersionOperator)
+	if !ok {
+		return false, ops.ErrNotSupported
+	}
+	inRollout := obj.Status.RolloutStartTime != nil
+	resolved := make([]opsv1.ResolvedVersion, len(obj.Spec.Clusters))
+	for i, cluster := range obj.Spec.Clusters {
+		version, ok := obj.Status.GetResolvedVersion(cluster.ID)
+		if !inRollout || !ok {
+			available, err := vo.GetAvailableVersions(ctx, *obj, cluster, policy.Channel)
+			if err != nil {
+				return false, err
+			}
+			version, err = policy.Resolve(cluster.Version, available)
+			if err != nil {
+				return false, fmt.Errorf("failed to resolve version of cluster %s: %w", cluster.ID, err)
+			}
+		}
+		resolved[i] = opsv1.ResolvedVersion{ClusterID: cluster.ID, Version: version}
+	}
+	changed := !reflect.DeepEqual(obj.Status.ResolvedVersions, resolved)
+	obj.Status.ResolvedVersions = resolved
+	return changed, nil
+}
+
+// waitForMaintenanceWindow returns true with the result to requeue the ClusterVersion when the maintenance window opens,
+// if the rollout of the versions resolved by spec.versionPolicy can't start now. The rollout which has started continues.
+func (r *ClusterVersionReconciler) waitForMaintenanceWindow(obj *opsv1.ClusterVersion, now time.Time, log logr.Logger) (ctrl.Result, bool) {
+	policy := obj.Spec.VersionPolicy
+	if policy.GetMode() == opsv1.VersionPolicyPinned || obj.Status.RolloutStartTime != nil {
+		return ctrl.Result{}, false
+	}
+	window := policy.MaintenanceWindow
+	if window.Contains(now) {
+		return ctrl.Result{}, false
+	}
+	next := window.NextOpen(now)
+	if next.IsZero() {
+		log.Info("the rollout waits for the maintenance window which never opens")
+		return ctrl.Result{}, true
+	}
+	log.Info(fmt.Sprintf("the rollout waits for the maintenance window which opens at %s", next.Format(time.RFC3339)))
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, true
+}
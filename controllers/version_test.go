@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"time"
+)
+
+var _ = Describe("resolveVersions", func() {
+	const clusterID = "projects/test-project/locations/asia-northeast1/clusters/version-cluster"
+	var (
+		obj *opsv1.ClusterVersion
+		m   *mockOperator
+		r   *ClusterVersionReconciler
+		ctx = context.Background()
+	)
+
+	BeforeEach(func() {
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "version-test", ResourceVersion: "1"},
+			Spec: opsv1.ClusterVersionSpec{
+				Clusters:      []opsv1.Cluster{{ID: clusterID, Version: "1.16"}},
+				VersionPolicy: &opsv1.VersionPolicy{Mode: opsv1.VersionPolicyLatestPatch},
+			},
+		}
+		m = newMockOperator()
+		m.AddClusterVersion(&ops.ClusterVersion{Master: ops.MasterVersion{ClusterID: clusterID, Version: "1.16.13-gke.404"}})
+		m.AddCapabilities(clusterID, &ops.Capabilities{ProtocolVersion: ops.ProtocolVersionV1})
+		m.AddAvailableVersions(clusterID, "1.16.13-gke.404", "1.16.15-gke.4301")
+		r = &ClusterVersionReconciler{Operator: m}
+	})
+
+	It("records the resolved versions in status without modifying the spec", func() {
+		changed, err := r.resolveVersions(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changed).Should(BeTrue())
+		Expect(obj.Status.ResolvedVersions).Should(Equal([]opsv1.ResolvedVersion{{ClusterID: clusterID, Version: "1.16.15-gke.4301"}}))
+		Expect(obj.Spec.Clusters[0].Version).Should(Equal("1.16"))
+		Expect(obj.DesiredCluster(obj.Spec.Clusters[0]).Version).Should(Equal("1.16.15-gke.4301"))
+
+		changed, err = r.resolveVersions(ctx, obj)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(changed).Should(BeFalse())
+	})
+
+	It("passes the resolved version to the hooks", func() {
+		s := runtime.NewScheme()
+		Expect(opsv1.AddToScheme(s)).Should(Succeed())
+		Expect(batchv1.AddToScheme(s)).Should(Succeed())
+		obj.Spec.Hooks = &opsv1.Hooks{
+			PreUpgrade: &runtime.RawExtension{
+				Raw: []byte(`{"spec":{"template":{"spec":{"restartPolicy":"Never","containers":[{"name":"smoke-test","image":"busybox"}]}}}}`),
+			},
+		}
+		now := metav1.Now()
+		obj.Status.RolloutStartTime = &now
+		obj.Status.ResolvedVersions = []opsv1.ResolvedVersion{{ClusterID: clusterID, Version: "1.16.15-gke.4301"}}
+		cl := fake.NewFakeClientWithScheme(s, obj.DeepCopy())
+		r.Client = cl
+		r.Scheme = s
+		r.Log = ctrl.Log.WithName("test")
+		r.Recorder = record.NewFakeRecorder(10)
+
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: client.ObjectKey{Namespace: obj.Namespace, Name: obj.Name}})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		jobs := &batchv1.JobList{}
+		Expect(cl.List(ctx, jobs, client.InNamespace(obj.Namespace))).Should(Succeed())
+		Expect(jobs.Items).Should(HaveLen(1))
+		Expect(jobs.Items[0].Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: "TARGET_VERSION", Value: "1.16.15-gke.4301"}))
+	})
+})
+
+var _ = Describe("waitForMaintenanceWindow", func() {
+	var (
+		obj *opsv1.ClusterVersion
+		r   = &ClusterVersionReconciler{}
+		log = ctrl.Log.WithName("test")
+		// 2021-01-02 is Saturday
+		saturday = time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		obj = &opsv1.ClusterVersion{
+			Spec: opsv1.ClusterVersionSpec{
+				VersionPolicy: &opsv1.VersionPolicy{
+					Mode:              opsv1.VersionPolicyLatestPatch,
+					MaintenanceWindow: &opsv1.MaintenanceWindow{Start: "03:00", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+				},
+			},
+		}
+	})
+
+	It("waits until the window opens", func() {
+		ret, wait := r.waitForMaintenanceWindow(obj, saturday, log)
+		Expect(wait).Should(BeTrue())
+		Expect(ret.RequeueAfter).Should(Equal(3 * time.Hour))
+	})
+
+	It("doesn't wait in the window", func() {
+		_, wait := r.waitForMaintenanceWindow(obj, saturday.Add(4*time.Hour), log)
+		Expect(wait).Should(BeFalse())
+	})
+
+	It("doesn't wait for the rollout which has started", func() {
+		started := metav1.NewTime(saturday)
+		obj.Status.RolloutStartTime = &started
+		_, wait := r.waitForMaintenanceWindow(obj, saturday, log)
+		Expect(wait).Should(BeFalse())
+	})
+
+	It("doesn't wait for the pinned versions", func() {
+		obj.Spec.VersionPolicy.Mode = opsv1.VersionPolicyPinned
+		_, wait := r.waitForMaintenanceWindow(obj, saturday, log)
+		Expect(wait).Should(BeFalse())
+	})
+})
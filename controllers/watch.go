@@ -0,0 +1,168 @@
+/*
+Copyright 2020 taisho6339.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sync"
+	"time"
+)
+
+// watchOpenTimeout limits how long opening a stream may take until the first status arrives.
+const watchOpenTimeout = time.Second * 30
+
+// operationWatch is the stream of the operation status of a ClusterVersion.
+type operationWatch struct {
+	operationID string
+	cancel      context.CancelFunc
+}
+
+// operationWatches holds a stream per in-flight operation, and sends the owning ClusterVersion to events
+// whenever the operation status changes.
+type operationWatches struct {
+	events  chan event.GenericEvent
+	watches map[types.NamespacedName]*operationWatch
+	// stopped is true while the controller isn't the leader, and no stream is started then.
+	stopped bool
+	lock    sync.Mutex
+}
+
+func newOperationWatches() *operationWatches {
+	return &operationWatches{
+		events:  make(chan event.GenericEvent),
+		watches: map[types.NamespacedName]*operationWatch{},
+	}
+}
+
+// watchOperation starts streaming the status of the operation in obj.Status unless it's already streamed.
+// Nothing is started if the Operator doesn't support streaming, and the status is polled every sync period as before.
+// The stream is opened in background, so a plugin server which doesn't respond doesn't block the reconciliations.
+func (r *ClusterVersionReconciler) watchOperation(obj *opsv1.ClusterVersion, log logr.Logger) {
+	wo, ok := r.Operator.(ops.WatchOperator)
+	if !ok || r.watches == nil {
+		return
+	}
+	name := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+	w := r.watches
+	w.lock.Lock()
+	if w.stopped {
+		w.lock.Unlock()
+		return
+	}
+	if current, ok := w.watches[name]; ok {
+		if current.operationID == obj.Status.OperationID {
+			w.lock.Unlock()
+			return
+		}
+		current.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	self := &operationWatch{operationID: obj.Status.OperationID, cancel: cancel}
+	w.watches[name] = self
+	w.lock.Unlock()
+
+	target := obj.DeepCopy()
+	go func() {
+		defer cancel()
+		timer := time.AfterFunc(watchOpenTimeout, cancel)
+		ch, err := wo.WatchOperation(ctx, *target)
+		if !timer.Stop() && err == nil {
+			err = errors.New("timed out opening the stream")
+		}
+		if errors.Is(err, ops.ErrNotSupported) {
+			// keep the entry not to try again until the next operation
+			return
+		}
+		if err != nil {
+			log.Error(err, "failed to watch operation")
+			w.forget(name, self)
+			return
+		}
+		log.V(1).Info("watching operation", "operation_id", target.Status.OperationID)
+
+		last := ops.OperationStatus("")
+		for status := range ch {
+			if status == last {
+				continue
+			}
+			last = status
+			if !w.enqueue(ctx, target) {
+				return
+			}
+		}
+		// the stream has ended, so reconcile to poll the final status
+		w.forget(name, self)
+		w.enqueue(ctx, target)
+	}()
+}
+
+// stopWatchingOperation stops the stream of the ClusterVersion if any.
+func (r *ClusterVersionReconciler) stopWatchingOperation(name types.NamespacedName) {
+	if r.watches == nil {
+		return
+	}
+	w := r.watches
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if current, ok := w.watches[name]; ok {
+		current.cancel()
+		delete(w.watches, name)
+	}
+}
+
+// forget removes the entry of the ClusterVersion unless it has been replaced by another stream.
+func (w *operationWatches) forget(name types.NamespacedName, watch *operationWatch) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.watches[name] == watch {
+		delete(w.watches, name)
+	}
+}
+
+// start allows streaming the operations again after stop.
+func (w *operationWatches) start() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stopped = false
+}
+
+// stop closes all streams, and starts no stream until start is called.
+func (w *operationWatches) stop() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.stopped = true
+	for name, current := range w.watches {
+		current.cancel()
+		delete(w.watches, name)
+	}
+}
+
+// enqueue sends the ClusterVersion to be reconciled. It returns false if ctx is done.
+func (w *operationWatches) enqueue(ctx context.Context, obj *opsv1.ClusterVersion) bool {
+	select {
+	case w.events <- event.GenericEvent{Meta: obj, Object: obj}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sync"
+	"time"
+)
+
+// hangingWatchOperator is the WatchOperator whose streams never send the first status.
+type hangingWatchOperator struct {
+	ops.Operator
+	canceled chan struct{}
+	once     sync.Once
+}
+
+func (o *hangingWatchOperator) WatchOperation(ctx context.Context, _ opsv1.ClusterVersion) (<-chan ops.OperationStatus, error) {
+	<-ctx.Done()
+	o.once.Do(func() { close(o.canceled) })
+	return nil, ctx.Err()
+}
+
+var _ = Describe("watchOperation", func() {
+	const clusterID = "projects/test-project/locations/asia-northeast1/clusters/watch-cluster"
+	var (
+		server *fakeplugin.Server
+		obj    *opsv1.ClusterVersion
+		name   = types.NamespacedName{Namespace: "default", Name: "watch-test"}
+		log    = ctrl.Log.WithName("test")
+	)
+	// watching returns the number of the streams
+	watching := func(r *ClusterVersionReconciler) func() int {
+		return func() int {
+			r.watches.lock.Lock()
+			defer r.watches.lock.Unlock()
+			return len(r.watches.watches)
+		}
+	}
+
+	BeforeEach(func() {
+		server = fakeplugin.NewServer(fakeplugin.Config{
+			Settings: fakeplugin.Settings{OperationDuration: &metav1.Duration{Duration: time.Millisecond * 300}},
+			Clusters: []fakeplugin.ClusterConfig{{ID: clusterID, Version: "1.16.13-gke.404"}},
+		})
+		op, err := server.UpgradeMaster(context.Background(), &plugin.MasterVersion{ClusterID: clusterID, Version: "1.16.15-gke.4301"})
+		Expect(err).ShouldNot(HaveOccurred())
+		obj = &opsv1.ClusterVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+			Status:     opsv1.ClusterVersionStatus{ClusterID: clusterID, OperationID: op.OperationID, OperationType: op.Type},
+		}
+	})
+
+	It("enqueues the ClusterVersion whenever the status in the stream changes", func() {
+		r := &ClusterVersionReconciler{Operator: ops.NewInProcessOperator(server), watches: newOperationWatches()}
+
+		r.watchOperation(obj, log)
+		r.watchOperation(obj, log)
+		Expect(watching(r)()).Should(Equal(1))
+
+		By("[check] the running status, the done status and the end of the stream are enqueued")
+		for i := 0; i < 3; i++ {
+			Eventually(r.watches.events, time.Second*5).Should(Receive())
+		}
+		Eventually(watching(r)).Should(BeZero())
+		Consistently(r.watches.events, "200ms").ShouldNot(Receive())
+	})
+
+	It("doesn't stream the operation if the plugin server can't", func() {
+		r := &ClusterVersionReconciler{Operator: ops.NewInProcessOperator(struct{ plugin.ClusterServer }{server}), watches: newOperationWatches()}
+
+		r.watchOperation(obj, log)
+		Consistently(r.watches.events, "200ms").ShouldNot(Receive())
+		By("[check] the entry is kept not to try again until the next operation")
+		Expect(watching(r)()).Should(Equal(1))
+		r.stopWatchingOperation(name)
+		Expect(watching(r)()).Should(BeZero())
+	})
+
+	It("closes the streams on stop and starts none until start", func() {
+		r := &ClusterVersionReconciler{Operator: ops.NewInProcessOperator(server), watches: newOperationWatches()}
+
+		r.watchOperation(obj, log)
+		Expect(watching(r)()).Should(Equal(1))
+		r.watches.stop()
+		Expect(watching(r)()).Should(BeZero())
+		r.watchOperation(obj, log)
+		Expect(watching(r)()).Should(BeZero())
+
+		r.watches.start()
+		r.watchOperation(obj, log)
+		Expect(watching(r)()).Should(Equal(1))
+		r.watches.stop()
+	})
+
+	It("doesn't block the reconciliations while the stream is being opened", func() {
+		o := &hangingWatchOperator{Operator: ops.NewInProcessOperator(server), canceled: make(chan struct{})}
+		r := &ClusterVersionReconciler{Operator: o, watches: newOperationWatches()}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.watchOperation(obj, log)
+			other := obj.DeepCopy()
+			other.Name = "watch-test-other"
+			r.watchOperation(other, log)
+			r.stopWatchingOperation(name)
+		}()
+		Eventually(done, time.Second).Should(BeClosed())
+
+		By("[check] the stream being opened is canceled")
+		Eventually(o.canceled, time.Second).Should(BeClosed())
+		r.watches.stop()
+	})
+})
@@ -17,11 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"time"
 
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/notify"
 	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/remote"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/tracing"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -55,12 +60,36 @@ func main() {
 	var enableLeaderElection bool
 	var debug bool
 	var syncPeriodSeconds int
+	var recordOperations bool
+	var otlpEndpoint string
+	var otlpInsecure bool
+	var traceSampleRatio float64
+	var fakePluginConfig string
+	var maxConcurrentReconciles int
+	var rateLimitBaseDelay time.Duration
+	var rateLimitMaxDelay time.Duration
+	var operationQPS float64
+	var operationBurst int
+	var operatorCacheTTL time.Duration
+	var gracefulShutdownTimeout time.Duration
 	flag.IntVar(&syncPeriodSeconds, "sync-period-seconds", 60, "The period controller will sync after when no event occurs.")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&debug, "debug", false, "Enable debug mode. if debug is true, controller outputs logs of debug level.")
+	flag.BoolVar(&recordOperations, "record-cluster-operations", false, "Record every operation as a ClusterOperation resource owned by the ClusterVersion.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "", "The address of the OTLP collector to export traces to. Tracing is disabled if it's empty.")
+	flag.BoolVar(&otlpInsecure, "otlp-insecure", false, "Export traces to the OTLP collector without TLS.")
+	flag.Float64Var(&traceSampleRatio, "trace-sample-ratio", 1, "The ratio of rollouts to be traced.")
+	flag.StringVar(&fakePluginConfig, "fake-plugin-config", "", "The fleet config of the in-process fake plugin. The \"fake\" provider is registered if it's set.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1, "The number of ClusterVersions reconciled in parallel.")
+	flag.DurationVar(&rateLimitBaseDelay, "rate-limit-base-delay", 5*time.Millisecond, "The first delay to requeue a ClusterVersion whose reconciliation failed. It doubles on every failure.")
+	flag.DurationVar(&rateLimitMaxDelay, "rate-limit-max-delay", 1000*time.Second, "The maximum delay to requeue a ClusterVersion whose reconciliation failed.")
+	flag.Float64Var(&operationQPS, "operation-qps", 1, "The number of mutating calls to the operators per second shared by all ClusterVersions. Unlimited if it's 0.")
+	flag.IntVar(&operationBurst, "operation-burst", 10, "The number of mutating calls to the operators allowed in a burst over --operation-qps.")
+	flag.DurationVar(&operatorCacheTTL, "operator-cache-ttl", 0, "The duration the cluster statuses and versions are cached across reconciliations. They're cached only within a reconciliation if it's 0.")
+	flag.DurationVar(&gracefulShutdownTimeout, "graceful-shutdown-timeout", 30*time.Second, "The duration to wait for the operator calls in flight to complete on shutdown.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(debug)))
@@ -73,22 +102,78 @@ func main() {
 		LeaderElection:     enableLeaderElection,
 		LeaderElectionID:   "6807cfeb.io",
 		SyncPeriod:         &sp,
+
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	shutdownTracing := func(context.Context) error { return nil }
+	if otlpEndpoint != "" {
+		shutdownTracing, err = tracing.Setup(context.Background(), otlpEndpoint, otlpInsecure, traceSampleRatio)
+		if err != nil {
+			setupLog.Error(err, "unable to setup tracing")
+			os.Exit(1)
+		}
+	}
+
+	// the state in memory is torn down when the controller stops or loses the leadership.
+	// the manager exits the process when the leadership is lost, so it isn't rebuilt
+	lifecycle := ops.NewLifecycle()
+	if err := mgr.Add(lifecycle); err != nil {
+		setupLog.Error(err, "unable to add lifecycle")
+		os.Exit(1)
+	}
+	pool := ops.NewConnPool()
+	lifecycle.OnStop(pool.Close)
+
+	ops.Register(ops.ProviderGRPC, ops.NewPluginOperator(pool.NewFunc))
+	ops.Register(ops.ProviderHTTP, ops.NewHTTPOperator(nil))
+	if fakePluginConfig != "" {
+		cfg, err := fakeplugin.LoadConfig(fakePluginConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load fake plugin config")
+			os.Exit(1)
+		}
+		ops.Register("fake", ops.NewInProcessOperator(fakeplugin.NewServer(*cfg)))
+	}
+	setupLog.Info("registered providers", "providers", ops.DefaultRegistry.Providers())
+	helmOperator := ops.NewHelmOperator(ops.NewRegistryOperator(ops.DefaultRegistry), mgr.GetAPIReader())
+	// the Helm upgrades in progress are canceled and waited for
+	lifecycle.OnStop(helmOperator.(ops.Resetter).Reset)
+	// no mutating call, including the Helm upgrades, is issued after the leadership is lost,
+	// even if it has waited for the rate limiter
+	operator := lifecycle.Operator(helmOperator)
+	if operationQPS > 0 {
+		operator = ops.NewRateLimitedOperator(operator, operationQPS, operationBurst)
+	}
+	cachingOperator := ops.NewCachingOperator(operator, operatorCacheTTL)
+
 	if err = (&controllers.ClusterVersionReconciler{
 		Client:   mgr.GetClient(),
 		Log:      ctrl.Log.WithName("controllers").WithName("ClusterVersion"),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("clusterversion_controller"),
-		Operator: ops.NewPluginOperator(ops.DefaultNewFunc),
+		Operator: ops.NewTracingOperator(cachingOperator),
+
+		RecordOperations: recordOperations,
+		Notifier:         notify.NewPolicyNotifier(mgr.GetClient(), mgr.GetAPIReader(), ctrl.Log.WithName("notifier")),
+		RemoteClient:     remote.NewClientsetFunc(mgr.GetAPIReader()),
+
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		RateLimiter:             controllers.NewRateLimiter(rateLimitBaseDelay, rateLimitMaxDelay),
+		Lifecycle:               lifecycle,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClusterVersion")
 		os.Exit(1)
 	}
+	// the webhook rejects the specs which the plugin servers can't perform
+	multiclusteropsiov1.SpecValidator = (&controllers.SpecValidator{
+		Operator: ops.NewRegistryOperator(ops.DefaultRegistry),
+		Log:      ctrl.Log.WithName("webhook").WithName("ClusterVersion"),
+	}).Validate
 	if err = (&multiclusteropsiov1.ClusterVersion{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "ClusterVersion")
 		os.Exit(1)
@@ -96,7 +181,14 @@ func main() {
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+	// flush spans of the last reconciliations
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	if err := shutdownTracing(ctx); err != nil {
+		setupLog.Error(err, "failed to shutdown tracing")
+	}
+	cancel()
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -0,0 +1,249 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"time"
+)
+
+const (
+	defaultTimeout      = time.Minute * 30
+	defaultPollInterval = time.Second * 5
+)
+
+// Scenario defines the cluster which the scenario runs against.
+// The cluster is serviced out and in, and upgraded to Cluster.Version if it's given, so it must not serve production traffic.
+type Scenario struct {
+	// Cluster is the cluster to operate. The upgrade steps are skipped if its version is empty.
+	Cluster opsv1.Cluster
+	// NodePools is the number of node pools which GetVersion must return. It isn't checked if it's zero.
+	NodePools int
+	// Timeout is how long to wait for each operation. default value is 30m.
+	Timeout time.Duration
+	// PollInterval is the interval of getting the operation status. default value is 5s.
+	PollInterval time.Duration
+}
+
+// StepResult is the result of a step of the scenario.
+type StepResult struct {
+	Name       string
+	Violations []string
+}
+
+// Report is the result of the scenario.
+type Report struct {
+	Steps []StepResult
+}
+
+// Passed returns true if no step has violated the expectations.
+func (r *Report) Passed() bool {
+	for _, s := range r.Steps {
+		if len(s.Violations) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type step struct {
+	name string
+	run  func(ctx context.Context) []string
+}
+
+type runner struct {
+	operator ops.Operator
+	scenario Scenario
+	obj      opsv1.ClusterVersion
+	report   *Report
+}
+
+// Run runs the scenario through the Operator and reports the violations of what the controller expects.
+// The scenario stops at the first step which has violations because the later steps depend on it,
+// so the cluster may be left serviced out.
+func Run(ctx context.Context, operator ops.Operator, scenario Scenario) *Report {
+	if scenario.Timeout == 0 {
+		scenario.Timeout = defaultTimeout
+	}
+	if scenario.PollInterval == 0 {
+		scenario.PollInterval = defaultPollInterval
+	}
+	r := &runner{
+		operator: operator,
+		scenario: scenario,
+		report:   &Report{},
+	}
+	r.obj.Spec.Clusters = []opsv1.Cluster{scenario.Cluster}
+
+	steps := []step{
+		{"GetVersion returns the master and all node pools", r.checkVersion},
+		{"GetClusterStatus returns serviced in and available", r.checkStatus(ops.ClusterStatusServiceIn)},
+		{"ServiceOut completes", r.serviceOut},
+		{"GetClusterStatus reflects service out", r.checkStatus(ops.ClusterStatusServiceOut)},
+	}
+	if scenario.Cluster.Version != "" {
+		steps = append(steps,
+			step{"UpgradeMaster completes", r.upgradeMaster},
+			step{"UpgradeNodePool completes for all node pools", r.upgradeNodePools},
+		)
+	}
+	steps = append(steps,
+		step{"ServiceIn completes", r.serviceIn},
+		step{"GetClusterStatus reflects service in", r.checkStatus(ops.ClusterStatusServiceIn)},
+	)
+
+	for _, s := range steps {
+		violations := s.run(ctx)
+		r.report.Steps = append(r.report.Steps, StepResult{Name: s.name, Violations: violations})
+		if len(violations) > 0 {
+			break
+		}
+	}
+	return r.report
+}
+
+func (r *runner) checkVersion(ctx context.Context) []string {
+	cv, err := r.operator.GetClusterVersion(ctx, r.obj, r.scenario.Cluster)
+	if err != nil {
+		return []string{fmt.Sprintf("GetVersion returned an error: %v", err)}
+	}
+	return r.versionViolations(cv)
+}
+
+func (r *runner) versionViolations(cv *ops.ClusterVersion) []string {
+	var violations []string
+	clusterID := r.scenario.Cluster.ID
+	if cv.Master.ClusterID != clusterID {
+		violations = append(violations, fmt.Sprintf("master.clusterID is %q, not %q", cv.Master.ClusterID, clusterID))
+	}
+	if cv.Master.Version == "" {
+		violations = append(violations, "master.version is empty")
+	}
+	if r.scenario.NodePools > 0 && len(cv.NodePools) != r.scenario.NodePools {
+		violations = append(violations, fmt.Sprintf("%d node pools are returned, not %d", len(cv.NodePools), r.scenario.NodePools))
+	}
+	seen := map[string]bool{}
+	for _, np := range cv.NodePools {
+		switch {
+		case np.NodePoolID == "":
+			violations = append(violations, "nodePoolID is empty")
+		case seen[np.NodePoolID]:
+			violations = append(violations, fmt.Sprintf("node pool %s is duplicated", np.NodePoolID))
+		case np.Version == "":
+			violations = append(violations, fmt.Sprintf("version of node pool %s is empty", np.NodePoolID))
+		}
+		seen[np.NodePoolID] = true
+	}
+	return violations
+}
+
+func (r *runner) checkStatus(expected ops.ClusterStatusType) func(ctx context.Context) []string {
+	return func(ctx context.Context) []string {
+		cs, err := r.operator.GetClusterStatus(ctx, r.obj, r.scenario.Cluster)
+		if err != nil {
+			return []string{fmt.Sprintf("GetClusterStatus returned an error: %v", err)}
+		}
+		var violations []string
+		if cs.Type != expected {
+			violations = append(violations, fmt.Sprintf("status is %s, not %s", cs.Type, expected))
+		}
+		if !cs.Available {
+			violations = append(violations, "isAvailable is false")
+		}
+		return violations
+	}
+}
+
+func (r *runner) serviceOut(ctx context.Context) []string {
+	result, err := r.operator.ServiceOut(ctx, r.obj, r.scenario.Cluster)
+	return r.waitOperation(ctx, "ServiceOut", result, err)
+}
+
+func (r *runner) serviceIn(ctx context.Context) []string {
+	result, err := r.operator.ServiceIn(ctx, r.obj, r.scenario.Cluster)
+	return r.waitOperation(ctx, "ServiceIn", result, err)
+}
+
+func (r *runner) upgradeMaster(ctx context.Context) []string {
+	result, err := r.operator.UpgradeMaster(ctx, r.obj, r.scenario.Cluster)
+	if violations := r.waitOperation(ctx, "UpgradeMaster", result, err); len(violations) > 0 {
+		return violations
+	}
+	cv, err := r.operator.GetClusterVersion(ctx, r.obj, r.scenario.Cluster)
+	if err != nil {
+		return []string{fmt.Sprintf("GetVersion returned an error: %v", err)}
+	}
+	if cv.Master.Version != r.scenario.Cluster.Version {
+		return []string{fmt.Sprintf("master.version is %s after the upgrade, not %s", cv.Master.Version, r.scenario.Cluster.Version)}
+	}
+	return nil
+}
+
+func (r *runner) upgradeNodePools(ctx context.Context) []string {
+	cv, err := r.operator.GetClusterVersion(ctx, r.obj, r.scenario.Cluster)
+	if err != nil {
+		return []string{fmt.Sprintf("GetVersion returned an error: %v", err)}
+	}
+	for _, np := range cv.NodePools {
+		result, err := r.operator.UpgradeNodePool(ctx, r.obj, r.scenario.Cluster, np.NodePoolID)
+		if violations := r.waitOperation(ctx, "UpgradeNodePool", result, err); len(violations) > 0 {
+			return violations
+		}
+	}
+	cv, err = r.operator.GetClusterVersion(ctx, r.obj, r.scenario.Cluster)
+	if err != nil {
+		return []string{fmt.Sprintf("GetVersion returned an error: %v", err)}
+	}
+	var violations []string
+	for _, np := range cv.NodePools {
+		if np.Version != r.scenario.Cluster.Version {
+			violations = append(violations, fmt.Sprintf("version of node pool %s is %s after the upgrade, not %s", np.NodePoolID, np.Version, r.scenario.Cluster.Version))
+		}
+	}
+	return violations
+}
+
+// waitOperation polls the operation status until it's DONE. Only RUNNING is allowed before DONE.
+func (r *runner) waitOperation(ctx context.Context, method string, result *ops.OperationResult, err error) []string {
+	if err != nil {
+		return []string{fmt.Sprintf("%s returned an error: %v", method, err)}
+	}
+	var violations []string
+	if result.OperationID == "" {
+		violations = append(violations, fmt.Sprintf("%s returned an empty operationID", method))
+	}
+	if result.OperationType == "" {
+		violations = append(violations, fmt.Sprintf("%s returned an empty type", method))
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+
+	obj := r.obj.DeepCopy()
+	obj.Status.ClusterID = r.scenario.Cluster.ID
+	obj.Status.OperationID = result.OperationID
+	obj.Status.OperationType = result.OperationType
+	ctx, cancel := context.WithTimeout(ctx, r.scenario.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(r.scenario.PollInterval)
+	defer ticker.Stop()
+	for {
+		st, err := r.operator.GetOperationStatus(ctx, *obj)
+		if err != nil {
+			return []string{fmt.Sprintf("GetOperationStatus of %s %s returned an error: %v", result.OperationType, result.OperationID, err)}
+		}
+		switch st {
+		case ops.OperationStatusDone:
+			return nil
+		case ops.OperationStatusRunning:
+		default:
+			return []string{fmt.Sprintf("operation %s %s became %s, not RUNNING or DONE", result.OperationType, result.OperationID, st)}
+		}
+		select {
+		case <-ctx.Done():
+			return []string{fmt.Sprintf("operation %s %s didn't become DONE in %s", result.OperationType, result.OperationID, r.scenario.Timeout)}
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package conformance
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/ops"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"testing"
+	"time"
+)
+
+const testClusterID = "projects/test/locations/asia-northeast1/clusters/cluster-1"
+
+// newFakePluginOperator returns the Operator talking to the fake plugin server over an in-memory connection.
+func newFakePluginOperator(failureRate float64) (ops.Operator, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	plugin.RegisterClusterServer(s, fakeplugin.NewServer(fakeplugin.Config{
+		Settings: fakeplugin.Settings{
+			OperationDuration: &metav1.Duration{Duration: time.Millisecond * 50},
+			FailureRate:       failureRate,
+		},
+		Clusters: []fakeplugin.ClusterConfig{
+			{ID: testClusterID, Version: "1.16.13-gke.404", NodePools: []string{"pool-1", "pool-2"}},
+		},
+	}))
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	return ops.NewPluginOperator(func(_ opsv1.OpsEndpoint) (ops.PluginClient, func(), error) {
+		conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+		if err != nil {
+			return nil, nil, err
+		}
+		return ops.NewPluginClient(conn), func() { _ = conn.Close() }, nil
+	}), s.Stop
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name               string
+		failureRate        float64
+		version            string
+		expectedSteps      int
+		expectedPassed     bool
+		expectedViolations []string
+	}{
+		{
+			name:           "pass all steps with upgrades",
+			version:        "1.16.15-gke.4301",
+			expectedSteps:  8,
+			expectedPassed: true,
+		},
+		{
+			name:           "pass all steps without upgrades",
+			expectedSteps:  6,
+			expectedPassed: true,
+		},
+		{
+			name:               "stop at the failed operation",
+			failureRate:        1,
+			version:            "1.16.15-gke.4301",
+			expectedSteps:      3,
+			expectedViolations: []string{"operation SERVICE_OUT 1 became Failed, not RUNNING or DONE"},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			operator, stop := newFakePluginOperator(c.failureRate)
+			defer stop()
+			report := Run(context.Background(), operator, Scenario{
+				Cluster:      opsv1.Cluster{ID: testClusterID, Version: c.version},
+				NodePools:    2,
+				Timeout:      time.Second * 5,
+				PollInterval: time.Millisecond * 10,
+			})
+			g.Expect(report.Passed()).Should(Equal(c.expectedPassed))
+			g.Expect(report.Steps).Should(HaveLen(c.expectedSteps))
+			g.Expect(report.Steps[len(report.Steps)-1].Violations).Should(Equal(c.expectedViolations))
+		})
+	}
+}
+
+func TestRun_Version(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	g := NewGomegaWithT(t)
+	c := plugin.NewMockClusterClient(ctrl)
+	c.EXPECT().GetVersion(gomock.Any(), gomock.Any()).Return(&plugin.ClusterVersion{
+		Master: &plugin.MasterVersion{ClusterID: "another-cluster", Version: "1.16.13-gke.404"},
+		NodePools: []*plugin.NodePoolVersion{
+			{ClusterID: testClusterID, NodePoolID: "pool-1", Version: "1.16.13-gke.404"},
+			{ClusterID: testClusterID, NodePoolID: "pool-1", Version: "1.16.13-gke.404"},
+		},
+	}, nil)
+	operator := ops.NewPluginOperator(func(_ opsv1.OpsEndpoint) (ops.PluginClient, func(), error) {
+		return struct {
+			plugin.ClusterClient
+			pluginext.ClusterExtensionClient
+		}{ClusterClient: c}, func() {}, nil
+	})
+
+	report := Run(context.Background(), operator, Scenario{
+		Cluster:   opsv1.Cluster{ID: testClusterID},
+		NodePools: 3,
+	})
+	g.Expect(report.Passed()).Should(BeFalse())
+	g.Expect(report.Steps).Should(Equal([]StepResult{
+		{
+			Name: "GetVersion returns the master and all node pools",
+			Violations: []string{
+				`master.clusterID is "another-cluster", not "projects/test/locations/asia-northeast1/clusters/cluster-1"`,
+				"2 node pools are returned, not 3",
+				"node pool pool-1 is duplicated",
+			},
+		},
+	}))
+}
@@ -0,0 +1,118 @@
+package drain
+
+import (
+	"context"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/drain"
+	"sort"
+)
+
+const evictionGroupVersion = "policy/v1beta1"
+
+// Drainer cordons and drains the nodes of a node pool.
+// Drain never blocks until the pods are gone, so the caller calls it until all nodes are drained.
+type Drainer struct {
+	client        kubernetes.Interface
+	nodePoolLabel string
+}
+
+// New returns the Drainer which finds the nodes of the node pool by the given node label.
+func New(client kubernetes.Interface, nodePoolLabel string) *Drainer {
+	return &Drainer{
+		client:        client,
+		nodePoolLabel: nodePoolLabel,
+	}
+}
+
+// Drain cordons the nodes of the node pool and evicts the pods on them except DaemonSet pods.
+// The evictions blocked by PodDisruptionBudgets are retried on the next call.
+// The pods not managed by any controller aren't deleted because nothing would recreate them, so they're returned
+// as blocked until they're deleted by hand.
+// It returns the number of the pods remaining on each node.
+func (d *Drainer) Drain(ctx context.Context, nodePool string) ([]opsv1.NodeDrainStatus, error) {
+	nodes, err := d.nodes(ctx, nodePool)
+	if err != nil {
+		return nil, err
+	}
+	helper := &drain.Helper{
+		Ctx:                 ctx,
+		Client:              d.client,
+		Force:               false,
+		GracePeriodSeconds:  -1,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		Out:                 ioutil.Discard,
+		ErrOut:              ioutil.Discard,
+	}
+	statuses := make([]opsv1.NodeDrainStatus, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if err := drain.RunCordonOrUncordon(helper, node, true); err != nil {
+			return nil, err
+		}
+		list, errs := helper.GetPodsForDeletion(node.Name)
+		if list == nil {
+			return nil, utilerrors.NewAggregate(errs)
+		}
+		var blocked []string
+		for _, err := range errs {
+			blocked = append(blocked, err.Error())
+		}
+		// the errors are built from a map, so they're sorted not to change the status on every call
+		sort.Strings(blocked)
+		remaining := 0
+		for _, pod := range list.Pods() {
+			if pod.Spec.NodeName != node.Name {
+				continue
+			}
+			remaining += 1
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			err := helper.EvictPod(pod, evictionGroupVersion)
+			// TooManyRequests means the eviction is blocked by the PodDisruptionBudget.
+			if err != nil && !k8serrors.IsNotFound(err) && !k8serrors.IsTooManyRequests(err) {
+				return nil, err
+			}
+		}
+		statuses = append(statuses, opsv1.NodeDrainStatus{
+			Name:          node.Name,
+			RemainingPods: remaining,
+			Blocked:       blocked,
+		})
+	}
+	return statuses, nil
+}
+
+// Uncordon makes the nodes of the node pool schedulable again, e.g. when the node pool isn't upgraded after all.
+func (d *Drainer) Uncordon(ctx context.Context, nodePool string) error {
+	nodes, err := d.nodes(ctx, nodePool)
+	if err != nil {
+		return err
+	}
+	helper := &drain.Helper{
+		Ctx:    ctx,
+		Client: d.client,
+		Out:    ioutil.Discard,
+		ErrOut: ioutil.Discard,
+	}
+	for i := range nodes.Items {
+		if err := drain.RunCordonOrUncordon(helper, &nodes.Items[i], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Drainer) nodes(ctx context.Context, nodePool string) (*corev1.NodeList, error) {
+	return d.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{d.nodePoolLabel: nodePool}).String(),
+	})
+}
@@ -0,0 +1,130 @@
+package drain
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+)
+
+func makeNode(name, pool string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{opsv1.DefaultNodePoolLabel: pool}},
+	}
+}
+
+func makePod(name, node string, owner metav1.OwnerReference) *corev1.Pod {
+	controller := true
+	owner.Controller = &controller
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, OwnerReferences: []metav1.OwnerReference{owner}},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+}
+
+func TestDrainer_Drain(t *testing.T) {
+	g := NewGomegaWithT(t)
+	rs := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app"}
+	ds := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent"}
+	client := fake.NewSimpleClientset(
+		makeNode("node-1", "pool-1"),
+		makeNode("node-2", "pool-1"),
+		makeNode("node-3", "pool-2"),
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "agent"}},
+		makePod("app-1", "node-1", rs),
+		makePod("agent-1", "node-1", ds),
+		makePod("app-protected", "node-2", rs),
+		makePod("app-3", "node-3", rs),
+	)
+	// app-protected is blocked by the PodDisruptionBudget, others are evicted at once.
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.Eviction)
+		if eviction.Name == "app-protected" {
+			return true, nil, k8serrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		}
+		return true, nil, client.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace, eviction.Name)
+	})
+	drainer := New(client, opsv1.DefaultNodePoolLabel)
+
+	statuses, err := drainer.Drain(context.Background(), "pool-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(statuses).Should(Equal([]opsv1.NodeDrainStatus{
+		{Name: "node-1", RemainingPods: 1},
+		{Name: "node-2", RemainingPods: 1},
+	}))
+
+	statuses, err = drainer.Drain(context.Background(), "pool-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(statuses).Should(Equal([]opsv1.NodeDrainStatus{
+		{Name: "node-1", RemainingPods: 0},
+		{Name: "node-2", RemainingPods: 1},
+	}))
+
+	for _, name := range []string{"node-1", "node-2", "node-3"} {
+		node, err := client.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(node.Spec.Unschedulable).Should(Equal(name != "node-3"))
+	}
+	_, err = client.CoreV1().Pods("default").Get(context.Background(), "agent-1", metav1.GetOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	_, err = client.CoreV1().Pods("default").Get(context.Background(), "app-3", metav1.GetOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+}
+
+func TestDrainer_Drain_Unmanaged(t *testing.T) {
+	g := NewGomegaWithT(t)
+	bare := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bare"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	}
+	client := fake.NewSimpleClientset(makeNode("node-1", "pool-1"), bare)
+	drainer := New(client, opsv1.DefaultNodePoolLabel)
+
+	for i := 0; i < 2; i++ {
+		statuses, err := drainer.Drain(context.Background(), "pool-1")
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(statuses).Should(HaveLen(1))
+		g.Expect(statuses[0].RemainingPods).Should(Equal(0))
+		g.Expect(statuses[0].Blocked).Should(ConsistOf(ContainSubstring("default/bare")))
+	}
+	_, err := client.CoreV1().Pods("default").Get(context.Background(), "bare", metav1.GetOptions{})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	g.Expect(client.CoreV1().Pods("default").Delete(context.Background(), "bare", metav1.DeleteOptions{})).Should(Succeed())
+	statuses, err := drainer.Drain(context.Background(), "pool-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(statuses).Should(Equal([]opsv1.NodeDrainStatus{{Name: "node-1", RemainingPods: 0}}))
+}
+
+func TestDrainer_Uncordon(t *testing.T) {
+	g := NewGomegaWithT(t)
+	client := fake.NewSimpleClientset(
+		makeNode("node-1", "pool-1"),
+		makeNode("node-2", "pool-1"),
+		makeNode("node-3", "pool-2"),
+	)
+	drainer := New(client, opsv1.DefaultNodePoolLabel)
+	_, err := drainer.Drain(context.Background(), "pool-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	_, err = drainer.Drain(context.Background(), "pool-2")
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	err = drainer.Uncordon(context.Background(), "pool-1")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	for name, unschedulable := range map[string]bool{"node-1": false, "node-2": false, "node-3": true} {
+		node, err := client.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(node.Spec.Unschedulable).Should(Equal(unschedulable), name)
+	}
+}
@@ -0,0 +1,73 @@
+package fakeplugin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sigs.k8s.io/yaml"
+	"strconv"
+)
+
+// NewAdminHandler returns the HTTP handler which inspects and changes the fleet at runtime.
+//
+//	GET /clusters                                 shows the state of the clusters
+//	GET /settings, PUT /settings                  shows or replaces the settings in JSON or YAML
+//	PUT /availability?cluster=<id>&available=<b>  changes whether the cluster can be routed
+func NewAdminHandler(s *Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/clusters", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, s.Clusters())
+	})
+	mux.HandleFunc("/settings", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, s.Settings())
+		case http.MethodPut:
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			settings := Settings{}
+			if err := yaml.UnmarshalStrict(body, &settings); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := s.SetSettings(settings); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, settings)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/availability", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		available, err := strconv.ParseBool(r.URL.Query().Get("available"))
+		if err != nil {
+			http.Error(w, "available must be a bool", http.StatusBadRequest)
+			return
+		}
+		if err := s.SetAvailability(r.URL.Query().Get("cluster"), available); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,80 @@
+package fakeplugin
+
+import (
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "change the settings in YAML",
+			method:       http.MethodPut,
+			path:         "/settings",
+			body:         "operationDuration: 5s\nfailureRate: 0.2\n",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "reject the invalid failure rate",
+			method:       http.MethodPut,
+			path:         "/settings",
+			body:         `{"failureRate": 2}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "change the availability",
+			method:       http.MethodPut,
+			path:         "/availability?available=false&cluster=" + url.QueryEscape(testClusterID),
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "ret not found for unknown cluster",
+			method:       http.MethodPut,
+			path:         "/availability?available=false&cluster=unknown",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "show the clusters",
+			method:       http.MethodGet,
+			path:         "/clusters",
+			expectedCode: http.StatusOK,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			s, _ := newTestServer(0)
+			rec := httptest.NewRecorder()
+			NewAdminHandler(s).ServeHTTP(rec, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+			g.Expect(rec.Code).Should(Equal(c.expectedCode))
+		})
+	}
+}
+
+func TestAdminHandler_Apply(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s, _ := newTestServer(0)
+	handler := NewAdminHandler(s)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/settings", strings.NewReader("operationDuration: 5s\nfailureRate: 0.2\n")))
+	g.Expect(rec.Code).Should(Equal(http.StatusOK))
+	g.Expect(s.Settings().operationDuration()).Should(Equal(time.Second * 5))
+	g.Expect(s.Settings().FailureRate).Should(Equal(0.2))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/availability?available=false&cluster="+url.QueryEscape(testClusterID), nil))
+	g.Expect(rec.Code).Should(Equal(http.StatusNoContent))
+	g.Expect(s.Clusters()[0].Available).Should(BeFalse())
+}
@@ -0,0 +1,77 @@
+package fakeplugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+const (
+	defaultOperationDuration = time.Second * 10
+)
+
+// Config is the configuration of the fake fleet.
+type Config struct {
+	// Settings change how the operations behave.
+	Settings Settings `json:"settings"`
+	// Clusters are the clusters of the fleet.
+	Clusters []ClusterConfig `json:"clusters"`
+}
+
+// Settings change how the operations behave. They can be changed at runtime through the admin API.
+type Settings struct {
+	// OperationDuration is how long every operation takes. default value is 10s.
+	// +optional
+	OperationDuration *metav1.Duration `json:"operationDuration,omitempty"`
+	// FailureRate is the probability from 0 to 1 that an operation fails.
+	// +optional
+	FailureRate float64 `json:"failureRate,omitempty"`
+}
+
+// ClusterConfig is the initial state of the cluster.
+type ClusterConfig struct {
+	ID        string   `json:"id"`
+	Version   string   `json:"version"`
+	NodePools []string `json:"nodePools"`
+	// Unavailable makes the cluster report it can't be routed.
+	// +optional
+	Unavailable bool `json:"unavailable,omitempty"`
+	// AvailableVersions are the versions which the cluster can be upgraded to. Empty means any version.
+	// +optional
+	AvailableVersions []string `json:"availableVersions,omitempty"`
+	// Workloads are the versions of the core workloads by their names.
+	// +optional
+	Workloads map[string]string `json:"workloads,omitempty"`
+}
+
+// LoadConfig reads the configuration from the YAML file.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(raw, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Settings.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (s Settings) validate() error {
+	if s.FailureRate < 0 || s.FailureRate > 1 {
+		return fmt.Errorf("failureRate must be between 0 and 1: %v", s.FailureRate)
+	}
+	return nil
+}
+
+func (s Settings) operationDuration() time.Duration {
+	if s.OperationDuration == nil {
+		return defaultOperationDuration
+	}
+	return s.OperationDuration.Duration
+}
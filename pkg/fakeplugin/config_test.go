@@ -0,0 +1,59 @@
+package fakeplugin
+
+import (
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		name           string
+		in             string
+		expectedHasErr bool
+	}{
+		{
+			name: "load the fleet",
+			in: `settings:
+  operationDuration: 30s
+  failureRate: 0.1
+clusters:
+  - id: cluster-1
+    version: 1.16.13-gke.404
+    nodePools: [pool-1]
+`,
+		},
+		{
+			name:           "ret error with unknown field",
+			in:             "settings:\n  failureRatio: 0.1\n",
+			expectedHasErr: true,
+		},
+		{
+			name:           "ret error with invalid failure rate",
+			in:             "settings:\n  failureRate: -1\n",
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			dir, err := ioutil.TempDir("", "fake-plugin")
+			g.Expect(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "fleet.yaml")
+			g.Expect(ioutil.WriteFile(path, []byte(c.in), 0600)).Should(Succeed())
+
+			cfg, err := LoadConfig(path)
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+				return
+			}
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(cfg.Settings.operationDuration()).Should(Equal(time.Second * 30))
+			g.Expect(cfg.Clusters).Should(Equal([]ClusterConfig{{ID: "cluster-1", Version: "1.16.13-gke.404", NodePools: []string{"pool-1"}}}))
+		})
+	}
+}
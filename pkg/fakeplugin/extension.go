@@ -0,0 +1,192 @@
+package fakeplugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// protocolVersionV2 is the version of the plugin protocol with the ClusterExtension service.
+const protocolVersionV2 = "v2"
+
+// watchInterval is how often WatchOperation checks the status of the operation.
+const watchInterval = time.Millisecond * 100
+
+// GetCapabilities speaks the protocol v2. The fake fleet supports all operations except rollback.
+func (s *Server) GetCapabilities(_ context.Context, req *pluginext.GetCapabilitiesRequest) (*pluginext.Capabilities, error) {
+	if !contains(req.ProtocolVersions, protocolVersionV2) {
+		return nil, status.Errorf(codes.FailedPrecondition, "protocol version %s is required: %v", protocolVersionV2, req.ProtocolVersions)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, err := s.cluster(req.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginext.Capabilities{
+		ProtocolVersion:   protocolVersionV2,
+		UpgradeNodePool:   true,
+		ServiceOut:        true,
+		AvailableVersions: c.AvailableVersions,
+		Workloads:         true,
+	}, nil
+}
+
+// GetAvailableVersions returns the configured available versions of the cluster for any channel.
+func (s *Server) GetAvailableVersions(_ context.Context, req *pluginext.GetAvailableVersionsRequest) (*pluginext.AvailableVersions, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, err := s.cluster(req.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginext.AvailableVersions{Versions: c.AvailableVersions}, nil
+}
+
+// GetWorkloadVersion returns the version of the workload, or an empty version if it isn't installed.
+func (s *Server) GetWorkloadVersion(_ context.Context, req *pluginext.GetWorkloadVersionRequest) (*pluginext.WorkloadVersion, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	c, err := s.cluster(req.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	return &pluginext.WorkloadVersion{
+		ClusterID: c.ID,
+		Name:      req.Name,
+		Version:   c.Workloads[req.Name],
+	}, nil
+}
+
+// UpgradeWorkload installs the workload if it isn't installed.
+func (s *Server) UpgradeWorkload(ctx context.Context, req *pluginext.WorkloadVersion) (*plugin.Operation, error) {
+	return s.startOperation(ctx, req.ClusterID, OperationTypeUpgradeWorkload, req.Name, func(c *ClusterState) {
+		c.Workloads[req.Name] = req.Version
+	})
+}
+
+// GetOperationDetail reports the progress of the running operation by the elapsed time.
+func (s *Server) GetOperationDetail(_ context.Context, req *plugin.GetOperationStatusRequest) (*pluginext.OperationDetail, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	op, ok := s.operations[req.OperationID]
+	if !ok || op.clusterID != req.ClusterID {
+		return &pluginext.OperationDetail{Status: plugin.OperationStatusType_UNKNOWN}, nil
+	}
+	now := s.now()
+	switch {
+	case now.Before(op.doneAt):
+		progress := int32(100 * now.Sub(op.startedAt) / op.doneAt.Sub(op.startedAt))
+		return &pluginext.OperationDetail{
+			Status:   plugin.OperationStatusType_RUNNING,
+			Progress: wrapperspb.Int32(progress),
+			Message:  fmt.Sprintf("%s is running", op.opType),
+		}, nil
+	case op.failed:
+		return &pluginext.OperationDetail{
+			Status:   plugin.OperationStatusType_FAILED,
+			Progress: wrapperspb.Int32(100),
+			Error:    fmt.Sprintf("%s has failed by the failure rate", op.opType),
+		}, nil
+	}
+	return &pluginext.OperationDetail{
+		Status:   plugin.OperationStatusType_DONE,
+		Progress: wrapperspb.Int32(100),
+	}, nil
+}
+
+// ListOperations lists the running operations of the cluster in the order they have started.
+func (s *Server) ListOperations(_ context.Context, req *pluginext.ListOperationsRequest) (*pluginext.Operations, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	if _, err := s.cluster(req.ClusterID); err != nil {
+		return nil, err
+	}
+	now := s.now()
+	ret := &pluginext.Operations{}
+	for id, op := range s.operations {
+		if op.clusterID != req.ClusterID || !now.Before(op.doneAt) {
+			continue
+		}
+		ret.Operations = append(ret.Operations, &pluginext.ListedOperation{
+			OperationID: id,
+			Type:        op.opType,
+			Target:      op.target,
+			Status:      plugin.OperationStatusType_RUNNING,
+		})
+	}
+	// the IDs are sequential
+	sort.Slice(ret.Operations, func(i, j int) bool {
+		a, _ := strconv.Atoi(ret.Operations[i].OperationID)
+		b, _ := strconv.Atoi(ret.Operations[j].OperationID)
+		return a < b
+	})
+	return ret, nil
+}
+
+// GetFleetStatus returns the versions and the statuses of the requested clusters. The unknown clusters are left out.
+func (s *Server) GetFleetStatus(_ context.Context, req *pluginext.GetFleetStatusRequest) (*pluginext.FleetStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	ret := &pluginext.FleetStatus{}
+	for _, id := range req.ClusterIDs {
+		c, ok := s.clusters[id]
+		if !ok {
+			continue
+		}
+		ret.Clusters = append(ret.Clusters, &pluginext.FleetClusterStatus{
+			ClusterID: id,
+			Version:   clusterVersion(c),
+			Status:    clusterStatus(c),
+		})
+	}
+	return ret, nil
+}
+
+// WatchOperation sends the status of the operation whenever it changes, until it's DONE, FAILED or UNKNOWN.
+func (s *Server) WatchOperation(req *plugin.GetOperationStatusRequest, stream pluginext.ClusterExtension_WatchOperationServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	var last *plugin.OperationStatus
+	for {
+		st, err := s.GetOperationStatus(ctx, req)
+		if err != nil {
+			return err
+		}
+		if last == nil || st.Status != last.Status {
+			if err := stream.Send(st); err != nil {
+				return err
+			}
+			last = st
+		}
+		if st.Status != plugin.OperationStatusType_RUNNING {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package fakeplugin
+
+import (
+	"context"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"testing"
+	"time"
+)
+
+func TestServer_GetCapabilities(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s, _ := newTestServer(0)
+	s.clusters[testClusterID].AvailableVersions = []string{"1.16.15-gke.4301"}
+
+	caps, err := s.GetCapabilities(context.Background(), &pluginext.GetCapabilitiesRequest{ClusterID: testClusterID, ProtocolVersions: []string{"v1", "v2"}})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(caps.ProtocolVersion).Should(Equal("v2"))
+	g.Expect(caps.UpgradeNodePool).Should(BeTrue())
+	g.Expect(caps.ServiceOut).Should(BeTrue())
+	g.Expect(caps.Workloads).Should(BeTrue())
+	g.Expect(caps.AvailableVersions).Should(Equal([]string{"1.16.15-gke.4301"}))
+
+	_, err = s.GetCapabilities(context.Background(), &pluginext.GetCapabilitiesRequest{ClusterID: testClusterID, ProtocolVersions: []string{"v1"}})
+	g.Expect(status.Code(err)).Should(Equal(codes.FailedPrecondition))
+	_, err = s.GetCapabilities(context.Background(), &pluginext.GetCapabilitiesRequest{ClusterID: "unknown", ProtocolVersions: []string{"v2"}})
+	g.Expect(status.Code(err)).Should(Equal(codes.NotFound))
+}
+
+func TestServer_GetAvailableVersions(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s, _ := newTestServer(0)
+	s.clusters[testClusterID].AvailableVersions = []string{"1.16.15-gke.4301", "1.17.14-gke.400"}
+
+	res, err := s.GetAvailableVersions(context.Background(), &pluginext.GetAvailableVersionsRequest{ClusterID: testClusterID, Channel: "REGULAR"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Versions).Should(Equal([]string{"1.16.15-gke.4301", "1.17.14-gke.400"}))
+	_, err = s.GetAvailableVersions(context.Background(), &pluginext.GetAvailableVersionsRequest{ClusterID: "unknown"})
+	g.Expect(status.Code(err)).Should(Equal(codes.NotFound))
+}
+
+func TestServer_UpgradeWorkload(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	s, now := newTestServer(0)
+	s.clusters[testClusterID].Workloads["istio"] = "1.7.0"
+
+	v, err := s.GetWorkloadVersion(ctx, &pluginext.GetWorkloadVersionRequest{ClusterID: testClusterID, Name: "istio"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.Version).Should(Equal("1.7.0"))
+	v, err = s.GetWorkloadVersion(ctx, &pluginext.GetWorkloadVersionRequest{ClusterID: testClusterID, Name: "nginx"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.Version).Should(BeEmpty())
+
+	op, err := s.UpgradeWorkload(ctx, &pluginext.WorkloadVersion{ClusterID: testClusterID, Name: "istio", Version: "1.8.0"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(op.Type).Should(Equal(OperationTypeUpgradeWorkload))
+	v, err = s.GetWorkloadVersion(ctx, &pluginext.GetWorkloadVersionRequest{ClusterID: testClusterID, Name: "istio"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.Version).Should(Equal("1.7.0"))
+
+	*now = now.Add(time.Minute)
+	st, err := s.GetOperationStatus(ctx, &plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: op.OperationID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(st.Status).Should(Equal(plugin.OperationStatusType_DONE))
+	v, err = s.GetWorkloadVersion(ctx, &pluginext.GetWorkloadVersionRequest{ClusterID: testClusterID, Name: "istio"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(v.Version).Should(Equal("1.8.0"))
+
+	_, err = s.UpgradeWorkload(ctx, &pluginext.WorkloadVersion{ClusterID: "unknown", Name: "istio", Version: "1.8.0"})
+	g.Expect(status.Code(err)).Should(Equal(codes.NotFound))
+}
+
+func TestServer_WatchOperation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	s, now := newTestServer(0)
+
+	op, err := s.UpgradeMaster(ctx, &plugin.MasterVersion{ClusterID: testClusterID, Version: "1.16.15-gke.4301"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	stream := pluginext.NewMockClusterExtension_WatchOperationServer(ctrl)
+	stream.EXPECT().Context().Return(ctx).AnyTimes()
+	sent := []plugin.OperationStatusType{}
+	stream.EXPECT().Send(gomock.Any()).DoAndReturn(func(st *plugin.OperationStatus) error {
+		sent = append(sent, st.Status)
+		// the operation completes after the first status
+		*now = now.Add(time.Minute)
+		return nil
+	}).Times(2)
+
+	err = s.WatchOperation(&plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: op.OperationID}, stream)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(sent).Should(Equal([]plugin.OperationStatusType{plugin.OperationStatusType_RUNNING, plugin.OperationStatusType_DONE}))
+
+	unknown := pluginext.NewMockClusterExtension_WatchOperationServer(ctrl)
+	unknown.EXPECT().Context().Return(ctx).AnyTimes()
+	unknown.EXPECT().Send(gomock.Eq(&plugin.OperationStatus{Status: plugin.OperationStatusType_UNKNOWN})).Return(nil).Times(1)
+	g.Expect(s.WatchOperation(&plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: "unknown"}, unknown)).Should(Succeed())
+}
+
+func TestServer_GetOperationDetail(t *testing.T) {
+	testCases := []struct {
+		name           string
+		failureRate    float64
+		expectedStatus plugin.OperationStatusType
+		expectedError  string
+	}{
+		{
+			name:           "report the progress until done",
+			failureRate:    0,
+			expectedStatus: plugin.OperationStatusType_DONE,
+		},
+		{
+			name:           "report the error of the failed operation",
+			failureRate:    1,
+			expectedStatus: plugin.OperationStatusType_FAILED,
+			expectedError:  "UPGRADE_MASTER has failed by the failure rate",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			ctx := context.Background()
+			s, now := newTestServer(c.failureRate)
+
+			op, err := s.UpgradeMaster(ctx, &plugin.MasterVersion{ClusterID: testClusterID, Version: "1.16.15-gke.4301"})
+			g.Expect(err).ShouldNot(HaveOccurred())
+			req := &plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: op.OperationID}
+
+			*now = now.Add(time.Second * 30)
+			detail, err := s.GetOperationDetail(ctx, req)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(detail.Status).Should(Equal(plugin.OperationStatusType_RUNNING))
+			g.Expect(detail.Progress.GetValue()).Should(Equal(int32(50)))
+			g.Expect(detail.Message).Should(Equal("UPGRADE_MASTER is running"))
+
+			*now = now.Add(time.Second * 30)
+			detail, err = s.GetOperationDetail(ctx, req)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(detail.Status).Should(Equal(c.expectedStatus))
+			g.Expect(detail.Progress.GetValue()).Should(Equal(int32(100)))
+			g.Expect(detail.Error).Should(Equal(c.expectedError))
+
+			detail, err = s.GetOperationDetail(ctx, &plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: "unknown"})
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(detail.Status).Should(Equal(plugin.OperationStatusType_UNKNOWN))
+		})
+	}
+}
+
+func TestServer_ListOperations(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	s, now := newTestServer(0)
+
+	master, err := s.UpgradeMaster(ctx, &plugin.MasterVersion{ClusterID: testClusterID, Version: "1.16.15-gke.4301"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	*now = now.Add(time.Second * 30)
+	serviceOut, err := s.ServiceOut(ctx, &plugin.ServiceOutRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	nodePool, err := s.UpgradeNodePool(ctx, &plugin.NodePoolVersion{ClusterID: testClusterID, NodePoolID: testClusterID + "/nodePools/pool-1", Version: "1.16.15-gke.4301"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	res, err := s.ListOperations(ctx, &pluginext.ListOperationsRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Operations).Should(HaveLen(3))
+	g.Expect(res.Operations[0].OperationID).Should(Equal(master.OperationID))
+	g.Expect(res.Operations[0].Type).Should(Equal(OperationTypeUpgradeMaster))
+	g.Expect(res.Operations[0].Target).Should(BeEmpty())
+	g.Expect(res.Operations[1].OperationID).Should(Equal(serviceOut.OperationID))
+	g.Expect(res.Operations[1].Status).Should(Equal(plugin.OperationStatusType_RUNNING))
+	g.Expect(res.Operations[2].OperationID).Should(Equal(nodePool.OperationID))
+	g.Expect(res.Operations[2].Target).Should(Equal(testClusterID + "/nodePools/pool-1"))
+
+	// the completed operations aren't listed
+	*now = now.Add(time.Second * 30)
+	res, err = s.ListOperations(ctx, &pluginext.ListOperationsRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(res.Operations).Should(HaveLen(2))
+	g.Expect(res.Operations[0].OperationID).Should(Equal(serviceOut.OperationID))
+
+	_, err = s.ListOperations(ctx, &pluginext.ListOperationsRequest{ClusterID: "unknown"})
+	g.Expect(status.Code(err)).Should(Equal(codes.NotFound))
+}
+
+func TestServer_GetFleetStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	s, _ := newTestServer(0)
+
+	res, err := s.GetFleetStatus(ctx, &pluginext.GetFleetStatusRequest{ClusterIDs: []string{testClusterID, "unknown"}})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	cv, err := s.GetVersion(ctx, &plugin.GetVersionRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	cs, err := s.GetClusterStatus(ctx, &plugin.GetClusterStatusRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	// the unknown clusters are left out
+	g.Expect(res.Clusters).Should(HaveLen(1))
+	g.Expect(res.Clusters[0].ClusterID).Should(Equal(testClusterID))
+	g.Expect(proto.Equal(res.Clusters[0].Version, cv)).Should(BeTrue())
+	g.Expect(proto.Equal(res.Clusters[0].Status, cs)).Should(BeTrue())
+}
@@ -0,0 +1,350 @@
+package fakeplugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The operation types which the Server returns.
+const (
+	OperationTypeServiceIn       = "SERVICE_IN"
+	OperationTypeServiceOut      = "SERVICE_OUT"
+	OperationTypeUpgradeMaster   = "UPGRADE_MASTER"
+	OperationTypeUpgradeNodePool = "UPGRADE_NODE_POOL"
+	OperationTypeUpgradeWorkload = "UPGRADE_WORKLOAD"
+)
+
+// idempotencyKeyMetadata is the gRPC metadata key of the idempotency key which the controller sends.
+const idempotencyKeyMetadata = "idempotency-key"
+
+// ClusterState is the state of the cluster in the fleet.
+type ClusterState struct {
+	ID            string            `json:"id"`
+	MasterVersion string            `json:"masterVersion"`
+	NodePools     map[string]string `json:"nodePools"`
+	ServiceIn     bool              `json:"serviceIn"`
+	Available     bool              `json:"available"`
+	// AvailableVersions are the versions which the cluster can be upgraded to. Empty means any version.
+	AvailableVersions []string `json:"availableVersions,omitempty"`
+	// Workloads are the versions of the core workloads by their names.
+	Workloads map[string]string `json:"workloads,omitempty"`
+}
+
+type operation struct {
+	clusterID string
+	opType    string
+	// target is the node pool ID or the workload name
+	target    string
+	startedAt time.Time
+	doneAt    time.Time
+	failed    bool
+	// apply changes the cluster when the operation has done. It's nil after applied.
+	apply func(c *ClusterState)
+}
+
+// Server implements the plugin server with an in-memory fleet.
+// Every operation takes the configured duration, and fails with the configured rate without changing the cluster.
+type Server struct {
+	plugin.UnimplementedClusterServer
+	pluginext.UnimplementedClusterExtensionServer
+
+	settings   Settings
+	clusters   map[string]*ClusterState
+	operations map[string]*operation
+	// idempotencyKeys holds the operation IDs by the idempotency keys of the requests.
+	idempotencyKeys map[string]string
+	nextID          int
+	now             func() time.Time
+	random          func() float64
+	lock            sync.Mutex
+}
+
+var _ plugin.ClusterServer = &Server{}
+var _ pluginext.ClusterExtensionServer = &Server{}
+
+// NewServer returns the Server of the fleet in the configuration.
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		settings:        cfg.Settings,
+		clusters:        map[string]*ClusterState{},
+		operations:      map[string]*operation{},
+		idempotencyKeys: map[string]string{},
+		now:             time.Now,
+		random:          rand.New(rand.NewSource(time.Now().UnixNano())).Float64,
+	}
+	for _, c := range cfg.Clusters {
+		pools := map[string]string{}
+		for _, np := range c.NodePools {
+			pools[nodePoolID(c.ID, np)] = c.Version
+		}
+		workloads := map[string]string{}
+		for name, version := range c.Workloads {
+			workloads[name] = version
+		}
+		s.clusters[c.ID] = &ClusterState{
+			ID:            c.ID,
+			MasterVersion: c.Version,
+			NodePools:     pools,
+			ServiceIn:     true,
+			Available:     !c.Unavailable,
+
+			AvailableVersions: c.AvailableVersions,
+			Workloads:         workloads,
+		}
+	}
+	return s
+}
+
+// nodePoolID returns the node pool ID in the same form as GKE.
+func nodePoolID(clusterID, name string) string {
+	return fmt.Sprintf("%s/nodePools/%s", clusterID, name)
+}
+
+// Settings returns the current settings.
+func (s *Server) Settings() Settings {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.settings
+}
+
+// SetSettings changes the settings. The running operations aren't affected.
+func (s *Server) SetSettings(settings Settings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.settings = settings
+	return nil
+}
+
+// SetAvailability changes whether the cluster can be routed.
+func (s *Server) SetAvailability(clusterID string, available bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, ok := s.clusters[clusterID]
+	if !ok {
+		return fmt.Errorf("cluster %s isn't found", clusterID)
+	}
+	c.Available = available
+	return nil
+}
+
+// Clusters returns the snapshot of the fleet sorted by the cluster ID.
+func (s *Server) Clusters() []ClusterState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	ret := make([]ClusterState, 0, len(s.clusters))
+	for _, c := range s.clusters {
+		pools := make(map[string]string, len(c.NodePools))
+		for k, v := range c.NodePools {
+			pools[k] = v
+		}
+		workloads := make(map[string]string, len(c.Workloads))
+		for k, v := range c.Workloads {
+			workloads[k] = v
+		}
+		cp := *c
+		cp.NodePools = pools
+		cp.Workloads = workloads
+		ret = append(ret, cp)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ID < ret[j].ID
+	})
+	return ret
+}
+
+func (s *Server) HealthCheck(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetVersion(_ context.Context, req *plugin.GetVersionRequest) (*plugin.ClusterVersion, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	c, err := s.cluster(req.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	return clusterVersion(c), nil
+}
+
+func clusterVersion(c *ClusterState) *plugin.ClusterVersion {
+	ids := make([]string, 0, len(c.NodePools))
+	for id := range c.NodePools {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	pools := make([]*plugin.NodePoolVersion, len(ids))
+	for i, id := range ids {
+		pools[i] = &plugin.NodePoolVersion{
+			ClusterID:  c.ID,
+			NodePoolID: id,
+			Version:    c.NodePools[id],
+		}
+	}
+	return &plugin.ClusterVersion{
+		Master: &plugin.MasterVersion{
+			ClusterID: c.ID,
+			Version:   c.MasterVersion,
+		},
+		NodePools: pools,
+	}
+}
+
+func (s *Server) GetClusterStatus(_ context.Context, req *plugin.GetClusterStatusRequest) (*plugin.ClusterStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	c, err := s.cluster(req.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	return clusterStatus(c), nil
+}
+
+func clusterStatus(c *ClusterState) *plugin.ClusterStatus {
+	st := plugin.ClusterStatusType_STATUS_SERVICE_OUT
+	if c.ServiceIn {
+		st = plugin.ClusterStatusType_STATUS_SERVICE_IN
+	}
+	return &plugin.ClusterStatus{
+		Status:      st,
+		IsAvailable: c.Available,
+	}
+}
+
+func (s *Server) GetOperationStatus(_ context.Context, req *plugin.GetOperationStatusRequest) (*plugin.OperationStatus, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyOperations()
+	op, ok := s.operations[req.OperationID]
+	if !ok || op.clusterID != req.ClusterID {
+		return &plugin.OperationStatus{Status: plugin.OperationStatusType_UNKNOWN}, nil
+	}
+	switch {
+	case s.now().Before(op.doneAt):
+		return &plugin.OperationStatus{Status: plugin.OperationStatusType_RUNNING}, nil
+	case op.failed:
+		return &plugin.OperationStatus{Status: plugin.OperationStatusType_FAILED}, nil
+	}
+	return &plugin.OperationStatus{Status: plugin.OperationStatusType_DONE}, nil
+}
+
+func (s *Server) ServiceIn(ctx context.Context, req *plugin.ServiceInRequest) (*plugin.Operation, error) {
+	return s.startOperation(ctx, req.ClusterID, OperationTypeServiceIn, "", func(c *ClusterState) {
+		c.ServiceIn = true
+	})
+}
+
+func (s *Server) ServiceOut(ctx context.Context, req *plugin.ServiceOutRequest) (*plugin.Operation, error) {
+	return s.startOperation(ctx, req.ClusterID, OperationTypeServiceOut, "", func(c *ClusterState) {
+		c.ServiceIn = false
+	})
+}
+
+func (s *Server) UpgradeMaster(ctx context.Context, req *plugin.MasterVersion) (*plugin.Operation, error) {
+	return s.startOperation(ctx, req.ClusterID, OperationTypeUpgradeMaster, "", func(c *ClusterState) {
+		c.MasterVersion = req.Version
+	})
+}
+
+func (s *Server) UpgradeNodePool(ctx context.Context, req *plugin.NodePoolVersion) (*plugin.Operation, error) {
+	s.lock.Lock()
+	c, err := s.cluster(req.ClusterID)
+	if err == nil {
+		if _, ok := c.NodePools[req.NodePoolID]; !ok {
+			err = status.Errorf(codes.NotFound, "node pool %s isn't found", req.NodePoolID)
+		}
+	}
+	s.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return s.startOperation(ctx, req.ClusterID, OperationTypeUpgradeNodePool, req.NodePoolID, func(c *ClusterState) {
+		c.NodePools[req.NodePoolID] = req.Version
+	})
+}
+
+// startOperation starts the operation, or returns the operation started by the request with the same idempotency key.
+func (s *Server) startOperation(ctx context.Context, clusterID string, opType string, target string, apply func(c *ClusterState)) (*plugin.Operation, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.cluster(clusterID); err != nil {
+		return nil, err
+	}
+	key := idempotencyKey(ctx)
+	if id, ok := s.idempotencyKeys[key]; ok && key != "" {
+		return &plugin.Operation{
+			Type:        s.operations[id].opType,
+			OperationID: id,
+		}, nil
+	}
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	if key != "" {
+		s.idempotencyKeys[key] = id
+	}
+	now := s.now()
+	op := &operation{
+		clusterID: clusterID,
+		opType:    opType,
+		target:    target,
+		startedAt: now,
+		doneAt:    now.Add(s.settings.operationDuration()),
+		failed:    s.random() < s.settings.FailureRate,
+	}
+	if !op.failed {
+		op.apply = apply
+	}
+	s.operations[id] = op
+	return &plugin.Operation{
+		Type:        opType,
+		OperationID: id,
+	}, nil
+}
+
+// applyOperations applies the operations which have done to the clusters.
+func (s *Server) applyOperations() {
+	now := s.now()
+	for _, op := range s.operations {
+		if op.apply == nil || now.Before(op.doneAt) {
+			continue
+		}
+		op.apply(s.clusters[op.clusterID])
+		op.apply = nil
+	}
+}
+
+func (s *Server) cluster(id string) (*ClusterState, error) {
+	c, ok := s.clusters[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %s isn't found", id)
+	}
+	return c, nil
+}
+
+func idempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(idempotencyKeyMetadata)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,139 @@
+package fakeplugin
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	"google.golang.org/grpc/metadata"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+const testClusterID = "projects/test/locations/asia-northeast1/clusters/cluster-1"
+
+func newTestServer(failureRate float64) (*Server, *time.Time) {
+	s := NewServer(Config{
+		Settings: Settings{
+			OperationDuration: &metav1.Duration{Duration: time.Minute},
+			FailureRate:       failureRate,
+		},
+		Clusters: []ClusterConfig{
+			{ID: testClusterID, Version: "1.16.13-gke.404", NodePools: []string{"pool-1", "pool-2"}},
+		},
+	})
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	s.random = func() float64 { return 0.5 }
+	return s, &now
+}
+
+func TestServer_UpgradeMaster(t *testing.T) {
+	testCases := []struct {
+		name            string
+		failureRate     float64
+		expectedStatus  plugin.OperationStatusType
+		expectedVersion string
+	}{
+		{
+			name:            "upgrade the master after the duration",
+			failureRate:     0,
+			expectedStatus:  plugin.OperationStatusType_DONE,
+			expectedVersion: "1.16.15-gke.4301",
+		},
+		{
+			name:            "keep the master version when the operation fails",
+			failureRate:     1,
+			expectedStatus:  plugin.OperationStatusType_FAILED,
+			expectedVersion: "1.16.13-gke.404",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			ctx := context.Background()
+			s, now := newTestServer(c.failureRate)
+
+			op, err := s.UpgradeMaster(ctx, &plugin.MasterVersion{ClusterID: testClusterID, Version: "1.16.15-gke.4301"})
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(op.Type).Should(Equal(OperationTypeUpgradeMaster))
+
+			st, err := s.GetOperationStatus(ctx, &plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: op.OperationID})
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(st.Status).Should(Equal(plugin.OperationStatusType_RUNNING))
+
+			*now = now.Add(time.Minute)
+			st, err = s.GetOperationStatus(ctx, &plugin.GetOperationStatusRequest{ClusterID: testClusterID, OperationID: op.OperationID})
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(st.Status).Should(Equal(c.expectedStatus))
+			cv, err := s.GetVersion(ctx, &plugin.GetVersionRequest{ClusterID: testClusterID})
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(cv.Master.Version).Should(Equal(c.expectedVersion))
+		})
+	}
+}
+
+func TestServer_UpgradeNodePool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	s, now := newTestServer(0)
+
+	cv, err := s.GetVersion(ctx, &plugin.GetVersionRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cv.NodePools).Should(HaveLen(2))
+	poolID := cv.NodePools[0].NodePoolID
+	g.Expect(poolID).Should(Equal(testClusterID + "/nodePools/pool-1"))
+
+	_, err = s.UpgradeNodePool(ctx, &plugin.NodePoolVersion{ClusterID: testClusterID, NodePoolID: "unknown", Version: "1.16.15-gke.4301"})
+	g.Expect(err).Should(HaveOccurred())
+	_, err = s.UpgradeNodePool(ctx, &plugin.NodePoolVersion{ClusterID: testClusterID, NodePoolID: poolID, Version: "1.16.15-gke.4301"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	*now = now.Add(time.Minute)
+	cv, err = s.GetVersion(ctx, &plugin.GetVersionRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cv.NodePools[0].Version).Should(Equal("1.16.15-gke.4301"))
+	g.Expect(cv.NodePools[1].Version).Should(Equal("1.16.13-gke.404"))
+}
+
+func TestServer_IdempotencyKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+	s, _ := newTestServer(0)
+	req := &plugin.MasterVersion{ClusterID: testClusterID, Version: "1.16.15-gke.4301"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyMetadata, "key-1"))
+	first, err := s.UpgradeMaster(ctx, req)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	retried, err := s.UpgradeMaster(ctx, req)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(retried.OperationID).Should(Equal(first.OperationID))
+	g.Expect(retried.Type).Should(Equal(OperationTypeUpgradeMaster))
+
+	another, err := s.UpgradeMaster(context.Background(), req)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(another.OperationID).ShouldNot(Equal(first.OperationID))
+}
+
+func TestServer_ServiceOutAndIn(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	s, now := newTestServer(0)
+
+	_, err := s.ServiceOut(ctx, &plugin.ServiceOutRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	*now = now.Add(time.Minute)
+	st, err := s.GetClusterStatus(ctx, &plugin.GetClusterStatusRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(st.Status).Should(Equal(plugin.ClusterStatusType_STATUS_SERVICE_OUT))
+	g.Expect(st.IsAvailable).Should(BeTrue())
+
+	_, err = s.ServiceIn(ctx, &plugin.ServiceInRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	*now = now.Add(time.Minute)
+	st, err = s.GetClusterStatus(ctx, &plugin.GetClusterStatusRequest{ClusterID: testClusterID})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(st.Status).Should(Equal(plugin.ClusterStatusType_STATUS_SERVICE_IN))
+
+	_, err = s.ServiceOut(ctx, &plugin.ServiceOutRequest{ClusterID: "unknown"})
+	g.Expect(err).Should(HaveOccurred())
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	defaultLabels       = []string{"event"}
+	successNotification = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "multicluster_clusterversion_success_notification_total",
+			Help: "Number of success notifications",
+		},
+		defaultLabels,
+	)
+	failedNotification = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "multicluster_clusterversion_failed_notification_total",
+			Help: "Number of failed notifications",
+		},
+		defaultLabels,
+	)
+)
+
+func addSuccessNotification(event string) {
+	successNotification.With(prometheus.Labels{"event": event}).Inc()
+}
+
+func addFailedNotification(event string) {
+	failedNotification.With(prometheus.Labels{"event": event}).Inc()
+}
+
+func init() {
+	metrics.Registry.MustRegister(successNotification, failedNotification)
+}
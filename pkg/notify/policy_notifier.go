@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSendTimeout = time.Second * 10
+)
+
+type policyNotifier struct {
+	client       client.Client
+	secretReader client.Reader
+	httpClient   *http.Client
+	log          logr.Logger
+
+	// lastHalts holds the last halt message per ClusterVersion not to repeat the same halt every sync.
+	lastHalts map[types.NamespacedName]string
+	lock      sync.Mutex
+}
+
+var _ Notifier = &policyNotifier{}
+
+// NewPolicyNotifier returns the Notifier which sends events according to NotificationPolicy resources.
+// Secrets are read through secretReader so that the controller doesn't have to cache all secrets.
+func NewPolicyNotifier(c client.Client, secretReader client.Reader, log logr.Logger) Notifier {
+	return &policyNotifier{
+		client:       c,
+		secretReader: secretReader,
+		httpClient:   &http.Client{Timeout: defaultSendTimeout},
+		log:          log,
+		lastHalts:    map[types.NamespacedName]string{},
+	}
+}
+
+// Notify resolves sinks synchronously and sends the event in background not to block reconciliation.
+func (n *policyNotifier) Notify(ctx context.Context, obj opsv1.ClusterVersion, ev Event) {
+	if !n.shouldNotify(obj, ev) {
+		return
+	}
+	log := n.log.WithValues("multicluster", types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}, "event", ev.Type)
+	sinks, err := n.resolveSinks(ctx, obj, ev.Type)
+	if err != nil {
+		log.Error(err, "failed to resolve notification sinks")
+		return
+	}
+	for name, sink := range sinks {
+		go func(name string, sink Sink) {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultSendTimeout)
+			defer cancel()
+			if err := sink.Send(ctx, ev); err != nil {
+				addFailedNotification(string(ev.Type))
+				log.Error(err, "failed to send notification", "sink", name)
+				return
+			}
+			addSuccessNotification(string(ev.Type))
+		}(name, sink)
+	}
+}
+
+// shouldNotify suppresses repeated halts until any other event happens on the ClusterVersion.
+func (n *policyNotifier) shouldNotify(obj opsv1.ClusterVersion, ev Event) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	key := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+	if ev.Type != opsv1.NotificationRolloutHalted {
+		delete(n.lastHalts, key)
+		return true
+	}
+	msg := ev.ClusterID + "/" + ev.Message
+	if n.lastHalts[key] == msg {
+		return false
+	}
+	n.lastHalts[key] = msg
+	return true
+}
+
+// Forget drops the last halt of the ClusterVersion.
+func (n *policyNotifier) Forget(name types.NamespacedName) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.lastHalts, name)
+}
+
+func (n *policyNotifier) resolveSinks(ctx context.Context, obj opsv1.ClusterVersion, t opsv1.NotificationEventType) (map[string]Sink, error) {
+	list := &opsv1.NotificationPolicyList{}
+	if err := n.client.List(ctx, list, client.InNamespace(obj.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list notification policies. err: %w", err)
+	}
+	sinks := map[string]Sink{}
+	for _, p := range list.Items {
+		if !p.Spec.Subscribes(t) {
+			continue
+		}
+		if p.Spec.Selector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(p.Spec.Selector)
+			if err != nil {
+				n.log.Error(err, "invalid selector", "policy", p.Name)
+				continue
+			}
+			if !selector.Matches(labels.Set(obj.Labels)) {
+				continue
+			}
+		}
+		for _, s := range p.Spec.Sinks {
+			url, err := n.readSecret(ctx, obj.Namespace, s.URLSecretRef)
+			if err != nil {
+				n.log.Error(err, "failed to read the url of the sink", "policy", p.Name, "sink", s.Name)
+				continue
+			}
+			sink, err := NewSink(s.Type, url, s.Template, n.httpClient)
+			if err != nil {
+				n.log.Error(err, "invalid sink", "policy", p.Name, "sink", s.Name)
+				continue
+			}
+			sinks[p.Name+"/"+s.Name] = sink
+		}
+	}
+	return sinks, nil
+}
+
+func (n *policyNotifier) readSecret(ctx context.Context, namespace string, ref corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := n.secretReader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return "", err
+	}
+	v, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("key %s is not found in secret %s", ref.Key, ref.Name)
+	}
+	return string(v), nil
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestPolicyNotifier_Notify(t *testing.T) {
+	g := NewGomegaWithT(t)
+	received := make(chan Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		ev := Event{}
+		_ = json.Unmarshal(b, &ev)
+		received <- ev
+	}))
+	defer server.Close()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = opsv1.AddToScheme(scheme)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "webhook"},
+		Data:       map[string][]byte{"url": []byte(server.URL)},
+	}
+	policy := &opsv1.NotificationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "failures"},
+		Spec: opsv1.NotificationPolicySpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "sre"}},
+			Events:   []opsv1.NotificationEventType{opsv1.NotificationOperationFailed, opsv1.NotificationRolloutHalted},
+			Sinks: []opsv1.NotificationSink{
+				{
+					Name: "webhook",
+					Type: opsv1.NotificationSinkWebhook,
+					URLSecretRef: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "webhook"},
+						Key:                  "url",
+					},
+				},
+			},
+		},
+	}
+	c := fake.NewFakeClientWithScheme(scheme, secret, policy)
+	n := NewPolicyNotifier(c, c, ctrl.Log)
+
+	selected := opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "selected", Labels: map[string]string{"team": "sre"}}}
+	notSelected := opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "not-selected"}}
+	event := func(obj opsv1.ClusterVersion, t opsv1.NotificationEventType) Event {
+		return Event{Type: t, Namespace: obj.Namespace, Name: obj.Name, ClusterID: "test-cluster", Message: "dummy"}
+	}
+
+	ctx := context.Background()
+	n.Notify(ctx, notSelected, event(notSelected, opsv1.NotificationOperationFailed))
+	n.Notify(ctx, selected, event(selected, opsv1.NotificationServiceOut))
+	n.Notify(ctx, selected, event(selected, opsv1.NotificationOperationFailed))
+	g.Eventually(received).Should(Receive(WithTransform(func(ev Event) string { return ev.Name + "/" + string(ev.Type) }, Equal("selected/OperationFailed"))))
+
+	n.Notify(ctx, selected, event(selected, opsv1.NotificationRolloutHalted))
+	g.Eventually(received).Should(Receive(WithTransform(func(ev Event) opsv1.NotificationEventType { return ev.Type }, Equal(opsv1.NotificationRolloutHalted))))
+
+	// the same halt isn't repeated
+	n.Notify(ctx, selected, event(selected, opsv1.NotificationRolloutHalted))
+	g.Consistently(received, time.Millisecond*100).ShouldNot(Receive())
+}
+
+func TestPolicyNotifier_Forget(t *testing.T) {
+	g := NewGomegaWithT(t)
+	n := NewPolicyNotifier(nil, nil, ctrl.Log).(*policyNotifier)
+	obj := opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "deleted"}}
+	halt := Event{Type: opsv1.NotificationRolloutHalted, ClusterID: "test-cluster", Message: "dummy"}
+
+	g.Expect(n.shouldNotify(obj, halt)).Should(BeTrue())
+	n.Forget(types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name})
+	g.Expect(n.lastHalts).Should(BeEmpty())
+
+	// the ClusterVersion created again with the same name is notified of the same halt
+	g.Expect(n.shouldNotify(obj, halt)).Should(BeTrue())
+}
@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+const (
+	defaultTemplate   = `[{{ .Type }}] {{ .Namespace }}/{{ .Name }}{{ if .ClusterID }} cluster: {{ .ClusterID }}{{ end }}{{ if .OperationType }} operation: {{ .OperationType }}{{ end }} {{ .Message }}`
+	cloudEventsSource = "multicluster-upgrade-operator"
+	cloudEventsPrefix = "io.multicluster-ops.clusterversion."
+)
+
+// NewSink returns the sink for the given type.
+func NewSink(t opsv1.NotificationSinkType, url string, tmpl string, client *http.Client) (Sink, error) {
+	if tmpl == "" {
+		tmpl = defaultTemplate
+	}
+	tp, err := template.New("notification").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template. err: %w", err)
+	}
+	switch t {
+	case opsv1.NotificationSinkWebhook:
+		return &webhookSink{url: url, template: tp, client: client}, nil
+	case opsv1.NotificationSinkSlack:
+		return &slackSink{url: url, template: tp, client: client}, nil
+	case opsv1.NotificationSinkCloudEvents:
+		return &cloudEventsSink{url: url, client: client}, nil
+	}
+	return nil, fmt.Errorf("no match sink type. type: %s", t)
+}
+
+// webhookSink posts the event as a generic JSON with the rendered text.
+type webhookSink struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+}
+
+type webhookPayload struct {
+	Event
+	Text string `json:"text"`
+}
+
+func (s *webhookSink) Send(ctx context.Context, ev Event) error {
+	text, err := render(s.template, ev)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, "application/json", webhookPayload{Event: ev, Text: text})
+}
+
+// slackSink posts the rendered text to a Slack-compatible incoming webhook.
+type slackSink struct {
+	url      string
+	template *template.Template
+	client   *http.Client
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+func (s *slackSink) Send(ctx context.Context, ev Event) error {
+	text, err := render(s.template, ev)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, "application/json", slackPayload{Text: text})
+}
+
+// cloudEventsSink posts the event as a CloudEvent in the structured content mode.
+type cloudEventsSink struct {
+	url    string
+	client *http.Client
+}
+
+type cloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Subject         string `json:"subject"`
+	Time            string `json:"time"`
+	DataContentType string `json:"datacontenttype"`
+	Data            Event  `json:"data"`
+}
+
+func (s *cloudEventsSink) Send(ctx context.Context, ev Event) error {
+	ce := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              string(uuid.NewUUID()),
+		Source:          cloudEventsSource,
+		Type:            cloudEventsPrefix + strings.ToLower(string(ev.Type)),
+		Subject:         ev.Namespace + "/" + ev.Name,
+		Time:            ev.Time.UTC().Format("2006-01-02T15:04:05.999999999Z"),
+		DataContentType: "application/json",
+		Data:            ev,
+	}
+	return post(ctx, s.client, s.url, "application/cloudevents+json", ce)
+}
+
+func render(tp *template.Template, ev Event) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tp.Execute(buf, ev); err != nil {
+		return "", fmt.Errorf("failed to render template. err: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func post(ctx context.Context, client *http.Client, url string, contentType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code from sink. status: %d", res.StatusCode)
+	}
+	return nil
+}
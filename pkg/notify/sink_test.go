@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func makeEvent() Event {
+	return Event{
+		Type:          opsv1.NotificationOperationFailed,
+		Namespace:     "default",
+		Name:          "test-clusters",
+		ClusterID:     "test-cluster",
+		OperationID:   "dummy_operation",
+		OperationType: "UPGRADE_MASTER",
+		Message:       "operation failed",
+		Time:          time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSink_Send(t *testing.T) {
+	testCases := []struct {
+		name                string
+		sinkType            opsv1.NotificationSinkType
+		template            string
+		expectedContentType string
+		expected            map[string]interface{}
+	}{
+		{
+			name:                "webhook",
+			sinkType:            opsv1.NotificationSinkWebhook,
+			template:            "{{ .Name }} {{ .Type }}",
+			expectedContentType: "application/json",
+			expected: map[string]interface{}{
+				"type":          "OperationFailed",
+				"namespace":     "default",
+				"name":          "test-clusters",
+				"clusterID":     "test-cluster",
+				"operationID":   "dummy_operation",
+				"operationType": "UPGRADE_MASTER",
+				"message":       "operation failed",
+				"time":          "2020-12-01T00:00:00Z",
+				"text":          "test-clusters OperationFailed",
+			},
+		},
+		{
+			name:                "slack with default template",
+			sinkType:            opsv1.NotificationSinkSlack,
+			expectedContentType: "application/json",
+			expected: map[string]interface{}{
+				"text": "[OperationFailed] default/test-clusters cluster: test-cluster operation: UPGRADE_MASTER operation failed",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g           = NewGomegaWithT(t)
+				body        map[string]interface{}
+				contentType string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				b, _ := ioutil.ReadAll(r.Body)
+				_ = json.Unmarshal(b, &body)
+			}))
+			defer server.Close()
+
+			sink, err := NewSink(testCase.sinkType, server.URL, testCase.template, server.Client())
+			g.Expect(err).Should(BeNil())
+			g.Expect(sink.Send(context.Background(), makeEvent())).Should(BeNil())
+			g.Expect(contentType).Should(Equal(testCase.expectedContentType))
+			g.Expect(body).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+func TestCloudEventsSink_Send(t *testing.T) {
+	var (
+		g           = NewGomegaWithT(t)
+		body        map[string]interface{}
+		contentType string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		b, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &body)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(opsv1.NotificationSinkCloudEvents, server.URL, "", server.Client())
+	g.Expect(err).Should(BeNil())
+	g.Expect(sink.Send(context.Background(), makeEvent())).Should(BeNil())
+	g.Expect(contentType).Should(Equal("application/cloudevents+json"))
+	g.Expect(body["specversion"]).Should(Equal("1.0"))
+	g.Expect(body["type"]).Should(Equal("io.multicluster-ops.clusterversion.operationfailed"))
+	g.Expect(body["subject"]).Should(Equal("default/test-clusters"))
+	g.Expect(body["time"]).Should(Equal("2020-12-01T00:00:00Z"))
+	g.Expect(body["id"]).ShouldNot(BeEmpty())
+	g.Expect(body["data"]).Should(HaveKeyWithValue("operationID", "dummy_operation"))
+}
+
+func TestSink_SendFailure(t *testing.T) {
+	g := NewGomegaWithT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(opsv1.NotificationSinkWebhook, server.URL, "", server.Client())
+	g.Expect(err).Should(BeNil())
+	g.Expect(sink.Send(context.Background(), makeEvent())).ShouldNot(BeNil())
+}
+
+func TestNewSink_InvalidTemplate(t *testing.T) {
+	g := NewGomegaWithT(t)
+	_, err := NewSink(opsv1.NotificationSinkSlack, "http://example.com", "{{ .Name ", http.DefaultClient)
+	g.Expect(err).ShouldNot(BeNil())
+}
@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"context"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+// Notifier notifies rollout lifecycle events to the sinks.
+type Notifier interface {
+	// Notify sends the event of the given ClusterVersion to the subscribing sinks.
+	Notify(ctx context.Context, obj opsv1.ClusterVersion, ev Event)
+	// Forget drops what's kept for the given ClusterVersion after it's deleted.
+	Forget(name types.NamespacedName)
+}
+
+// Sink sends an event to a destination.
+type Sink interface {
+	// Send sends the event.
+	Send(ctx context.Context, ev Event) error
+}
+
+// Event shows a rollout lifecycle event.
+type Event struct {
+	Type          opsv1.NotificationEventType `json:"type"`
+	Namespace     string                      `json:"namespace"`
+	Name          string                      `json:"name"`
+	ClusterID     string                      `json:"clusterID,omitempty"`
+	OperationID   string                      `json:"operationID,omitempty"`
+	OperationType string                      `json:"operationType,omitempty"`
+	Message       string                      `json:"message"`
+	Time          time.Time                   `json:"time"`
+}
@@ -0,0 +1,215 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"sync"
+	"time"
+)
+
+type snapshotContextKey struct{}
+
+// snapshot holds the cluster statuses and versions read in a reconciliation.
+type snapshot struct {
+	entries map[string]interface{}
+	lock    sync.Mutex
+}
+
+// WithSnapshot returns the context in which the Operator returned by NewCachingOperator reads the status and
+// the versions of each cluster at most once, so that all decisions in a reconciliation are made on the same data.
+func WithSnapshot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, snapshotContextKey{}, &snapshot{entries: map[string]interface{}{}})
+}
+
+func snapshotFromContext(ctx context.Context) *snapshot {
+	s, _ := ctx.Value(snapshotContextKey{}).(*snapshot)
+	return s
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// cachingOperator caches GetClusterStatus and GetClusterVersion of the wrapped Operator in the snapshot of the context,
+// and in the cache shared by all reconciliations for ttl. The entries of a cluster are dropped when an operation
+// is requested for it or its operation completes. The errors aren't cached.
+type cachingOperator struct {
+	wrapped
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]cacheEntry
+	lock    sync.Mutex
+}
+
+var _ extendedOperator = &cachingOperator{}
+
+// NewCachingOperator returns the Operator which caches the cluster statuses and versions of the given Operator
+// keyed by the endpoint and the cluster ID. Only the snapshot of WithSnapshot is used if ttl is 0.
+func NewCachingOperator(operator Operator, ttl time.Duration) Operator {
+	return &cachingOperator{
+		wrapped: wrapped{operator},
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+func clusterCacheKey(obj opsv1.ClusterVersion, clusterID string) string {
+	ep := obj.Spec.GetOpsEndpoint(clusterID)
+	return fmt.Sprintf("%s|%s|%s|%s", ep.Provider, ep.Protocol, ep.Endpoint, clusterID)
+}
+
+// get returns the cached value of the method for the cluster, or loads and caches it.
+func (c *cachingOperator) get(ctx context.Context, method string, obj opsv1.ClusterVersion, clusterID string, load func() (interface{}, error)) (interface{}, error) {
+	key := method + "|" + clusterCacheKey(obj, clusterID)
+	s := snapshotFromContext(ctx)
+	if s != nil {
+		s.lock.Lock()
+		v, ok := s.entries[key]
+		s.lock.Unlock()
+		if ok {
+			addCacheHit(method)
+			return v, nil
+		}
+	}
+	if c.ttl > 0 {
+		c.lock.Lock()
+		e, ok := c.entries[key]
+		c.lock.Unlock()
+		if ok && c.now().Before(e.expires) {
+			addCacheHit(method)
+			if s != nil {
+				s.lock.Lock()
+				s.entries[key] = e.value
+				s.lock.Unlock()
+			}
+			return e.value, nil
+		}
+	}
+	addCacheMiss(method)
+	v, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.put(ctx, key, v)
+	return v, nil
+}
+
+// put caches the value in the snapshot of the context and in the shared cache.
+func (c *cachingOperator) put(ctx context.Context, key string, v interface{}) {
+	if s := snapshotFromContext(ctx); s != nil {
+		s.lock.Lock()
+		s.entries[key] = v
+		s.lock.Unlock()
+	}
+	if c.ttl > 0 {
+		c.lock.Lock()
+		c.entries[key] = cacheEntry{value: v, expires: c.now().Add(c.ttl)}
+		c.lock.Unlock()
+	}
+}
+
+// invalidate drops the cached values of the cluster, whose status or versions are changing.
+func (c *cachingOperator) invalidate(ctx context.Context, obj opsv1.ClusterVersion, clusterID string) {
+	keys := []string{
+		metricsGetClusterStatus + "|" + clusterCacheKey(obj, clusterID),
+		metricsGetClusterVersion + "|" + clusterCacheKey(obj, clusterID),
+	}
+	if s := snapshotFromContext(ctx); s != nil {
+		s.lock.Lock()
+		for _, key := range keys {
+			delete(s.entries, key)
+		}
+		s.lock.Unlock()
+	}
+	c.lock.Lock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	c.lock.Unlock()
+}
+
+func (c *cachingOperator) GetOperationStatus(ctx context.Context, obj opsv1.ClusterVersion) (OperationStatus, error) {
+	status, err := c.Operator.GetOperationStatus(ctx, obj)
+	if err == nil && (status == OperationStatusDone || status == OperationStatusFailed) {
+		c.invalidate(ctx, obj, obj.Status.ClusterID)
+	}
+	return status, err
+}
+
+func (c *cachingOperator) GetClusterVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterVersion, error) {
+	v, err := c.get(ctx, metricsGetClusterVersion, obj, cluster.ID, func() (interface{}, error) {
+		return c.Operator.GetClusterVersion(ctx, obj, cluster)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cv := *v.(*ClusterVersion)
+	cv.NodePools = append([]NodePoolVersion{}, cv.NodePools...)
+	return &cv, nil
+}
+
+func (c *cachingOperator) GetClusterStatus(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterStatus, error) {
+	v, err := c.get(ctx, metricsGetClusterStatus, obj, cluster.ID, func() (interface{}, error) {
+		return c.Operator.GetClusterStatus(ctx, obj, cluster)
+	})
+	if err != nil {
+		return nil, err
+	}
+	cs := *v.(*ClusterStatus)
+	return &cs, nil
+}
+
+func (c *cachingOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	c.invalidate(ctx, obj, cluster.ID)
+	return c.Operator.ServiceIn(ctx, obj, cluster)
+}
+
+func (c *cachingOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	c.invalidate(ctx, obj, cluster.ID)
+	return c.Operator.ServiceOut(ctx, obj, cluster)
+}
+
+func (c *cachingOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	c.invalidate(ctx, obj, cluster.ID)
+	return c.Operator.UpgradeMaster(ctx, obj, cluster)
+}
+
+func (c *cachingOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+	c.invalidate(ctx, obj, cluster.ID)
+	return c.Operator.UpgradeNodePool(ctx, obj, cluster, nodePoolID)
+}
+
+func (c *cachingOperator) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	do, ok := c.Operator.(OperationDetailOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	detail, err := do.GetOperationDetail(ctx, obj)
+	if err == nil && (detail.Status == OperationStatusDone || detail.Status == OperationStatusFailed) {
+		c.invalidate(ctx, obj, obj.Status.ClusterID)
+	}
+	return detail, err
+}
+
+// GetFleetStatus caches the versions and the statuses of the clusters, so that GetClusterVersion and GetClusterStatus
+// of the clusters are served from the cache afterwards.
+func (c *cachingOperator) GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error) {
+	fo, ok := c.Operator.(FleetStatusOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	statuses, err := fo.GetFleetStatus(ctx, obj, clusters)
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		st := statuses[i]
+		key := clusterCacheKey(obj, st.ClusterID)
+		c.put(ctx, metricsGetClusterVersion+"|"+key, &st.Version)
+		c.put(ctx, metricsGetClusterStatus+"|"+key, &st.Status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,159 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"testing"
+	"time"
+)
+
+// countingOperator counts the reads of the wrapped Operator, and fails them while err is set.
+type countingOperator struct {
+	Operator
+	statusCalls  int
+	versionCalls int
+	err          error
+}
+
+func (c *countingOperator) GetClusterStatus(ctx context.Context, obj v1.ClusterVersion, cluster v1.Cluster) (*ClusterStatus, error) {
+	c.statusCalls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.Operator.GetClusterStatus(ctx, obj, cluster)
+}
+
+func (c *countingOperator) GetClusterVersion(ctx context.Context, obj v1.ClusterVersion, cluster v1.Cluster) (*ClusterVersion, error) {
+	c.versionCalls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.Operator.GetClusterVersion(ctx, obj, cluster)
+}
+
+// fleetOperator returns the fleet status of the wrapped Operator without counting the reads.
+type fleetOperator struct {
+	*countingOperator
+	fleetCalls int
+}
+
+func (f *fleetOperator) GetFleetStatus(ctx context.Context, obj v1.ClusterVersion, clusters []v1.Cluster) ([]FleetClusterStatus, error) {
+	f.fleetCalls++
+	var ret []FleetClusterStatus
+	for _, c := range clusters {
+		cv, err := f.Operator.GetClusterVersion(ctx, obj, c)
+		if err != nil {
+			return nil, err
+		}
+		cs, err := f.Operator.GetClusterStatus(ctx, obj, c)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, FleetClusterStatus{ClusterID: c.ID, Version: *cv, Status: *cs})
+	}
+	return ret, nil
+}
+
+func TestCachingOperator_Snapshot(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	cluster := v1.Cluster{ID: "test-cluster", Version: "1.16.13-gke.404"}
+	counter := &countingOperator{Operator: newFakeFleetOperator(cluster.ID, cluster.Version)}
+	op := NewCachingOperator(counter, 0)
+
+	// nothing is cached without the snapshot
+	_, err := op.GetClusterStatus(context.Background(), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	_, err = op.GetClusterStatus(context.Background(), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(2))
+
+	ctx := WithSnapshot(context.Background())
+	for i := 0; i < 3; i++ {
+		cs, err := op.GetClusterStatus(ctx, *obj, cluster)
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(cs.Type).Should(Equal(ClusterStatusServiceIn))
+		cv, err := op.GetClusterVersion(ctx, *obj, cluster)
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(cv.Master.Version).Should(Equal(cluster.Version))
+	}
+	g.Expect(counter.statusCalls).Should(Equal(3))
+	g.Expect(counter.versionCalls).Should(Equal(1))
+
+	// the operation drops the snapshot of the cluster
+	_, err = op.ServiceOut(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	_, err = op.GetClusterStatus(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(4))
+
+	// another reconciliation reads again
+	_, err = op.GetClusterStatus(WithSnapshot(context.Background()), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(5))
+}
+
+func TestCachingOperator_TTL(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	cluster := v1.Cluster{ID: "test-cluster", Version: "1.16.13-gke.404"}
+	counter := &countingOperator{Operator: newFakeFleetOperator(cluster.ID, cluster.Version)}
+	op := NewCachingOperator(counter, time.Minute).(*cachingOperator)
+	now := time.Now()
+	op.now = func() time.Time { return now }
+
+	_, err := op.GetClusterStatus(WithSnapshot(context.Background()), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	_, err = op.GetClusterStatus(WithSnapshot(context.Background()), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(1))
+
+	// another endpoint has another entry
+	other := obj.DeepCopy()
+	other.Spec.OpsEndpoint.Endpoint = "other.example.com"
+	_, err = op.GetClusterStatus(context.Background(), *other, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(2))
+
+	now = now.Add(time.Minute)
+	_, err = op.GetClusterStatus(context.Background(), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(3))
+
+	// the errors aren't cached
+	counter.err = errors.New("unavailable")
+	op.invalidate(context.Background(), *obj, cluster.ID)
+	_, err = op.GetClusterStatus(context.Background(), *obj, cluster)
+	g.Expect(err).Should(HaveOccurred())
+	counter.err = nil
+	_, err = op.GetClusterStatus(context.Background(), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(counter.statusCalls).Should(Equal(5))
+}
+
+func TestCachingOperator_FleetStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	cluster := v1.Cluster{ID: "test-cluster", Version: "1.16.13-gke.404"}
+	fleet := &fleetOperator{countingOperator: &countingOperator{Operator: newFakeFleetOperator(cluster.ID, cluster.Version)}}
+	op := NewCachingOperator(fleet, 0)
+
+	ctx := WithSnapshot(context.Background())
+	_, err := op.(FleetStatusOperator).GetFleetStatus(ctx, *obj, []v1.Cluster{cluster})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	cs, err := op.GetClusterStatus(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cs.Type).Should(Equal(ClusterStatusServiceIn))
+	cv, err := op.GetClusterVersion(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(cv.Master.Version).Should(Equal(cluster.Version))
+	g.Expect(fleet.fleetCalls).Should(Equal(1))
+	g.Expect(fleet.statusCalls).Should(Equal(0))
+	g.Expect(fleet.versionCalls).Should(Equal(0))
+
+	// the wrapped Operator doesn't support it
+	_, err = NewCachingOperator(fleet.countingOperator, 0).(FleetStatusOperator).GetFleetStatus(ctx, *obj, []v1.Cluster{cluster})
+	g.Expect(err).Should(MatchError(ErrNotSupported))
+}
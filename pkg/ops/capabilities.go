@@ -0,0 +1,69 @@
+package ops
+
+import (
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"sync"
+	"time"
+)
+
+// defaultCapabilitiesTTL is how long the capabilities of a cluster are cached. They change only when the plugin server
+// is updated, and the controller asks for them on every reconcile.
+const defaultCapabilitiesTTL = time.Minute * 10
+
+// capabilitiesCache caches the capabilities of each cluster keyed by the endpoint and the cluster ID.
+// The errors aren't cached.
+type capabilitiesCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]cacheEntry
+	lock    sync.Mutex
+}
+
+func newCapabilitiesCache(ttl time.Duration) *capabilitiesCache {
+	return &capabilitiesCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// get returns the cached capabilities of the cluster, or loads and caches them.
+func (c *capabilitiesCache) get(obj opsv1.ClusterVersion, clusterID string, load func() (*Capabilities, error)) (*Capabilities, error) {
+	key := clusterCacheKey(obj, clusterID)
+	c.lock.Lock()
+	e, ok := c.entries[key]
+	c.lock.Unlock()
+	if ok && c.now().Before(e.expires) {
+		addCacheHit(metricsGetCapabilities)
+		return copyCapabilities(e.value.(*Capabilities)), nil
+	}
+	addCacheMiss(metricsGetCapabilities)
+	caps, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	c.entries[key] = cacheEntry{value: caps, expires: c.now().Add(c.ttl)}
+	c.lock.Unlock()
+	return copyCapabilities(caps), nil
+}
+
+func copyCapabilities(caps *Capabilities) *Capabilities {
+	cp := *caps
+	if caps.AvailableVersions != nil {
+		cp.AvailableVersions = append([]string{}, caps.AvailableVersions...)
+	}
+	return &cp
+}
+
+// negotiateProtocol returns ErrUnsupportedProtocol if the protocol version chosen by the plugin server
+// isn't one of ProtocolVersions.
+func negotiateProtocol(version string) error {
+	for _, v := range ProtocolVersions {
+		if v == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedProtocol, version)
+}
@@ -0,0 +1,61 @@
+package ops
+
+import (
+	"errors"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"google.golang.org/grpc"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sync"
+)
+
+// ConnPool keeps a gRPC connection per endpoint of the plugin servers, instead of dialing on every call.
+type ConnPool struct {
+	dial  func(endpoint opsv1.OpsEndpoint) (*grpc.ClientConn, error)
+	conns map[opsv1.OpsEndpoint]*grpc.ClientConn
+	// closed is true after Close, so that nothing is dialed while the controller stops.
+	closed bool
+	lock   sync.Mutex
+}
+
+// errPoolClosed is returned for the calls after the pool has been closed.
+var errPoolClosed = errors.New("the connection pool is closed")
+
+// NewConnPool returns the empty pool. The connections are dialed on the first call to each endpoint.
+func NewConnPool() *ConnPool {
+	return &ConnPool{
+		dial:  dialPlugin,
+		conns: map[opsv1.OpsEndpoint]*grpc.ClientConn{},
+	}
+}
+
+// NewFunc is passed to NewPluginOperator to use the pooled connections. The closer doesn't close the connection.
+func (p *ConnPool) NewFunc(endpoint opsv1.OpsEndpoint) (PluginClient, func(), error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		return nil, nil, errPoolClosed
+	}
+	conn, ok := p.conns[endpoint]
+	if !ok {
+		var err error
+		conn, err = p.dial(endpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.conns[endpoint] = conn
+	}
+	return NewPluginClient(conn), func() {}, nil
+}
+
+// Close closes all connections. No connection is dialed afterwards.
+func (p *ConnPool) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	for endpoint, conn := range p.conns {
+		if err := conn.Close(); err != nil {
+			ctrl.Log.Error(err, "failed to close connection", "endpoint", endpoint.Endpoint)
+		}
+		delete(p.conns, endpoint)
+	}
+}
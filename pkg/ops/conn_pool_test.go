@@ -0,0 +1,37 @@
+package ops
+
+import (
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"google.golang.org/grpc"
+	"testing"
+)
+
+func TestConnPool(t *testing.T) {
+	g := NewGomegaWithT(t)
+	pool := NewConnPool()
+	dialed := 0
+	pool.dial = func(endpoint v1.OpsEndpoint) (*grpc.ClientConn, error) {
+		dialed++
+		return dialPlugin(endpoint)
+	}
+	endpoint := v1.OpsEndpoint{Endpoint: "localhost:39000", Insecure: true}
+
+	for i := 0; i < 3; i++ {
+		c, closer, err := pool.NewFunc(endpoint)
+		g.Expect(err).ShouldNot(HaveOccurred())
+		g.Expect(c).ShouldNot(BeNil())
+		closer()
+	}
+	g.Expect(dialed).Should(Equal(1))
+
+	_, _, err := pool.NewFunc(v1.OpsEndpoint{Endpoint: "localhost:39001", Insecure: true})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(dialed).Should(Equal(2))
+
+	// no connection is dialed after closed
+	pool.Close()
+	_, _, err = pool.NewFunc(endpoint)
+	g.Expect(err).Should(MatchError(errPoolClosed))
+	g.Expect(dialed).Should(Equal(2))
+}
@@ -0,0 +1,7 @@
+// Package ops calls the plugin servers which perform the operations of the clusters.
+//
+// Operator has the operations which every plugin server supports. The others, e.g. WorkloadOperator and WatchOperator,
+// are optional interfaces. The controller detects them with type assertions, and falls back to Operator if the call
+// returns ErrNotSupported. The Operators wrapping another Operator, e.g. the tracing and the caching ones, implement
+// all of them and return ErrNotSupported if the wrapped one doesn't implement it.
+package ops
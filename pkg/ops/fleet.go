@@ -0,0 +1,32 @@
+package ops
+
+import (
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+)
+
+// groupClusters groups the clusters by the key, e.g. the endpoint of their plugin server, in order of the first
+// appearance, so GetFleetStatus can be called once per group. The key must be comparable.
+func groupClusters(clusters []opsv1.Cluster, key func(c opsv1.Cluster) interface{}) [][]opsv1.Cluster {
+	var groups [][]opsv1.Cluster
+	index := map[interface{}]int{}
+	for _, c := range clusters {
+		k := key(c)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], c)
+	}
+	return groups
+}
+
+// clusterIDs returns the IDs of the clusters.
+func clusterIDs(clusters []opsv1.Cluster) []string {
+	ids := make([]string, len(clusters))
+	for i, c := range clusters {
+		ids[i] = c.ID
+	}
+	return ids
+}
@@ -0,0 +1,390 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/remote"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"os"
+	"path/filepath"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OperationTypeHelmUpgrade is the operation type of upgrading the Helm release of a workload.
+	OperationTypeHelmUpgrade = "HELM_UPGRADE"
+
+	defaultHelmTimeout = time.Minute * 5
+
+	// interruptedDescription is the description of the release whose upgrade has been interrupted.
+	interruptedDescription = "the upgrade has been interrupted, e.g. by the restart of the controller"
+)
+
+type helmConfigFunc func(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, namespace string) (*action.Configuration, error)
+
+type helmChartFunc func(workload opsv1.Workload) (*chart.Chart, error)
+
+// helmOperator upgrades the Helm workloads directly with the kubeconfig of the cluster.
+// Other operations are delegated to the wrapped Operator.
+type helmOperator struct {
+	wrapped
+	newConfig helmConfigFunc
+	loadChart helmChartFunc
+
+	// running holds the functions canceling the upgrades in progress in this process by the operation IDs.
+	running map[string]context.CancelFunc
+	// started holds the operations by the idempotency keys of the requests which started them,
+	// until they're seen completed.
+	started map[string]*OperationResult
+	// upgrades tracks the goroutines running the upgrades.
+	upgrades sync.WaitGroup
+	lock     sync.Mutex
+}
+
+var _ extendedOperator = &helmOperator{}
+var _ Resetter = &helmOperator{}
+
+// NewHelmOperator returns the Operator which upgrades the Helm workloads and delegates others to the given Operator.
+// The kubeconfig of each cluster is read from the Secret referred by spec.clusters[].kubeconfigSecretRef.
+func NewHelmOperator(operator Operator, secretReader client.Reader) Operator {
+	settings := cli.New()
+	cacheDir := filepath.Join(os.TempDir(), "helm")
+	settings.RepositoryCache = filepath.Join(cacheDir, "repository")
+	settings.RepositoryConfig = filepath.Join(cacheDir, "repositories.yaml")
+	return newHelmOperator(operator, newKubeconfigHelmConfig(secretReader), newRepoChartLoader(settings))
+}
+
+func newHelmOperator(operator Operator, newConfig helmConfigFunc, loadChart helmChartFunc) *helmOperator {
+	return &helmOperator{
+		wrapped:   wrapped{operator},
+		newConfig: newConfig,
+		loadChart: loadChart,
+		running:   map[string]context.CancelFunc{},
+		started:   map[string]*OperationResult{},
+	}
+}
+
+func newKubeconfigHelmConfig(secretReader client.Reader) helmConfigFunc {
+	log := ctrl.Log.WithName("helm")
+	return func(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, namespace string) (*action.Configuration, error) {
+		kubeconfig, err := remote.Kubeconfig(ctx, secretReader, obj, cluster)
+		if err != nil {
+			return nil, err
+		}
+		getter, err := remote.NewRESTClientGetter(ctx, kubeconfig, namespace)
+		if err != nil {
+			return nil, err
+		}
+		cfg := &action.Configuration{}
+		debug := func(format string, v ...interface{}) {
+			log.V(1).Info(fmt.Sprintf(format, v...), "cluster", cluster.ID)
+		}
+		if err := cfg.Init(getter, namespace, "secret", debug); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+}
+
+func newRepoChartLoader(settings *cli.EnvSettings) helmChartFunc {
+	return func(workload opsv1.Workload) (*chart.Chart, error) {
+		opts := action.ChartPathOptions{
+			RepoURL: workload.Helm.RepoURL,
+			Version: workload.Version,
+		}
+		path, err := opts.LocateChart(workload.Helm.Chart, settings)
+		if err != nil {
+			return nil, err
+		}
+		return loader.Load(path)
+	}
+}
+
+func (h *helmOperator) GetOperationStatus(ctx context.Context, obj opsv1.ClusterVersion) (OperationStatus, error) {
+	if obj.Status.OperationType != OperationTypeHelmUpgrade {
+		return h.Operator.GetOperationStatus(ctx, obj)
+	}
+	detail, err := h.helmOperationDetail(ctx, obj)
+	if err != nil {
+		return OperationStatusUnknown, err
+	}
+	return detail.Status, nil
+}
+
+// GetOperationDetail reports the description of the release as the message of the Helm upgrade.
+func (h *helmOperator) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	if obj.Status.OperationType != OperationTypeHelmUpgrade {
+		return h.wrapped.GetOperationDetail(ctx, obj)
+	}
+	return h.helmOperationDetail(ctx, obj)
+}
+
+func (h *helmOperator) helmOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	opID := obj.Status.OperationID
+	namespace, name, revision, err := parseHelmOperationID(opID)
+	if err != nil {
+		return nil, err
+	}
+	h.lock.Lock()
+	_, running := h.running[opID]
+	h.lock.Unlock()
+
+	cluster, ok := findCluster(obj, obj.Status.ClusterID)
+	if !ok {
+		return nil, fmt.Errorf("cluster %s isn't found", obj.Status.ClusterID)
+	}
+	cfg, err := h.newConfig(ctx, obj, cluster, namespace)
+	if err != nil {
+		return nil, err
+	}
+	get := action.NewGet(cfg)
+	get.Version = revision
+	rel, err := get.Run(name)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		if running {
+			return &OperationDetail{Status: OperationStatusRunning}, nil
+		}
+		// the upgrade failed before recording the release, or the controller restarted in the meantime.
+		h.forget(opID)
+		return &OperationDetail{Status: OperationStatusFailed, Error: "release isn't found"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	detail := &OperationDetail{
+		Status:  OperationStatusUnknown,
+		Message: rel.Info.Description,
+	}
+	switch {
+	case rel.Info.Status == release.StatusDeployed || rel.Info.Status == release.StatusSuperseded:
+		detail.Status = OperationStatusDone
+	case rel.Info.Status.IsPending() && running:
+		detail.Status = OperationStatusRunning
+	case rel.Info.Status.IsPending():
+		// no goroutine of this process will complete the release
+		detail.Status = OperationStatusFailed
+		detail.Error = interruptedDescription
+	case rel.Info.Status == release.StatusFailed:
+		detail.Status = OperationStatusFailed
+		detail.Error = rel.Info.Description
+	}
+	if detail.Status == OperationStatusDone || detail.Status == OperationStatusFailed {
+		h.forget(opID)
+	}
+	return detail, nil
+}
+
+// forget drops the completed operation from started, so the requests with its key start another one.
+func (h *helmOperator) forget(opID string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for key, result := range h.started {
+		if result.OperationID == opID {
+			delete(h.started, key)
+		}
+	}
+}
+
+func (h *helmOperator) GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	if workload.Helm == nil {
+		return h.wrapped.GetWorkloadVersion(ctx, obj, cluster, workload)
+	}
+	cfg, err := h.newConfig(ctx, obj, cluster, workload.Helm.Namespace)
+	if err != nil {
+		return "", err
+	}
+	rel, err := action.NewGet(cfg).Run(workload.Helm.ReleaseName)
+	if err != nil {
+		return "", err
+	}
+	version := rel.Chart.Metadata.Version
+	// report a different version to upgrade the release again
+	if rel.Info.Status != release.StatusDeployed {
+		return fmt.Sprintf("%s (%s)", version, rel.Info.Status), nil
+	}
+	values, err := helmValues(workload)
+	if err != nil {
+		return "", err
+	}
+	same, err := sameValues(values, rel.Config)
+	if err != nil {
+		return "", err
+	}
+	if !same {
+		return fmt.Sprintf("%s (values differ)", version), nil
+	}
+	return version, nil
+}
+
+// UpgradeWorkload starts upgrading the Helm release in background and returns the operation of the next revision.
+// The upgrade is canceled by Reset.
+func (h *helmOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	if workload.Helm == nil {
+		return h.wrapped.UpgradeWorkload(ctx, obj, cluster, workload)
+	}
+	key := IdempotencyKeyFromContext(ctx)
+	h.lock.Lock()
+	started, ok := h.started[key]
+	h.lock.Unlock()
+	if ok && key != "" {
+		return started, nil
+	}
+	// the clients of the config are used until the upgrade completes, not only during the request
+	upgradeCtx, cancel := context.WithCancel(context.Background())
+	result, err := h.startUpgrade(upgradeCtx, cancel, obj, cluster, workload, key)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return result, nil
+}
+
+func (h *helmOperator) startUpgrade(ctx context.Context, cancel context.CancelFunc, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload, key string) (*OperationResult, error) {
+	cfg, err := h.newConfig(ctx, obj, cluster, workload.Helm.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	last, err := cfg.Releases.Last(workload.Helm.ReleaseName)
+	if err != nil {
+		return nil, err
+	}
+	if last.Info.Status.IsPending() {
+		h.lock.Lock()
+		_, running := h.running[helmOperationID(last.Namespace, last.Name, last.Version)]
+		h.lock.Unlock()
+		if running {
+			return nil, fmt.Errorf("release %s is being upgraded", last.Name)
+		}
+		// the release left pending by the interrupted upgrade would make Helm refuse the next one
+		last.SetStatus(release.StatusFailed, interruptedDescription)
+		if err := cfg.Releases.Update(last); err != nil {
+			return nil, err
+		}
+	}
+	values, err := helmValues(workload)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := h.loadChart(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Namespace = workload.Helm.Namespace
+	upgrade.Wait = true
+	upgrade.ResetValues = true
+	upgrade.Timeout = defaultHelmTimeout
+	if workload.Helm.Timeout != nil {
+		upgrade.Timeout = workload.Helm.Timeout.Duration
+	}
+	opID := helmOperationID(workload.Helm.Namespace, workload.Helm.ReleaseName, last.Version+1)
+	result := &OperationResult{
+		OperationID:   opID,
+		OperationType: OperationTypeHelmUpgrade,
+	}
+	h.lock.Lock()
+	h.running[opID] = cancel
+	if key != "" {
+		h.started[key] = result
+	}
+	h.upgrades.Add(1)
+	h.lock.Unlock()
+	go func() {
+		defer h.upgrades.Done()
+		defer cancel()
+		if _, err := upgrade.Run(workload.Helm.ReleaseName, ch, values); err != nil {
+			ctrl.Log.WithName("helm").Error(err, "failed to upgrade release", "cluster", cluster.ID, "operation_id", opID)
+		}
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		delete(h.running, opID)
+	}()
+	return result, nil
+}
+
+// Reset cancels the upgrades in progress and waits for them to end when the controller stops or loses the leadership.
+// The releases left pending by them are reported as failed, and the next upgrade marks them failed.
+func (h *helmOperator) Reset() {
+	h.lock.Lock()
+	for _, cancel := range h.running {
+		cancel()
+	}
+	h.started = map[string]*OperationResult{}
+	h.lock.Unlock()
+	h.upgrades.Wait()
+}
+
+// WatchOperation isn't supported for the Helm upgrades, so their status is polled.
+func (h *helmOperator) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	if obj.Status.OperationType == OperationTypeHelmUpgrade {
+		return nil, ErrNotSupported
+	}
+	return h.wrapped.WatchOperation(ctx, obj)
+}
+
+func helmOperationID(namespace, name string, revision int) string {
+	return fmt.Sprintf("%s/%s/%d", namespace, name, revision)
+}
+
+func parseHelmOperationID(opID string) (namespace string, name string, revision int, err error) {
+	parts := strings.Split(opID, "/")
+	if len(parts) != 3 {
+		return "", "", 0, fmt.Errorf("invalid helm operation id: %s", opID)
+	}
+	revision, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid helm operation id: %s", opID)
+	}
+	return parts[0], parts[1], revision, nil
+}
+
+func helmValues(workload opsv1.Workload) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if workload.Helm.Values == nil || len(workload.Helm.Values.Raw) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(workload.Helm.Values.Raw, &values); err != nil {
+		return nil, fmt.Errorf("invalid values of workload %s: %w", workload.Name, err)
+	}
+	return values, nil
+}
+
+// sameValues compares the values through JSON because the release values may have different Go types.
+func sameValues(desired, current map[string]interface{}) (bool, error) {
+	if len(desired) == 0 && len(current) == 0 {
+		return true, nil
+	}
+	raw, err := json.Marshal(current)
+	if err != nil {
+		return false, err
+	}
+	normalized := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(desired, normalized), nil
+}
+
+func findCluster(obj opsv1.ClusterVersion, clusterID string) (opsv1.Cluster, bool) {
+	for _, c := range obj.Spec.Clusters {
+		if c.ID == clusterID {
+			return c, true
+		}
+	}
+	return opsv1.Cluster{}, false
+}
@@ -0,0 +1,307 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"testing"
+	"time"
+)
+
+func makeHelmChart(version string) *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "istiod", Version: version},
+	}
+}
+
+func makeHelmWorkload(version string, values string) v1.Workload {
+	w := v1.Workload{
+		Name:    "istio",
+		Version: version,
+		Helm: &v1.HelmWorkload{
+			ReleaseName: "istiod",
+			Namespace:   "istio-system",
+			Chart:       "istiod",
+			RepoURL:     "https://charts.example.com",
+		},
+	}
+	if values != "" {
+		w.Helm.Values = &runtime.RawExtension{Raw: []byte(values)}
+	}
+	return w
+}
+
+func makeHelmConfig(t *testing.T, status release.Status) *action.Configuration {
+	mem := driver.NewMemory()
+	mem.SetNamespace("istio-system")
+	cfg := &action.Configuration{
+		Releases:     storage.Init(mem),
+		KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		Capabilities: chartutil.DefaultCapabilities,
+		Log:          t.Logf,
+	}
+	err := cfg.Releases.Create(&release.Release{
+		Name:      "istiod",
+		Namespace: "istio-system",
+		Version:   1,
+		Chart:     makeHelmChart("1.7.5"),
+		Config:    map[string]interface{}{"global": map[string]interface{}{"hub": "docker.io/istio"}},
+		Info:      &release.Info{Status: status},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func newTestHelmOperator(cfg *action.Configuration) *helmOperator {
+	return newHelmOperator(onlyOperator{NewPluginOperator(nil)},
+		func(_ context.Context, _ v1.ClusterVersion, _ v1.Cluster, _ string) (*action.Configuration, error) {
+			return cfg, nil
+		},
+		func(workload v1.Workload) (*chart.Chart, error) {
+			return makeHelmChart(workload.Version), nil
+		},
+	)
+}
+
+func TestHelmOperator_GetWorkloadVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   release.Status
+		workload v1.Workload
+		expected string
+	}{
+		{
+			name:     "ret deployed version",
+			status:   release.StatusDeployed,
+			workload: makeHelmWorkload("1.7.5", `{"global":{"hub":"docker.io/istio"}}`),
+			expected: "1.7.5",
+		},
+		{
+			name:     "ret different version when values differ",
+			status:   release.StatusDeployed,
+			workload: makeHelmWorkload("1.7.5", `{"global":{"hub":"gcr.io/istio"}}`),
+			expected: "1.7.5 (values differ)",
+		},
+		{
+			name:     "ret different version when the release isn't deployed",
+			status:   release.StatusFailed,
+			workload: makeHelmWorkload("1.7.5", `{"global":{"hub":"docker.io/istio"}}`),
+			expected: "1.7.5 (failed)",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			obj := makeClusterVersionResource()
+			op := newTestHelmOperator(makeHelmConfig(t, c.status))
+			version, err := op.GetWorkloadVersion(context.Background(), *obj, obj.Spec.Clusters[0], c.workload)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(version).Should(Equal(c.expected))
+		})
+	}
+}
+
+func TestHelmOperator_UpgradeWorkload(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	op := newTestHelmOperator(makeHelmConfig(t, release.StatusDeployed))
+	workload := makeHelmWorkload("1.8.1", `{"global":{"hub":"gcr.io/istio"}}`)
+
+	ctx := WithIdempotencyKey(context.Background(), "key-1")
+	result, err := op.UpgradeWorkload(ctx, *obj, obj.Spec.Clusters[0], workload)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(result).Should(Equal(&OperationResult{
+		OperationID:   "istio-system/istiod/2",
+		OperationType: OperationTypeHelmUpgrade,
+	}))
+	retried, err := op.UpgradeWorkload(ctx, *obj, obj.Spec.Clusters[0], workload)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(retried).Should(Equal(result))
+
+	obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+	obj.Status.OperationID = result.OperationID
+	obj.Status.OperationType = result.OperationType
+	g.Eventually(func() (OperationStatus, error) {
+		return op.GetOperationStatus(context.Background(), *obj)
+	}, time.Second).Should(Equal(OperationStatusDone))
+	// the completed operation is forgotten
+	g.Expect(op.started).Should(BeEmpty())
+	g.Eventually(func() int {
+		op.lock.Lock()
+		defer op.lock.Unlock()
+		return len(op.running)
+	}, time.Second).Should(BeZero())
+
+	version, err := op.GetWorkloadVersion(context.Background(), *obj, obj.Spec.Clusters[0], workload)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(version).Should(Equal("1.8.1"))
+}
+
+func TestHelmOperator_GetOperationStatusOfLostUpgrade(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+	obj.Status.OperationID = "istio-system/istiod/2"
+	obj.Status.OperationType = OperationTypeHelmUpgrade
+	op := newTestHelmOperator(makeHelmConfig(t, release.StatusDeployed))
+
+	status, err := op.GetOperationStatus(context.Background(), *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(status).Should(Equal(OperationStatusFailed))
+}
+
+func TestHelmOperator_InterruptedUpgrade(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+	obj.Status.OperationID = "istio-system/istiod/1"
+	obj.Status.OperationType = OperationTypeHelmUpgrade
+	cfg := makeHelmConfig(t, release.StatusPendingUpgrade)
+	op := newTestHelmOperator(cfg)
+
+	// the release left pending without the upgrade in this process is failed
+	detail, err := op.GetOperationDetail(context.Background(), *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(detail.Status).Should(Equal(OperationStatusFailed))
+	g.Expect(detail.Error).Should(Equal(interruptedDescription))
+
+	// the next upgrade marks it failed and goes ahead
+	result, err := op.UpgradeWorkload(context.Background(), *obj, obj.Spec.Clusters[0], makeHelmWorkload("1.8.1", ""))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(result.OperationID).Should(Equal("istio-system/istiod/2"))
+	obj.Status.OperationID = result.OperationID
+	g.Eventually(func() (OperationStatus, error) {
+		return op.GetOperationStatus(context.Background(), *obj)
+	}, time.Second).Should(Equal(OperationStatusDone))
+	rel, err := cfg.Releases.Get("istiod", 1)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(rel.Info.Status).ShouldNot(Equal(release.StatusPendingUpgrade))
+}
+
+// blockingKubeClient waits for the resources until the context is done.
+type blockingKubeClient struct {
+	kubefake.PrintingKubeClient
+	ctx context.Context
+}
+
+func (b *blockingKubeClient) Wait(_ kube.ResourceList, _ time.Duration) error {
+	<-b.ctx.Done()
+	return b.ctx.Err()
+}
+
+// newBlockingHelmOperator returns the helmOperator whose upgrades wait for the resources until they're canceled.
+func newBlockingHelmOperator(cfg *action.Configuration) *helmOperator {
+	return newHelmOperator(onlyOperator{NewPluginOperator(nil)},
+		func(ctx context.Context, _ v1.ClusterVersion, _ v1.Cluster, _ string) (*action.Configuration, error) {
+			c := *cfg
+			c.KubeClient = &blockingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}, ctx: ctx}
+			return &c, nil
+		},
+		func(workload v1.Workload) (*chart.Chart, error) {
+			return makeHelmChart(workload.Version), nil
+		},
+	)
+}
+
+func TestHelmOperator_Reset(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	op := newBlockingHelmOperator(makeHelmConfig(t, release.StatusDeployed))
+	ctx := WithIdempotencyKey(context.Background(), "key-1")
+	result, err := op.UpgradeWorkload(ctx, *obj, obj.Spec.Clusters[0], makeHelmWorkload("1.8.1", ""))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+	obj.Status.OperationID = result.OperationID
+	obj.Status.OperationType = result.OperationType
+	g.Eventually(func() (OperationStatus, error) {
+		return op.GetOperationStatus(context.Background(), *obj)
+	}, time.Second).Should(Equal(OperationStatusRunning))
+
+	// the upgrade is canceled and waited for
+	op.Reset()
+	g.Expect(op.running).Should(BeEmpty())
+	g.Expect(op.started).Should(BeEmpty())
+	status, err := op.GetOperationStatus(context.Background(), *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(status).Should(Equal(OperationStatusFailed))
+}
+
+func TestHelmOperator_Lifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	helm := newBlockingHelmOperator(makeHelmConfig(t, release.StatusDeployed))
+	l := NewLifecycle()
+	l.OnStop(helm.Reset)
+	op := l.Operator(helm).(WorkloadOperator)
+	workload := makeHelmWorkload("1.8.1", "")
+
+	// the upgrades aren't allowed before the leader is elected
+	_, err := op.UpgradeWorkload(context.Background(), *obj, obj.Spec.Clusters[0], workload)
+	g.Expect(err).Should(MatchError(ErrNotLeader))
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- l.Start(stop)
+	}()
+	var result *OperationResult
+	g.Eventually(func() (err error) {
+		result, err = op.UpgradeWorkload(context.Background(), *obj, obj.Spec.Clusters[0], workload)
+		return err
+	}).Should(Succeed())
+
+	// the upgrade in progress is canceled on stop
+	close(stop)
+	g.Eventually(done).Should(Receive(BeNil()))
+	obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+	obj.Status.OperationID = result.OperationID
+	obj.Status.OperationType = result.OperationType
+	status, err := helm.GetOperationStatus(context.Background(), *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(status).Should(Equal(OperationStatusFailed))
+}
+
+func TestHelmOperator_GetOperationDetailOfFailedUpgrade(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	obj.Status.ClusterID = obj.Spec.Clusters[0].ID
+	obj.Status.OperationID = "istio-system/istiod/1"
+	obj.Status.OperationType = OperationTypeHelmUpgrade
+	cfg := makeHelmConfig(t, release.StatusFailed)
+	rel, err := cfg.Releases.Get("istiod", 1)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	rel.Info.Description = "Upgrade \"istiod\" failed: timed out waiting for the condition"
+	g.Expect(cfg.Releases.Update(rel)).Should(Succeed())
+	op := newTestHelmOperator(cfg)
+
+	detail, err := op.GetOperationDetail(context.Background(), *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(detail.Status).Should(Equal(OperationStatusFailed))
+	g.Expect(detail.Error).Should(Equal(rel.Info.Description))
+
+	obj.Status.OperationType = "UPGRADE_MASTER"
+	_, err = op.GetOperationDetail(context.Background(), *obj)
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+}
+
+func TestHelmOperator_WorkloadWithoutHelm(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	op := newTestHelmOperator(nil)
+
+	_, err := op.GetWorkloadVersion(context.Background(), *obj, obj.Spec.Clusters[0], v1.Workload{Name: "istio"})
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+}
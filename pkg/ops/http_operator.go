@@ -0,0 +1,594 @@
+package ops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"go.opentelemetry.io/otel"
+	"io/ioutil"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"strings"
+	"time"
+)
+
+const defaultHTTPTimeout = time.Second * 30
+
+// HTTPClusterRequest is the request body of GetVersion, GetClusterStatus, ServiceIn and ServiceOut of the HTTP/JSON plugin protocol.
+type HTTPClusterRequest struct {
+	ClusterID string `json:"clusterID"`
+}
+
+// HTTPOperationStatusRequest is the request body of GetOperationStatus of the HTTP/JSON plugin protocol.
+type HTTPOperationStatusRequest struct {
+	ClusterID   string `json:"clusterID"`
+	OperationID string `json:"operationID"`
+	Type        string `json:"type"`
+}
+
+// HTTPUpgradeRequest is the request body of UpgradeMaster and UpgradeNodePool of the HTTP/JSON plugin protocol.
+// NodePoolID is empty for UpgradeMaster.
+type HTTPUpgradeRequest struct {
+	ClusterID  string `json:"clusterID"`
+	NodePoolID string `json:"nodePoolID,omitempty"`
+	Version    string `json:"version"`
+}
+
+// HTTPCapabilitiesRequest is the request body of GetCapabilities of the HTTP/JSON plugin protocol.
+// ProtocolVersions are the protocol versions which the controller speaks.
+type HTTPCapabilitiesRequest struct {
+	ClusterID        string   `json:"clusterID"`
+	ProtocolVersions []string `json:"protocolVersions"`
+}
+
+// HTTPCapabilities is the response body of GetCapabilities of the HTTP/JSON plugin protocol.
+// ProtocolVersion is the one chosen from the requested ones.
+type HTTPCapabilities struct {
+	ProtocolVersion   string   `json:"protocolVersion"`
+	UpgradeNodePool   bool     `json:"upgradeNodePool"`
+	ServiceOut        bool     `json:"serviceOut"`
+	Rollback          bool     `json:"rollback"`
+	AvailableVersions []string `json:"availableVersions,omitempty"`
+	Workloads         bool     `json:"workloads"`
+}
+
+// HTTPVersionsRequest is the request body of GetAvailableVersions of the HTTP/JSON plugin protocol.
+// An empty channel means all valid versions.
+type HTTPVersionsRequest struct {
+	ClusterID string `json:"clusterID"`
+	Channel   string `json:"channel,omitempty"`
+}
+
+// HTTPVersions is the response body of GetAvailableVersions of the HTTP/JSON plugin protocol.
+type HTTPVersions struct {
+	Versions []string `json:"versions"`
+}
+
+// HTTPWorkloadRequest is the request body of GetWorkloadVersion and UpgradeWorkload of the HTTP/JSON plugin protocol.
+// Version is empty for GetWorkloadVersion.
+type HTTPWorkloadRequest struct {
+	ClusterID string `json:"clusterID"`
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+}
+
+// HTTPWorkloadVersion is the response body of GetWorkloadVersion of the HTTP/JSON plugin protocol.
+// Version is empty if the workload isn't installed in the cluster.
+type HTTPWorkloadVersion struct {
+	Version string `json:"version"`
+}
+
+// HTTPClusterVersion is the response body of GetVersion of the HTTP/JSON plugin protocol.
+type HTTPClusterVersion struct {
+	Master struct {
+		ClusterID string `json:"clusterID"`
+		Version   string `json:"version"`
+	} `json:"master"`
+	NodePools []struct {
+		NodePoolID string `json:"nodePoolID"`
+		Version    string `json:"version"`
+	} `json:"nodePools"`
+}
+
+// HTTPClusterStatus is the response body of GetClusterStatus of the HTTP/JSON plugin protocol.
+// Status is "STATUS_SERVICE_IN" or "STATUS_SERVICE_OUT" as the gRPC protocol.
+type HTTPClusterStatus struct {
+	Status      string `json:"status"`
+	IsAvailable bool   `json:"isAvailable"`
+}
+
+// HTTPFleetRequest is the request body of GetFleetStatus of the HTTP/JSON plugin protocol.
+type HTTPFleetRequest struct {
+	ClusterIDs []string `json:"clusterIDs"`
+}
+
+// HTTPFleetStatus is the response body of GetFleetStatus of the HTTP/JSON plugin protocol.
+// It may lack some of the requested clusters.
+type HTTPFleetStatus struct {
+	Clusters []HTTPFleetClusterStatus `json:"clusters"`
+}
+
+// HTTPFleetClusterStatus is the version and the status of a cluster in the response body of GetFleetStatus.
+type HTTPFleetClusterStatus struct {
+	ClusterID string             `json:"clusterID"`
+	Version   HTTPClusterVersion `json:"version"`
+	Status    HTTPClusterStatus  `json:"status"`
+}
+
+// HTTPOperationStatus is the response body of GetOperationStatus of the HTTP/JSON plugin protocol.
+// Status is "UNKNOWN", "RUNNING", "DONE" or "FAILED" as the gRPC protocol.
+// The others are optional.
+type HTTPOperationStatus struct {
+	Status   string `json:"status"`
+	Progress *int32 `json:"progress,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HTTPOperation is the response body of the operations of the HTTP/JSON plugin protocol.
+type HTTPOperation struct {
+	OperationID string `json:"operationID"`
+	Type        string `json:"type"`
+	Message     string `json:"message,omitempty"`
+}
+
+// HTTPOperations is the response body of ListOperations of the HTTP/JSON plugin protocol.
+// It has the operations of the cluster which are running in the provider.
+type HTTPOperations struct {
+	Operations []HTTPListedOperation `json:"operations"`
+}
+
+// HTTPListedOperation is an operation in the response body of ListOperations.
+type HTTPListedOperation struct {
+	OperationID string `json:"operationID"`
+	Type        string `json:"type"`
+	// Target is the node pool ID of UPGRADE_NODE_POOL and the workload name of UPGRADE_WORKLOAD.
+	Target string `json:"target,omitempty"`
+	Status string `json:"status"`
+}
+
+// HTTPError is the response body of the HTTP/JSON plugin protocol when the status code isn't 2xx.
+type HTTPError struct {
+	Message string `json:"message"`
+}
+
+// httpStatusError is returned when the status code isn't 2xx.
+type httpStatusError struct {
+	code    int
+	message string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("status code %d: %s", e.code, e.message)
+}
+
+// isNotImplemented returns true if the plugin server doesn't have the method.
+func isNotImplemented(err error) bool {
+	var se *httpStatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.code == http.StatusNotFound || se.code == http.StatusNotImplemented
+}
+
+// httpOperator calls the HTTP/JSON plugin server of each cluster.
+// Every method is "POST <endpoint>/v1/<method>" with the JSON body, where the method is the name of the gRPC method.
+type httpOperator struct {
+	client       *http.Client
+	capabilities *capabilitiesCache
+}
+
+var _ Operator = &httpOperator{}
+var _ CapabilitiesOperator = &httpOperator{}
+var _ VersionOperator = &httpOperator{}
+var _ WorkloadOperator = &httpOperator{}
+var _ WatchOperator = &httpOperator{}
+var _ OperationDetailOperator = &httpOperator{}
+var _ OperationListOperator = &httpOperator{}
+var _ FleetStatusOperator = &httpOperator{}
+
+// NewHTTPOperator returns the Operator which calls the HTTP/JSON plugin server of each cluster.
+// The endpoint is spec.clusters[].opsEndpoint if defined, otherwise spec.opsEndpoint.
+// The default client is used if the client is nil.
+func NewHTTPOperator(client *http.Client) Operator {
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	return &httpOperator{
+		client:       client,
+		capabilities: newCapabilitiesCache(defaultCapabilitiesTTL),
+	}
+}
+
+// call posts the request to the method and decodes the response into res.
+func (h *httpOperator) call(ctx context.Context, endpoint opsv1.OpsEndpoint, method string, req, res interface{}) error {
+	httpReq, err := newHTTPRequest(ctx, endpoint, method, req)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	code, err := h.do(httpReq, res)
+	addHTTPPluginServerCall(method, code, err, start)
+	if err != nil {
+		return fmt.Errorf("failed to call %s of the plugin server: %w", method, err)
+	}
+	return nil
+}
+
+func newHTTPRequest(ctx context.Context, endpoint opsv1.OpsEndpoint, method string, req interface{}) (*http.Request, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, httpURL(endpoint, method), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/json")
+	// propagate the trace context to the plugin server through the HTTP headers
+	otel.GetTextMapPropagator().Inject(ctx, httpReq.Header)
+	if key := IdempotencyKeyFromContext(ctx); key != "" {
+		httpReq.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return httpReq, nil
+}
+
+func (h *httpOperator) do(req *http.Request, res interface{}) (int, error) {
+	httpRes, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer httpRes.Body.Close()
+	raw, err := ioutil.ReadAll(httpRes.Body)
+	if err != nil {
+		return httpRes.StatusCode, err
+	}
+	if httpRes.StatusCode < 200 || httpRes.StatusCode >= 300 {
+		return httpRes.StatusCode, newHTTPStatusError(httpRes.StatusCode, raw)
+	}
+	if err := json.Unmarshal(raw, res); err != nil {
+		return httpRes.StatusCode, fmt.Errorf("invalid response: %w", err)
+	}
+	return httpRes.StatusCode, nil
+}
+
+// newHTTPStatusError returns the error with the message of the HTTPError body, or the body itself if it isn't.
+func newHTTPStatusError(code int, body []byte) error {
+	e := HTTPError{}
+	if err := json.Unmarshal(body, &e); err != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return &httpStatusError{code: code, message: e.Message}
+}
+
+// httpURL returns the URL of the method. The scheme is https, or http if insecure, when the endpoint doesn't have it.
+func httpURL(endpoint opsv1.OpsEndpoint, method string) string {
+	base := strings.TrimSuffix(endpoint.Endpoint, "/")
+	if !strings.Contains(base, "://") {
+		scheme := "https"
+		if endpoint.Insecure {
+			scheme = "http"
+		}
+		base = fmt.Sprintf("%s://%s", scheme, base)
+	}
+	return fmt.Sprintf("%s/v1/%s", base, method)
+}
+
+// GetCapabilities calls GetCapabilities of the plugin server with the protocol versions which the controller speaks.
+// The plugin servers which don't have the method speak the protocol v1, so DefaultCapabilities is returned.
+func (h *httpOperator) GetCapabilities(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error) {
+	return h.capabilities.get(obj, cluster.ID, func() (*Capabilities, error) {
+		req := HTTPCapabilitiesRequest{
+			ClusterID:        cluster.ID,
+			ProtocolVersions: ProtocolVersions,
+		}
+		res := HTTPCapabilities{}
+		err := h.call(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsGetCapabilities, req, &res)
+		if isNotImplemented(err) {
+			return DefaultCapabilities(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := negotiateProtocol(res.ProtocolVersion); err != nil {
+			return nil, err
+		}
+		return &Capabilities{
+			ProtocolVersion:   res.ProtocolVersion,
+			UpgradeNodePool:   res.UpgradeNodePool,
+			ServiceOut:        res.ServiceOut,
+			Rollback:          res.Rollback,
+			AvailableVersions: res.AvailableVersions,
+			Workloads:         res.Workloads,
+		}, nil
+	})
+}
+
+func (h *httpOperator) GetAvailableVersions(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, channel string) ([]string, error) {
+	res := HTTPVersions{}
+	err := h.call(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsGetVersions, HTTPVersionsRequest{ClusterID: cluster.ID, Channel: channel}, &res)
+	if isNotImplemented(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Versions, nil
+}
+
+func (h *httpOperator) GetOperationStatus(ctx context.Context, obj opsv1.ClusterVersion) (OperationStatus, error) {
+	detail, err := h.GetOperationDetail(ctx, obj)
+	if err != nil {
+		return OperationStatusUnknown, err
+	}
+	return detail.Status, nil
+}
+
+// GetOperationDetail calls GetOperationStatus of the plugin server, whose response can have the progress.
+func (h *httpOperator) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	req := HTTPOperationStatusRequest{
+		ClusterID:   obj.Status.ClusterID,
+		OperationID: obj.Status.OperationID,
+		Type:        obj.Status.OperationType,
+	}
+	res := HTTPOperationStatus{}
+	if err := h.call(ctx, obj.Spec.GetOpsEndpoint(obj.Status.ClusterID), metricsGetOperationStatus, req, &res); err != nil {
+		return nil, err
+	}
+	return fromHTTPOperationStatus(res)
+}
+
+func fromHTTPOperationStatus(res HTTPOperationStatus) (*OperationDetail, error) {
+	st, ok := plugin.OperationStatusType_value[res.Status]
+	if !ok {
+		return nil, fmt.Errorf("no match status of the operation. status: %s", res.Status)
+	}
+	status, err := toOperationStatus(plugin.OperationStatusType(st))
+	if err != nil {
+		return nil, err
+	}
+	return &OperationDetail{
+		Status:   status,
+		Progress: res.Progress,
+		Message:  res.Message,
+		Error:    res.Error,
+	}, nil
+}
+
+// WatchOperation calls WatchOperation of the plugin server, which responds the Server-Sent Events whose data are
+// the responses of GetOperationStatus, first the current one and then whenever it changes.
+// The response ends when the operation completes.
+func (h *httpOperator) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	req := HTTPOperationStatusRequest{
+		ClusterID:   obj.Status.ClusterID,
+		OperationID: obj.Status.OperationID,
+		Type:        obj.Status.OperationType,
+	}
+	httpReq, err := newHTTPRequest(ctx, obj.Spec.GetOpsEndpoint(obj.Status.ClusterID), metricsWatchOperation, req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	// the response lasts until the operation completes, so it isn't limited by the timeout of the client
+	client := *h.client
+	client.Timeout = 0
+
+	start := time.Now()
+	httpRes, err := client.Do(httpReq)
+	if err != nil {
+		addHTTPPluginServerCall(metricsWatchOperation, 0, err, start)
+		return nil, fmt.Errorf("failed to call %s of the plugin server: %w", metricsWatchOperation, err)
+	}
+	if httpRes.StatusCode < 200 || httpRes.StatusCode >= 300 {
+		defer httpRes.Body.Close()
+		raw, _ := ioutil.ReadAll(httpRes.Body)
+		err := newHTTPStatusError(httpRes.StatusCode, raw)
+		addHTTPPluginServerCall(metricsWatchOperation, httpRes.StatusCode, err, start)
+		if isNotImplemented(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotSupported, err)
+		}
+		return nil, fmt.Errorf("failed to call %s of the plugin server: %w", metricsWatchOperation, err)
+	}
+	addHTTPPluginServerCall(metricsWatchOperation, httpRes.StatusCode, nil, start)
+
+	ch := make(chan OperationStatus)
+	go func() {
+		defer httpRes.Body.Close()
+		defer close(ch)
+		err := readEvents(httpRes.Body, func(data []byte) bool {
+			res := HTTPOperationStatus{}
+			if err := json.Unmarshal(data, &res); err != nil {
+				ctrl.Log.Error(err, "invalid operation status in the stream", "operation_id", req.OperationID)
+				return false
+			}
+			detail, err := fromHTTPOperationStatus(res)
+			if err != nil {
+				ctrl.Log.Error(err, "invalid operation status in the stream", "operation_id", req.OperationID)
+				return false
+			}
+			select {
+			case ch <- detail.Status:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			ctrl.Log.V(1).Info("the stream of the operation has ended", "operation_id", req.OperationID, "error", err.Error())
+		}
+	}()
+	return ch, nil
+}
+
+func (h *httpOperator) ListOperations(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error) {
+	res := HTTPOperations{}
+	if err := h.call(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsListOperations, HTTPClusterRequest{ClusterID: cluster.ID}, &res); err != nil {
+		return nil, err
+	}
+	operations := make([]Operation, len(res.Operations))
+	for i, op := range res.Operations {
+		st, ok := plugin.OperationStatusType_value[op.Status]
+		if !ok {
+			return nil, fmt.Errorf("no match status of the operation %s. status: %s", op.OperationID, op.Status)
+		}
+		status, err := toOperationStatus(plugin.OperationStatusType(st))
+		if err != nil {
+			return nil, err
+		}
+		operations[i] = Operation{
+			OperationID:   op.OperationID,
+			OperationType: op.Type,
+			Target:        op.Target,
+			Status:        status,
+		}
+	}
+	return operations, nil
+}
+
+// GetFleetStatus calls the plugin server of each endpoint once with its clusters.
+// The clusters of the plugin servers without the method are left out of the result.
+func (h *httpOperator) GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error) {
+	var ret []FleetClusterStatus
+	supported := false
+	for _, group := range groupClusters(clusters, func(c opsv1.Cluster) interface{} { return obj.Spec.GetOpsEndpoint(c.ID) }) {
+		res := HTTPFleetStatus{}
+		err := h.call(ctx, obj.Spec.GetOpsEndpoint(group[0].ID), metricsGetFleetStatus, HTTPFleetRequest{ClusterIDs: clusterIDs(group)}, &res)
+		if isNotImplemented(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		supported = true
+		for _, c := range res.Clusters {
+			cs, err := fromHTTPClusterStatus(c.Status)
+			if err != nil {
+				return nil, fmt.Errorf("cluster %s: %w", c.ClusterID, err)
+			}
+			ret = append(ret, FleetClusterStatus{
+				ClusterID: c.ClusterID,
+				Version:   *fromHTTPClusterVersion(c.Version),
+				Status:    *cs,
+			})
+		}
+	}
+	if !supported {
+		return nil, ErrNotSupported
+	}
+	return ret, nil
+}
+
+func (h *httpOperator) GetClusterVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterVersion, error) {
+	res := HTTPClusterVersion{}
+	if err := h.call(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), "GetVersion", HTTPClusterRequest{ClusterID: cluster.ID}, &res); err != nil {
+		return nil, err
+	}
+	return fromHTTPClusterVersion(res), nil
+}
+
+func fromHTTPClusterVersion(res HTTPClusterVersion) *ClusterVersion {
+	cv := &ClusterVersion{
+		Master: MasterVersion{
+			ClusterID: res.Master.ClusterID,
+			Version:   res.Master.Version,
+		},
+	}
+	cv.NodePools = make([]NodePoolVersion, len(res.NodePools))
+	for i, np := range res.NodePools {
+		cv.NodePools[i] = NodePoolVersion{
+			NodePoolID: np.NodePoolID,
+			Version:    np.Version,
+		}
+	}
+	return cv
+}
+
+func (h *httpOperator) GetClusterStatus(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterStatus, error) {
+	res := HTTPClusterStatus{}
+	if err := h.call(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsGetClusterStatus, HTTPClusterRequest{ClusterID: cluster.ID}, &res); err != nil {
+		return nil, err
+	}
+	return fromHTTPClusterStatus(res)
+}
+
+func fromHTTPClusterStatus(res HTTPClusterStatus) (*ClusterStatus, error) {
+	st, ok := plugin.ClusterStatusType_value[res.Status]
+	if !ok {
+		return &ClusterStatus{
+			Type:      ClusterStatusServiceUnknown,
+			Available: false,
+		}, fmt.Errorf("no match cluster status. status: %s", res.Status)
+	}
+	return toClusterStatus(plugin.ClusterStatusType(st), res.IsAvailable)
+}
+
+func (h *httpOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	return h.operate(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsServiceIn, HTTPClusterRequest{ClusterID: cluster.ID})
+}
+
+func (h *httpOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	return h.operate(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsServiceOut, HTTPClusterRequest{ClusterID: cluster.ID})
+}
+
+func (h *httpOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	req := HTTPUpgradeRequest{
+		ClusterID: cluster.ID,
+		Version:   cluster.Version,
+	}
+	return h.operate(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsUpgradeMaster, req)
+}
+
+func (h *httpOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+	req := HTTPUpgradeRequest{
+		ClusterID:  cluster.ID,
+		NodePoolID: nodePoolID,
+		Version:    cluster.Version,
+	}
+	return h.operate(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsUpgradeNodePool, req)
+}
+
+func (h *httpOperator) GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	req := HTTPWorkloadRequest{
+		ClusterID: cluster.ID,
+		Name:      workload.Name,
+	}
+	res := HTTPWorkloadVersion{}
+	err := h.call(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsGetWorkloadVersion, req, &res)
+	if isNotImplemented(err) {
+		return "", fmt.Errorf("%w: %s", ErrNotSupported, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.Version, nil
+}
+
+func (h *httpOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	req := HTTPWorkloadRequest{
+		ClusterID: cluster.ID,
+		Name:      workload.Name,
+		Version:   workload.Version,
+	}
+	res, err := h.operate(ctx, obj.Spec.GetOpsEndpoint(cluster.ID), metricsUpgradeWorkload, req)
+	if isNotImplemented(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotSupported, err)
+	}
+	return res, err
+}
+
+func (h *httpOperator) operate(ctx context.Context, endpoint opsv1.OpsEndpoint, method string, req interface{}) (*OperationResult, error) {
+	res := HTTPOperation{}
+	if err := h.call(ctx, endpoint, method, req, &res); err != nil {
+		return nil, err
+	}
+	return &OperationResult{
+		OperationID:   res.OperationID,
+		OperationType: res.Type,
+		Message:       res.Message,
+	}, nil
+}
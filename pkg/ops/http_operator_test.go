@@ -0,0 +1,591 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newHTTPPluginServer returns the server which responds the status code and the body to every request,
+// and records the path and the body of the last request.
+func newHTTPPluginServer(code int, body string, lastPath *string, lastBody *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lastPath = r.URL.Path
+		raw, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, lastBody)
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func makeHTTPClusterVersionResource(endpoint string) v1.ClusterVersion {
+	obj := makeClusterVersionResource()
+	obj.Spec.OpsEndpoint = v1.OpsEndpoint{
+		Protocol: v1.OpsProtocolHTTP,
+		Endpoint: endpoint,
+	}
+	obj.Status.ClusterID = "test-cluster"
+	obj.Status.OperationID = "op-1"
+	obj.Status.OperationType = "UPGRADE_MASTER"
+	return *obj
+}
+
+func TestHTTPOperator_GetClusterStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		code           int
+		body           string
+		expected       *ClusterStatus
+		expectedHasErr bool
+	}{
+		{
+			name: "ret service in",
+			code: http.StatusOK,
+			body: `{"status": "STATUS_SERVICE_IN", "isAvailable": true}`,
+			expected: &ClusterStatus{
+				Type:      ClusterStatusServiceIn,
+				Available: true,
+			},
+		},
+		{
+			name: "ret service out",
+			code: http.StatusOK,
+			body: `{"status": "STATUS_SERVICE_OUT", "isAvailable": true}`,
+			expected: &ClusterStatus{
+				Type:      ClusterStatusServiceOut,
+				Available: true,
+			},
+		},
+		{
+			name: "ret unknown",
+			code: http.StatusOK,
+			body: `{"status": "SERVICE_IN", "isAvailable": true}`,
+			expected: &ClusterStatus{
+				Type:      ClusterStatusServiceUnknown,
+				Available: false,
+			},
+			expectedHasErr: true,
+		},
+		{
+			name:           "ret error with the message",
+			code:           http.StatusNotFound,
+			body:           `{"message": "cluster isn't found"}`,
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(c.code, c.body, &path, &body)
+			defer server.Close()
+
+			st, err := NewHTTPOperator(nil).GetClusterStatus(context.Background(), makeHTTPClusterVersionResource(server.URL), v1.Cluster{ID: "test-cluster"})
+			g.Expect(path).Should(Equal("/v1/GetClusterStatus"))
+			g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster"}))
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+			} else {
+				g.Expect(err).ShouldNot(HaveOccurred())
+			}
+			if c.expected != nil {
+				g.Expect(st).Should(Equal(c.expected))
+			}
+		})
+	}
+}
+
+func TestHTTPOperator_GetOperationStatus(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		expected       OperationStatus
+		expectedHasErr bool
+	}{
+		{
+			name:     "ret done",
+			body:     `{"status": "DONE"}`,
+			expected: OperationStatusDone,
+		},
+		{
+			name:     "ret running",
+			body:     `{"status": "RUNNING"}`,
+			expected: OperationStatusRunning,
+		},
+		{
+			name:     "ret failed",
+			body:     `{"status": "FAILED"}`,
+			expected: OperationStatusFailed,
+		},
+		{
+			name:           "ret error for the illegal status",
+			body:           `{}`,
+			expected:       OperationStatusUnknown,
+			expectedHasErr: true,
+		},
+		{
+			name:           "ret error for the invalid response",
+			body:           `DONE`,
+			expected:       OperationStatusUnknown,
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(http.StatusOK, c.body, &path, &body)
+			defer server.Close()
+
+			st, err := NewHTTPOperator(nil).GetOperationStatus(context.Background(), makeHTTPClusterVersionResource(server.URL))
+			g.Expect(path).Should(Equal("/v1/GetOperationStatus"))
+			g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster", "operationID": "op-1", "type": "UPGRADE_MASTER"}))
+			g.Expect(st).Should(Equal(c.expected))
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+			} else {
+				g.Expect(err).ShouldNot(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestHTTPOperator_GetCapabilities(t *testing.T) {
+	testCases := []struct {
+		name           string
+		code           int
+		body           string
+		expected       *Capabilities
+		expectedHasErr bool
+	}{
+		{
+			name: "ret the capabilities of protocol v2",
+			code: http.StatusOK,
+			body: `{"protocolVersion": "v2", "upgradeNodePool": false, "serviceOut": true, "availableVersions": ["1.0.0"]}`,
+			expected: &Capabilities{
+				ProtocolVersion:   ProtocolVersionV2,
+				ServiceOut:        true,
+				AvailableVersions: []string{"1.0.0"},
+			},
+		},
+		{
+			name:     "ret the default for the server without the method",
+			code:     http.StatusNotFound,
+			body:     `404 page not found`,
+			expected: DefaultCapabilities(),
+		},
+		{
+			name:           "ret error for the unsupported protocol version",
+			code:           http.StatusOK,
+			body:           `{"protocolVersion": "v100"}`,
+			expectedHasErr: true,
+		},
+		{
+			name:           "ret error for the server error",
+			code:           http.StatusInternalServerError,
+			body:           `{"message": "internal error"}`,
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(c.code, c.body, &path, &body)
+			defer server.Close()
+
+			caps, err := NewHTTPOperator(nil).(CapabilitiesOperator).GetCapabilities(context.Background(), makeHTTPClusterVersionResource(server.URL), v1.Cluster{ID: "test-cluster"})
+			g.Expect(path).Should(Equal("/v1/GetCapabilities"))
+			g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster", "protocolVersions": []interface{}{"v1", "v2"}}))
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+			} else {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(caps).Should(Equal(c.expected))
+			}
+		})
+	}
+}
+
+func TestHTTPOperator_GetAvailableVersions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		code        int
+		body        string
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:     "ret the versions",
+			code:     http.StatusOK,
+			body:     `{"versions": ["1.0.1", "1.0.2"]}`,
+			expected: []string{"1.0.1", "1.0.2"},
+		},
+		{
+			name:        "ret ErrNotSupported for the server without the method",
+			code:        http.StatusNotImplemented,
+			body:        `{"message": "not implemented"}`,
+			expectedErr: ErrNotSupported,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(c.code, c.body, &path, &body)
+			defer server.Close()
+
+			versions, err := NewHTTPOperator(nil).(VersionOperator).GetAvailableVersions(context.Background(), makeHTTPClusterVersionResource(server.URL), v1.Cluster{ID: "test-cluster"}, "REGULAR")
+			g.Expect(path).Should(Equal("/v1/GetAvailableVersions"))
+			g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster", "channel": "REGULAR"}))
+			if c.expectedErr != nil {
+				g.Expect(errors.Is(err, c.expectedErr)).Should(BeTrue())
+			} else {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(versions).Should(Equal(c.expected))
+			}
+		})
+	}
+}
+
+func TestHTTPOperator_GetWorkloadVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		code        int
+		body        string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "ret the version",
+			code:     http.StatusOK,
+			body:     `{"version": "1.7.0"}`,
+			expected: "1.7.0",
+		},
+		{
+			name:        "ret ErrNotSupported for the server without the method",
+			code:        http.StatusNotFound,
+			body:        `404 page not found`,
+			expectedErr: ErrNotSupported,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(c.code, c.body, &path, &body)
+			defer server.Close()
+
+			version, err := NewHTTPOperator(nil).(WorkloadOperator).GetWorkloadVersion(context.Background(), makeHTTPClusterVersionResource(server.URL), v1.Cluster{ID: "test-cluster"}, v1.Workload{Name: "istio", Version: "1.8.0"})
+			g.Expect(path).Should(Equal("/v1/GetWorkloadVersion"))
+			g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster", "name": "istio"}))
+			if c.expectedErr != nil {
+				g.Expect(errors.Is(err, c.expectedErr)).Should(BeTrue())
+			} else {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(version).Should(Equal(c.expected))
+			}
+		})
+	}
+}
+
+// TestHTTPOperator_WatchOperation streams the status through a real response of Server-Sent Events.
+func TestHTTPOperator_WatchOperation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	proceed := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/WatchOperation" || r.Header.Get("Accept") != "text/event-stream" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(": keep-alive\n\ndata: {\"status\": \"RUNNING\", \"progress\": 10}\n\n"))
+		w.(http.Flusher).Flush()
+		<-proceed
+		_, _ = w.Write([]byte("event: status\ndata: {\"status\": \"DONE\"}\n\n"))
+	}))
+	defer server.Close()
+
+	ch, err := NewHTTPOperator(nil).(WatchOperator).WatchOperation(context.Background(), makeHTTPClusterVersionResource(server.URL))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Eventually(ch).Should(Receive(Equal(OperationStatusRunning)))
+	g.Consistently(ch, "100ms").ShouldNot(Receive())
+	close(proceed)
+	g.Eventually(ch).Should(Receive(Equal(OperationStatusDone)))
+	g.Eventually(ch).Should(BeClosed())
+
+	path := ""
+	body := map[string]interface{}{}
+	v1Server := newHTTPPluginServer(http.StatusNotImplemented, `{"message": "not implemented"}`, &path, &body)
+	defer v1Server.Close()
+	_, err = NewHTTPOperator(nil).(WatchOperator).WatchOperation(context.Background(), makeHTTPClusterVersionResource(v1Server.URL))
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+	g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster", "operationID": "op-1", "type": "UPGRADE_MASTER"}))
+}
+
+func TestHTTPOperator_GetOperationDetail(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := ""
+	body := map[string]interface{}{}
+	server := newHTTPPluginServer(http.StatusOK, `{"status": "FAILED", "progress": 40, "message": "upgrading nodes", "error": "quota exceeded"}`, &path, &body)
+	defer server.Close()
+
+	detail, err := NewHTTPOperator(nil).(OperationDetailOperator).GetOperationDetail(context.Background(), makeHTTPClusterVersionResource(server.URL))
+	g.Expect(err).ShouldNot(HaveOccurred())
+	progress := int32(40)
+	g.Expect(detail).Should(Equal(&OperationDetail{
+		Status:   OperationStatusFailed,
+		Progress: &progress,
+		Message:  "upgrading nodes",
+		Error:    "quota exceeded",
+	}))
+}
+
+func TestHTTPOperator_ListOperations(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		expected       []Operation
+		expectedHasErr bool
+	}{
+		{
+			name: "ret the operations",
+			body: `{"operations": [{"operationID": "op-1", "type": "UPGRADE_MASTER", "status": "RUNNING"}, {"operationID": "op-2", "type": "SERVICE_IN", "status": "DONE"}, {"operationID": "op-3", "type": "UPGRADE_NODE_POOL", "target": "pool-1", "status": "RUNNING"}]}`,
+			expected: []Operation{
+				{OperationID: "op-1", OperationType: "UPGRADE_MASTER", Status: OperationStatusRunning},
+				{OperationID: "op-2", OperationType: "SERVICE_IN", Status: OperationStatusDone},
+				{OperationID: "op-3", OperationType: "UPGRADE_NODE_POOL", Target: "pool-1", Status: OperationStatusRunning},
+			},
+		},
+		{
+			name:     "ret empty for no operations",
+			body:     `{}`,
+			expected: []Operation{},
+		},
+		{
+			name:           "ret error for the illegal status",
+			body:           `{"operations": [{"operationID": "op-1", "type": "UPGRADE_MASTER", "status": "DONE!"}]}`,
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(http.StatusOK, c.body, &path, &body)
+			defer server.Close()
+
+			operations, err := NewHTTPOperator(nil).(OperationListOperator).ListOperations(context.Background(), makeHTTPClusterVersionResource(server.URL), v1.Cluster{ID: "test-cluster"})
+			g.Expect(path).Should(Equal("/v1/ListOperations"))
+			g.Expect(body).Should(Equal(map[string]interface{}{"clusterID": "test-cluster"}))
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+			} else {
+				g.Expect(err).ShouldNot(HaveOccurred())
+				g.Expect(operations).Should(Equal(c.expected))
+			}
+		})
+	}
+}
+
+func TestHTTPOperator_GetFleetStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := ""
+	body := map[string]interface{}{}
+	server := newHTTPPluginServer(http.StatusOK, `{"clusters": [
+  {"clusterID": "cluster-1", "version": {"master": {"clusterID": "cluster-1", "version": "1.0.0"}, "nodePools": [{"nodePoolID": "np-1", "version": "0.9.0"}]}, "status": {"status": "STATUS_SERVICE_IN", "isAvailable": true}},
+  {"clusterID": "cluster-2", "version": {"master": {"clusterID": "cluster-2", "version": "1.0.0"}, "nodePools": []}, "status": {"status": "STATUS_SERVICE_OUT", "isAvailable": false}}
+]}`, &path, &body)
+	defer server.Close()
+	otherPath := ""
+	otherBody := map[string]interface{}{}
+	other := newHTTPPluginServer(http.StatusOK, `{"clusters": []}`, &otherPath, &otherBody)
+	defer other.Close()
+
+	obj := makeHTTPClusterVersionResource(server.URL)
+	obj.Spec.Clusters = []v1.Cluster{
+		{ID: "cluster-1"},
+		{ID: "cluster-2"},
+		{ID: "cluster-3", OpsEndpoint: &v1.OpsEndpoint{Protocol: v1.OpsProtocolHTTP, Endpoint: other.URL}},
+	}
+	statuses, err := NewHTTPOperator(nil).(FleetStatusOperator).GetFleetStatus(context.Background(), obj, obj.Spec.Clusters)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(path).Should(Equal("/v1/GetFleetStatus"))
+	g.Expect(body).Should(Equal(map[string]interface{}{"clusterIDs": []interface{}{"cluster-1", "cluster-2"}}))
+	g.Expect(otherBody).Should(Equal(map[string]interface{}{"clusterIDs": []interface{}{"cluster-3"}}))
+	g.Expect(statuses).Should(Equal([]FleetClusterStatus{
+		{
+			ClusterID: "cluster-1",
+			Version: ClusterVersion{
+				Master:    MasterVersion{ClusterID: "cluster-1", Version: "1.0.0"},
+				NodePools: []NodePoolVersion{{NodePoolID: "np-1", Version: "0.9.0"}},
+			},
+			Status: ClusterStatus{Type: ClusterStatusServiceIn, Available: true},
+		},
+		{
+			ClusterID: "cluster-2",
+			Version: ClusterVersion{
+				Master:    MasterVersion{ClusterID: "cluster-2", Version: "1.0.0"},
+				NodePools: []NodePoolVersion{},
+			},
+			Status: ClusterStatus{Type: ClusterStatusServiceOut, Available: false},
+		},
+	}))
+}
+
+func TestHTTPOperator_GetFleetStatus_NotImplemented(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := ""
+	body := map[string]interface{}{}
+	server := newHTTPPluginServer(http.StatusNotFound, `not found`, &path, &body)
+	defer server.Close()
+	otherPath := ""
+	otherBody := map[string]interface{}{}
+	other := newHTTPPluginServer(http.StatusOK, `{"clusters": [
+  {"clusterID": "cluster-2", "version": {"master": {"clusterID": "cluster-2", "version": "1.0.0"}, "nodePools": []}, "status": {"status": "STATUS_SERVICE_IN", "isAvailable": true}}
+]}`, &otherPath, &otherBody)
+	defer other.Close()
+
+	obj := makeHTTPClusterVersionResource(server.URL)
+	obj.Spec.Clusters = []v1.Cluster{
+		{ID: "cluster-1"},
+		{ID: "cluster-2", OpsEndpoint: &v1.OpsEndpoint{Protocol: v1.OpsProtocolHTTP, Endpoint: other.URL}},
+	}
+	operator := NewHTTPOperator(nil).(FleetStatusOperator)
+
+	// the clusters of the plugin server without the method are left out
+	statuses, err := operator.GetFleetStatus(context.Background(), obj, obj.Spec.Clusters)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(statuses).Should(HaveLen(1))
+	g.Expect(statuses[0].ClusterID).Should(Equal("cluster-2"))
+
+	_, err = operator.GetFleetStatus(context.Background(), obj, obj.Spec.Clusters[:1])
+	g.Expect(err).Should(MatchError(ErrNotSupported))
+}
+
+func TestHTTPOperator_GetClusterVersion(t *testing.T) {
+	g := NewGomegaWithT(t)
+	path := ""
+	body := map[string]interface{}{}
+	server := newHTTPPluginServer(http.StatusOK, `{
+  "master": {"clusterID": "test-cluster", "version": "1.0.0"},
+  "nodePools": [{"nodePoolID": "np-1", "version": "0.9.0"}]
+}`, &path, &body)
+	defer server.Close()
+
+	cv, err := NewHTTPOperator(nil).GetClusterVersion(context.Background(), makeHTTPClusterVersionResource(server.URL), v1.Cluster{ID: "test-cluster"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(path).Should(Equal("/v1/GetVersion"))
+	g.Expect(cv).Should(Equal(&ClusterVersion{
+		Master:    MasterVersion{ClusterID: "test-cluster", Version: "1.0.0"},
+		NodePools: []NodePoolVersion{{NodePoolID: "np-1", Version: "0.9.0"}},
+	}))
+}
+
+func TestHTTPOperator_Operations(t *testing.T) {
+	cluster := v1.Cluster{ID: "test-cluster", Version: "1.1.0"}
+	testCases := []struct {
+		name         string
+		operate      func(o Operator, obj v1.ClusterVersion) (*OperationResult, error)
+		expectedPath string
+		expectedBody map[string]interface{}
+	}{
+		{
+			name: "service in",
+			operate: func(o Operator, obj v1.ClusterVersion) (*OperationResult, error) {
+				return o.ServiceIn(context.Background(), obj, cluster)
+			},
+			expectedPath: "/v1/ServiceIn",
+			expectedBody: map[string]interface{}{"clusterID": "test-cluster"},
+		},
+		{
+			name: "service out",
+			operate: func(o Operator, obj v1.ClusterVersion) (*OperationResult, error) {
+				return o.ServiceOut(context.Background(), obj, cluster)
+			},
+			expectedPath: "/v1/ServiceOut",
+			expectedBody: map[string]interface{}{"clusterID": "test-cluster"},
+		},
+		{
+			name: "upgrade master",
+			operate: func(o Operator, obj v1.ClusterVersion) (*OperationResult, error) {
+				return o.UpgradeMaster(context.Background(), obj, cluster)
+			},
+			expectedPath: "/v1/UpgradeMaster",
+			expectedBody: map[string]interface{}{"clusterID": "test-cluster", "version": "1.1.0"},
+		},
+		{
+			name: "upgrade node pool",
+			operate: func(o Operator, obj v1.ClusterVersion) (*OperationResult, error) {
+				return o.UpgradeNodePool(context.Background(), obj, cluster, "np-1")
+			},
+			expectedPath: "/v1/UpgradeNodePool",
+			expectedBody: map[string]interface{}{"clusterID": "test-cluster", "nodePoolID": "np-1", "version": "1.1.0"},
+		},
+		{
+			name: "upgrade workload",
+			operate: func(o Operator, obj v1.ClusterVersion) (*OperationResult, error) {
+				return o.(WorkloadOperator).UpgradeWorkload(context.Background(), obj, cluster, v1.Workload{Name: "istio", Version: "1.8.0"})
+			},
+			expectedPath: "/v1/UpgradeWorkload",
+			expectedBody: map[string]interface{}{"clusterID": "test-cluster", "name": "istio", "version": "1.8.0"},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			path := ""
+			body := map[string]interface{}{}
+			server := newHTTPPluginServer(http.StatusOK, `{"operationID": "op-1", "type": "TEST"}`, &path, &body)
+			defer server.Close()
+
+			res, err := c.operate(NewHTTPOperator(nil), makeHTTPClusterVersionResource(server.URL))
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(path).Should(Equal(c.expectedPath))
+			g.Expect(body).Should(Equal(c.expectedBody))
+			g.Expect(res).Should(Equal(&OperationResult{OperationID: "op-1", OperationType: "TEST"}))
+		})
+	}
+}
+
+func TestHTTPURL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		endpoint v1.OpsEndpoint
+		expected string
+	}{
+		{
+			name:     "ret the url of the endpoint",
+			endpoint: v1.OpsEndpoint{Endpoint: "http://ops.example.com/plugin/"},
+			expected: "http://ops.example.com/plugin/v1/ServiceIn",
+		},
+		{
+			name:     "ret https url for the endpoint without scheme",
+			endpoint: v1.OpsEndpoint{Endpoint: "ops.example.com"},
+			expected: "https://ops.example.com/v1/ServiceIn",
+		},
+		{
+			name:     "ret http url for the insecure endpoint without scheme",
+			endpoint: v1.OpsEndpoint{Endpoint: "ops.example.com:8080", Insecure: true},
+			expected: "http://ops.example.com:8080/v1/ServiceIn",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			g.Expect(httpURL(c.endpoint, "ServiceIn")).Should(Equal(c.expected))
+		})
+	}
+}
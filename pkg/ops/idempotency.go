@@ -0,0 +1,49 @@
+package ops
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// IdempotencyKeyMetadata is the gRPC metadata key of the idempotency key of the mutating calls.
+	IdempotencyKeyMetadata = "idempotency-key"
+	// IdempotencyKeyHeader is the HTTP header of the idempotency key of the mutating calls.
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+type idempotencyKeyContextKey struct{}
+
+// IdempotencyKey returns the key of the step of the cluster, which is derived from the UID, the generation and the number
+// of started operations of the ClusterVersion. The step is e.g. "UPGRADE_NODE_POOL/<node pool ID>".
+// The key changes when the spec is edited, so the plugin server doesn't return the operation requested for the old spec.
+func IdempotencyKey(obj opsv1.ClusterVersion, clusterID string, step string) string {
+	src := fmt.Sprintf("%s/%d/%d/%s/%s", obj.UID, obj.Generation, obj.Status.OperationCount, clusterID, step)
+	sum := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(sum[:16])
+}
+
+// WithIdempotencyKey returns the context which makes the Operators send the key with the mutating calls.
+// The plugin server should return the existing operation for the request with the key it has already accepted.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the key set by WithIdempotencyKey, or empty if it isn't set.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key
+}
+
+// outgoingContext puts the idempotency key in the gRPC metadata of the request.
+func outgoingContext(ctx context.Context) context.Context {
+	key := IdempotencyKeyFromContext(ctx)
+	if key == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, IdempotencyKeyMetadata, key)
+}
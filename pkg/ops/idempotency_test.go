@@ -0,0 +1,99 @@
+package ops
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	obj := makeClusterVersionResource()
+	obj.UID = "uid-1"
+	obj.Generation = 1
+	key := IdempotencyKey(*obj, "test-cluster", "UPGRADE_MASTER")
+
+	testCases := []struct {
+		name          string
+		mutate        func(obj *v1.ClusterVersion) (clusterID string, step string)
+		expectedEqual bool
+	}{
+		{
+			name: "ret the same key for the same step",
+			mutate: func(obj *v1.ClusterVersion) (string, string) {
+				return "test-cluster", "UPGRADE_MASTER"
+			},
+			expectedEqual: true,
+		},
+		{
+			name: "ret another key for another step",
+			mutate: func(obj *v1.ClusterVersion) (string, string) {
+				return "test-cluster", "UPGRADE_NODE_POOL/np-1"
+			},
+		},
+		{
+			name: "ret another key for another cluster",
+			mutate: func(obj *v1.ClusterVersion) (string, string) {
+				return "test-cluster-2", "UPGRADE_MASTER"
+			},
+		},
+		{
+			name: "ret another key after the spec has changed",
+			mutate: func(obj *v1.ClusterVersion) (string, string) {
+				obj.Generation = 2
+				obj.Spec.Clusters[0].Version = "1.17"
+				return "test-cluster", "UPGRADE_MASTER"
+			},
+		},
+		{
+			name: "ret another key after another operation has started",
+			mutate: func(obj *v1.ClusterVersion) (string, string) {
+				obj.Status.OperationCount = 1
+				return "test-cluster", "UPGRADE_MASTER"
+			},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			o := obj.DeepCopy()
+			clusterID, step := c.mutate(o)
+			g.Expect(IdempotencyKey(*o, clusterID, step) == key).Should(Equal(c.expectedEqual))
+		})
+	}
+}
+
+func TestInProcessOperator_IdempotencyKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+	op := newFakeFleetOperator("test-cluster", "1.0.0")
+	obj := makeClusterVersionResource()
+	cluster := obj.Spec.Clusters[0]
+
+	ctx := WithIdempotencyKey(context.Background(), "key-1")
+	first, err := op.UpgradeMaster(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	retried, err := op.UpgradeMaster(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(retried).Should(Equal(first))
+
+	another, err := op.UpgradeMaster(WithIdempotencyKey(context.Background(), "key-2"), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(another.OperationID).ShouldNot(Equal(first.OperationID))
+}
+
+func TestHTTPOperator_IdempotencyKey(t *testing.T) {
+	g := NewGomegaWithT(t)
+	header := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(IdempotencyKeyHeader)
+		_, _ = w.Write([]byte(`{"operationID": "op-1", "type": "UPGRADE_MASTER"}`))
+	}))
+	defer server.Close()
+	obj := makeHTTPClusterVersionResource(server.URL)
+
+	_, err := NewHTTPOperator(nil).UpgradeMaster(WithIdempotencyKey(context.Background(), "key-1"), obj, obj.Spec.Clusters[0])
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(header).Should(Equal("key-1"))
+}
@@ -0,0 +1,45 @@
+package ops
+
+import (
+	"context"
+	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const inProcessBufferSize = 1024 * 1024
+
+// NewInProcessOperator returns the Operator which calls the plugin server implemented in Go in the same process,
+// so the plugin server can be registered as a provider without running it as a sidecar.
+// The calls go through an in-memory gRPC connection, so the server sees the metadata and the streams as over the network.
+// The ClusterExtension service is served too if the server implements it.
+func NewInProcessOperator(server plugin.ClusterServer) Operator {
+	lis := bufconn.Listen(inProcessBufferSize)
+	s := grpc.NewServer()
+	plugin.RegisterClusterServer(s, server)
+	if ext, ok := server.(pluginext.ClusterExtensionServer); ok {
+		pluginext.RegisterClusterExtensionServer(s, ext)
+	}
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			ctrl.Log.Error(err, "in-process plugin server stopped")
+		}
+	}()
+	return NewPluginOperator(func(_ opsv1.OpsEndpoint) (PluginClient, func(), error) {
+		conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewPluginClient(conn), func() {
+			if err := conn.Close(); err != nil {
+				ctrl.Log.Error(err, "failed to close connection")
+			}
+		}, nil
+	})
+}
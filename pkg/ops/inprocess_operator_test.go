@@ -0,0 +1,39 @@
+package ops
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"testing"
+)
+
+func TestInProcessOperator(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	operator := NewInProcessOperator(fakeplugin.NewServer(fakeplugin.Config{
+		Clusters: []fakeplugin.ClusterConfig{
+			{ID: "test-cluster", Version: "1.0.0", NodePools: []string{"pool-1"}, AvailableVersions: []string{"1.0.1"}},
+		},
+	}))
+	obj := makeClusterVersionResource()
+
+	// the ClusterExtension service of the server is served too
+	caps, err := operator.(CapabilitiesOperator).GetCapabilities(ctx, *obj, obj.Spec.Clusters[0])
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(caps).Should(Equal(&Capabilities{
+		ProtocolVersion:   ProtocolVersionV2,
+		UpgradeNodePool:   true,
+		ServiceOut:        true,
+		AvailableVersions: []string{"1.0.1"},
+		Workloads:         true,
+	}))
+
+	// the idempotency key reaches the server as the gRPC metadata
+	ctx = WithIdempotencyKey(ctx, "key-1")
+	first, err := operator.UpgradeMaster(ctx, *obj, v1.Cluster{ID: "test-cluster", Version: "1.0.1"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	second, err := operator.UpgradeMaster(ctx, *obj, v1.Cluster{ID: "test-cluster", Version: "1.0.1"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(second.OperationID).Should(Equal(first.OperationID))
+}
@@ -0,0 +1,158 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sync"
+)
+
+// ErrNotLeader is returned for the mutating calls while the controller isn't the leader or is stopping.
+var ErrNotLeader = errors.New("the controller isn't the leader")
+
+// Resetter is implemented by the Operators which run work in memory, e.g. the Helm upgrades,
+// to be torn down when the controller stops.
+type Resetter interface {
+	Reset()
+}
+
+// Lifecycle runs as a manager.Runnable with the leadership of the controller. The mutating calls of the Operator
+// returned by Operator are allowed only while it runs. When the controller stops or loses the leadership,
+// it refuses new mutating calls, waits for the ones in flight, and then calls the functions registered by OnStop
+// to tear down the state in memory, e.g. the connections and the streams.
+// It runs only once, because the manager exits the process when the leadership is lost, and nothing is rebuilt.
+type Lifecycle struct {
+	active   bool
+	stopped  bool
+	inflight sync.WaitGroup
+	onStart  []func()
+	onStop   []func()
+	lock     sync.RWMutex
+}
+
+var _ manager.Runnable = &Lifecycle{}
+var _ manager.LeaderElectionRunnable = &Lifecycle{}
+
+// NewLifecycle returns the Lifecycle which isn't started yet.
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// OnStart registers the function called when the controller has become the leader.
+func (l *Lifecycle) OnStart(f func()) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.onStart = append(l.onStart, f)
+}
+
+// OnStop registers the function called after the mutating calls in flight have completed on stop.
+func (l *Lifecycle) OnStop(f func()) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.onStop = append(l.onStop, f)
+}
+
+// NeedLeaderElection makes the manager start the Lifecycle only on the leader.
+func (l *Lifecycle) NeedLeaderElection() bool {
+	return true
+}
+
+// Start allows the mutating calls until stop is closed, and drains them before returning.
+// It returns an error if the Lifecycle has already stopped.
+func (l *Lifecycle) Start(stop <-chan struct{}) error {
+	l.lock.Lock()
+	if l.stopped {
+		l.lock.Unlock()
+		return errors.New("the lifecycle has already stopped")
+	}
+	l.active = true
+	onStart := append([]func(){}, l.onStart...)
+	l.lock.Unlock()
+	for _, f := range onStart {
+		f()
+	}
+
+	<-stop
+
+	l.lock.Lock()
+	l.active = false
+	l.stopped = true
+	onStop := append([]func(){}, l.onStop...)
+	l.lock.Unlock()
+	l.inflight.Wait()
+	for _, f := range onStop {
+		f()
+	}
+	return nil
+}
+
+// acquire returns false if the mutating call isn't allowed. Otherwise release must be called after the call.
+func (l *Lifecycle) acquire() bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if !l.active {
+		return false
+	}
+	l.inflight.Add(1)
+	return true
+}
+
+func (l *Lifecycle) release() {
+	l.inflight.Done()
+}
+
+// Operator returns the Operator whose mutating calls return ErrNotLeader while the Lifecycle isn't running.
+func (l *Lifecycle) Operator(operator Operator) Operator {
+	return &lifecycleOperator{
+		wrapped:   wrapped{operator},
+		lifecycle: l,
+	}
+}
+
+// lifecycleOperator gates the mutating calls of the wrapped Operator by the Lifecycle.
+type lifecycleOperator struct {
+	wrapped
+	lifecycle *Lifecycle
+}
+
+var _ extendedOperator = &lifecycleOperator{}
+
+// operate performs the mutating call if the Lifecycle is running.
+func (o *lifecycleOperator) operate(call func() (*OperationResult, error)) (*OperationResult, error) {
+	if !o.lifecycle.acquire() {
+		return nil, ErrNotLeader
+	}
+	defer o.lifecycle.release()
+	return call()
+}
+
+func (o *lifecycleOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	return o.operate(func() (*OperationResult, error) {
+		return o.Operator.ServiceIn(ctx, obj, cluster)
+	})
+}
+
+func (o *lifecycleOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	return o.operate(func() (*OperationResult, error) {
+		return o.Operator.ServiceOut(ctx, obj, cluster)
+	})
+}
+
+func (o *lifecycleOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	return o.operate(func() (*OperationResult, error) {
+		return o.Operator.UpgradeMaster(ctx, obj, cluster)
+	})
+}
+
+func (o *lifecycleOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+	return o.operate(func() (*OperationResult, error) {
+		return o.Operator.UpgradeNodePool(ctx, obj, cluster, nodePoolID)
+	})
+}
+
+func (o *lifecycleOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	return o.operate(func() (*OperationResult, error) {
+		return o.wrapped.UpgradeWorkload(ctx, obj, cluster, workload)
+	})
+}
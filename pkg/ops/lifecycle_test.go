@@ -0,0 +1,81 @@
+package ops
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"testing"
+	"time"
+)
+
+// blockingOperator blocks ServiceIn until release is closed.
+type blockingOperator struct {
+	Operator
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingOperator) ServiceIn(ctx context.Context, obj v1.ClusterVersion, cluster v1.Cluster) (*OperationResult, error) {
+	close(b.started)
+	<-b.release
+	return b.Operator.ServiceIn(ctx, obj, cluster)
+}
+
+func TestLifecycle(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	cluster := v1.Cluster{ID: "test-cluster", Version: "1.16.13-gke.404"}
+	blocking := &blockingOperator{
+		Operator: newFakeFleetOperator(cluster.ID, cluster.Version),
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	l := NewLifecycle()
+	op := l.Operator(blocking)
+	started, stopped := make(chan struct{}, 1), make(chan struct{}, 1)
+	l.OnStart(func() { started <- struct{}{} })
+	l.OnStop(func() { stopped <- struct{}{} })
+
+	// the mutating calls aren't allowed before the leader is elected
+	_, err := op.ServiceOut(context.Background(), *obj, cluster)
+	g.Expect(err).Should(MatchError(ErrNotLeader))
+	_, err = op.GetClusterStatus(context.Background(), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- l.Start(stop)
+	}()
+	g.Eventually(func() error {
+		_, err := op.ServiceOut(context.Background(), *obj, cluster)
+		return err
+	}).Should(Succeed())
+	g.Expect(started).Should(Receive())
+
+	inflight := make(chan error)
+	go func() {
+		_, err := op.ServiceIn(context.Background(), *obj, cluster)
+		inflight <- err
+	}()
+	<-blocking.started
+	close(stop)
+
+	// no mutating call is issued after stop, and the one in flight is drained
+	g.Eventually(func() error {
+		_, err := op.UpgradeMaster(context.Background(), *obj, cluster)
+		return err
+	}).Should(MatchError(ErrNotLeader))
+	g.Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+	g.Expect(stopped).ShouldNot(Receive())
+	close(blocking.release)
+	g.Expect(<-inflight).ShouldNot(HaveOccurred())
+	g.Eventually(done).Should(Receive(BeNil()))
+	g.Expect(stopped).Should(Receive())
+
+	// it isn't started again, because the process exits when the leadership is lost
+	g.Expect(l.Start(make(chan struct{}))).Should(HaveOccurred())
+	_, err = op.ServiceOut(context.Background(), *obj, cluster)
+	g.Expect(err).Should(MatchError(ErrNotLeader))
+	g.Expect(started).ShouldNot(Receive())
+}
@@ -0,0 +1,35 @@
+package ops
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	cacheHit = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "multicluster_clusterversion_operator_cache_hit_total",
+			Help: "Number of operator calls served from the cache",
+		},
+		[]string{"request_type"},
+	)
+	cacheMiss = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "multicluster_clusterversion_operator_cache_miss_total",
+			Help: "Number of operator calls not served from the cache",
+		},
+		[]string{"request_type"},
+	)
+)
+
+func addCacheHit(request string) {
+	cacheHit.With(prometheus.Labels{"request_type": request}).Inc()
+}
+
+func addCacheMiss(request string) {
+	cacheMiss.With(prometheus.Labels{"request_type": request}).Inc()
+}
+
+func init() {
+	metrics.Registry.MustRegister(cacheHit, cacheMiss)
+}
@@ -0,0 +1,40 @@
+package ops
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	httpPluginServerCall = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "multicluster_clusterversion_http_plugin_call_total",
+			Help: "Number of call for HTTP plugin server",
+		},
+		[]string{"request_type", "code"},
+	)
+	httpPluginServerCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "multicluster_clusterversion_http_plugin_call_duration_seconds",
+			Help:    "Latency of call for HTTP plugin server",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"request_type", "result"},
+	)
+)
+
+// addHTTPPluginServerCall records the call. The code is 0 if the server didn't respond.
+func addHTTPPluginServerCall(request string, code int, err error, start time.Time) {
+	result := "success"
+	if err != nil {
+		result = "failed"
+	}
+	httpPluginServerCall.With(prometheus.Labels{"request_type": request, "code": strconv.Itoa(code)}).Inc()
+	httpPluginServerCallDuration.With(prometheus.Labels{"request_type": request, "result": result}).Observe(time.Since(start).Seconds())
+}
+
+func init() {
+	metrics.Registry.MustRegister(httpPluginServerCall, httpPluginServerCallDuration)
+}
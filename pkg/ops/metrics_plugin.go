@@ -3,6 +3,7 @@ package ops
 import (
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
 )
 
 var (
@@ -21,16 +22,26 @@ var (
 		},
 		defaultLabels,
 	)
+	pluginServerCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "multicluster_clusterversion_plugin_call_duration_seconds",
+			Help:    "Latency of call for plugin server",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"request_type", "result"},
+	)
 )
 
-func addSuccessPluginServerCall(request string) {
+func addSuccessPluginServerCall(request string, start time.Time) {
 	successPluginServerCall.With(prometheus.Labels{"request_type": request}).Inc()
+	pluginServerCallDuration.With(prometheus.Labels{"request_type": request, "result": "success"}).Observe(time.Since(start).Seconds())
 }
 
-func addFailedPluginServerCall(request string) {
+func addFailedPluginServerCall(request string, start time.Time) {
 	failedPluginServerCall.With(prometheus.Labels{"request_type": request}).Inc()
+	pluginServerCallDuration.With(prometheus.Labels{"request_type": request, "result": "failed"}).Observe(time.Since(start).Seconds())
 }
 
 func init() {
-	metrics.Registry.MustRegister(successPluginServerCall, failedPluginServerCall)
+	metrics.Registry.MustRegister(successPluginServerCall, failedPluginServerCall, pluginServerCallDuration)
 }
@@ -0,0 +1,26 @@
+package ops
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+var (
+	rateLimitWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "multicluster_clusterversion_operator_rate_limit_wait_seconds",
+			Help:    "Time the mutating operator calls waited for the rate limiter",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"request_type"},
+	)
+)
+
+func observeRateLimitWait(request string, start time.Time) {
+	rateLimitWaitDuration.With(prometheus.Labels{"request_type": request}).Observe(time.Since(start).Seconds())
+}
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitWaitDuration)
+}
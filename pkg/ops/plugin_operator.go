@@ -2,19 +2,53 @@ package ops
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
 	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
 )
 
-type newConnFunc func(obj opsv1.ClusterVersion) (c plugin.ClusterClient, closer func(), err error)
+type newConnFunc func(endpoint opsv1.OpsEndpoint) (c PluginClient, closer func(), err error)
+
+// PluginClient is the client of the Cluster service and the optional ClusterExtension service of the plugin server.
+type PluginClient interface {
+	plugin.ClusterClient
+	pluginext.ClusterExtensionClient
+}
+
+type pluginClient struct {
+	plugin.ClusterClient
+	pluginext.ClusterExtensionClient
+}
+
+// NewPluginClient returns the PluginClient which calls both services through the connection.
+func NewPluginClient(conn grpc.ClientConnInterface) PluginClient {
+	return &pluginClient{
+		ClusterClient:          plugin.NewClusterClient(conn),
+		ClusterExtensionClient: pluginext.NewClusterExtensionClient(conn),
+	}
+}
 
 type pluginOperator struct {
-	newFunc newConnFunc
+	newFunc      newConnFunc
+	capabilities *capabilitiesCache
 }
 
+var _ CapabilitiesOperator = &pluginOperator{}
+var _ VersionOperator = &pluginOperator{}
+var _ WorkloadOperator = &pluginOperator{}
+var _ WatchOperator = &pluginOperator{}
+var _ OperationDetailOperator = &pluginOperator{}
+var _ OperationListOperator = &pluginOperator{}
+var _ FleetStatusOperator = &pluginOperator{}
+
 const (
 	metricsGetClusterStatus   = "GetClusterStatus"
 	metricsGetOperationStatus = "GetOperationStatus"
@@ -23,19 +57,23 @@ const (
 	metricsServiceOut         = "ServiceOut"
 	metricsUpgradeMaster      = "UpgradeMaster"
 	metricsUpgradeNodePool    = "UpgradeNodePool"
+	metricsGetWorkloadVersion = "GetWorkloadVersion"
+	metricsUpgradeWorkload    = "UpgradeWorkload"
+	metricsGetCapabilities    = "GetCapabilities"
+	metricsGetVersions        = "GetAvailableVersions"
+	metricsWatchOperation     = "WatchOperation"
+	metricsGetOperationDetail = "GetOperationDetail"
+	metricsListOperations     = "ListOperations"
+	metricsGetFleetStatus     = "GetFleetStatus"
 )
 
 var (
-	DefaultNewFunc = func(obj opsv1.ClusterVersion) (c plugin.ClusterClient, closer func(), err error) {
-		var opts []grpc.DialOption
-		if obj.Spec.OpsEndpoint.Insecure {
-			opts = append(opts, grpc.WithInsecure())
-		}
-		conn, err := grpc.Dial(obj.Spec.OpsEndpoint.Endpoint, opts...)
+	DefaultNewFunc = func(endpoint opsv1.OpsEndpoint) (c PluginClient, closer func(), err error) {
+		conn, err := dialPlugin(endpoint)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to dial grpc. err: %#v", err)
+			return nil, nil, err
 		}
-		return plugin.NewClusterClient(conn), func() {
+		return NewPluginClient(conn), func() {
 			if err := conn.Close(); err != nil {
 				ctrl.Log.Error(err, "failed to close connection")
 			}
@@ -43,14 +81,71 @@ var (
 	}
 )
 
+func dialPlugin(endpoint opsv1.OpsEndpoint) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		// propagate the trace context to the plugin server through gRPC metadata
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+	}
+	if endpoint.Insecure {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	conn, err := grpc.Dial(endpoint.Endpoint, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial grpc. err: %#v", err)
+	}
+	return conn, nil
+}
+
+// NewPluginOperator returns the Operator which calls the plugin server of each cluster.
+// The endpoint is spec.clusters[].opsEndpoint if defined, otherwise spec.opsEndpoint.
 func NewPluginOperator(newFunc newConnFunc) Operator {
 	return &pluginOperator{
-		newFunc: newFunc,
+		newFunc:      newFunc,
+		capabilities: newCapabilitiesCache(defaultCapabilitiesTTL),
 	}
 }
 
+// GetCapabilities calls GetCapabilities of the ClusterExtension service with the protocol versions which the controller
+// speaks. The plugin servers which don't serve the service speak the protocol v1, so DefaultCapabilities is returned.
+func (p *pluginOperator) GetCapabilities(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error) {
+	return p.capabilities.get(obj, cluster.ID, func() (*Capabilities, error) {
+		c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
+		if err != nil {
+			return nil, err
+		}
+		defer closer()
+		req := &pluginext.GetCapabilitiesRequest{
+			ClusterID:        cluster.ID,
+			ProtocolVersions: ProtocolVersions,
+		}
+		start := time.Now()
+		res, err := c.GetCapabilities(ctx, req)
+		if status.Code(err) == codes.Unimplemented {
+			addSuccessPluginServerCall(metricsGetCapabilities, start)
+			return DefaultCapabilities(), nil
+		}
+		if err != nil {
+			addFailedPluginServerCall(metricsGetCapabilities, start)
+			return nil, err
+		}
+		addSuccessPluginServerCall(metricsGetCapabilities, start)
+		if err := negotiateProtocol(res.ProtocolVersion); err != nil {
+			return nil, err
+		}
+		return &Capabilities{
+			ProtocolVersion:   res.ProtocolVersion,
+			UpgradeNodePool:   res.UpgradeNodePool,
+			ServiceOut:        res.ServiceOut,
+			Rollback:          res.Rollback,
+			AvailableVersions: res.AvailableVersions,
+			Workloads:         res.Workloads,
+		}, nil
+	})
+}
+
 func (p *pluginOperator) GetClusterStatus(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterStatus, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -58,32 +153,18 @@ func (p *pluginOperator) GetClusterStatus(ctx context.Context, obj opsv1.Cluster
 	req := &plugin.GetClusterStatusRequest{
 		ClusterID: cluster.ID,
 	}
+	start := time.Now()
 	res, err := c.GetClusterStatus(ctx, req)
 	if err != nil {
-		addFailedPluginServerCall(metricsGetClusterStatus)
+		addFailedPluginServerCall(metricsGetClusterStatus, start)
 		return nil, err
 	}
-	addSuccessPluginServerCall(metricsGetClusterStatus)
-	switch res.Status {
-	case plugin.ClusterStatusType_STATUS_SERVICE_IN:
-		return &ClusterStatus{
-			Type:      ClusterStatusServiceIn,
-			Available: res.IsAvailable,
-		}, nil
-	case plugin.ClusterStatusType_STATUS_SERVICE_OUT:
-		return &ClusterStatus{
-			Type:      ClusterStatusServiceOut,
-			Available: res.IsAvailable,
-		}, nil
-	}
-	return &ClusterStatus{
-		Type:      ClusterStatusServiceUnknown,
-		Available: false,
-	}, fmt.Errorf("no match cluster status. status: %s", res.Status)
+	addSuccessPluginServerCall(metricsGetClusterStatus, start)
+	return toClusterStatus(res.Status, res.IsAvailable)
 }
 
 func (p *pluginOperator) GetOperationStatus(ctx context.Context, obj opsv1.ClusterVersion) (OperationStatus, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(obj.Status.ClusterID))
 	if err != nil {
 		return OperationStatusUnknown, err
 	}
@@ -93,27 +174,18 @@ func (p *pluginOperator) GetOperationStatus(ctx context.Context, obj opsv1.Clust
 		OperationID: obj.Status.OperationID,
 		Type:        obj.Status.OperationType,
 	}
+	start := time.Now()
 	st, err := c.GetOperationStatus(ctx, req)
 	if err != nil {
-		addFailedPluginServerCall(metricsGetOperationStatus)
+		addFailedPluginServerCall(metricsGetOperationStatus, start)
 		return OperationStatusUnknown, err
 	}
-	addSuccessPluginServerCall(metricsGetOperationStatus)
-	switch st.GetStatus() {
-	case plugin.OperationStatusType_DONE:
-		return OperationStatusDone, nil
-	case plugin.OperationStatusType_RUNNING:
-		return OperationStatusRunning, nil
-	case plugin.OperationStatusType_FAILED:
-		return OperationStatusFailed, nil
-	case plugin.OperationStatusType_UNKNOWN:
-		return OperationStatusUnknown, nil
-	}
-	return OperationStatusUnknown, fmt.Errorf("no match status of the operation. status: %s", st.GetStatus())
+	addSuccessPluginServerCall(metricsGetOperationStatus, start)
+	return toOperationStatus(st.GetStatus())
 }
 
 func (p *pluginOperator) GetClusterVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterVersion, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -121,17 +193,23 @@ func (p *pluginOperator) GetClusterVersion(ctx context.Context, obj opsv1.Cluste
 	req := &plugin.GetVersionRequest{
 		ClusterID: cluster.ID,
 	}
+	start := time.Now()
 	res, err := c.GetVersion(ctx, req)
 	if err != nil {
-		addFailedPluginServerCall(metricsGetClusterVersion)
+		addFailedPluginServerCall(metricsGetClusterVersion, start)
 		return nil, err
 	}
-	addSuccessPluginServerCall(metricsGetClusterVersion)
-	cv := &ClusterVersion{
-		Master: MasterVersion{
+	addSuccessPluginServerCall(metricsGetClusterVersion, start)
+	return toClusterVersion(res), nil
+}
+
+func toClusterVersion(res *plugin.ClusterVersion) *ClusterVersion {
+	cv := &ClusterVersion{}
+	if res.Master != nil {
+		cv.Master = MasterVersion{
 			ClusterID: res.Master.ClusterID,
 			Version:   res.Master.Version,
-		},
+		}
 	}
 	cv.NodePools = make([]NodePoolVersion, len(res.NodePools))
 	for i, np := range res.NodePools {
@@ -140,11 +218,11 @@ func (p *pluginOperator) GetClusterVersion(ctx context.Context, obj opsv1.Cluste
 			Version:    np.Version,
 		}
 	}
-	return cv, nil
+	return cv
 }
 
 func (p *pluginOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -152,12 +230,13 @@ func (p *pluginOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion
 	req := &plugin.ServiceInRequest{
 		ClusterID: cluster.ID,
 	}
-	ops, err := c.ServiceIn(ctx, req)
+	start := time.Now()
+	ops, err := c.ServiceIn(outgoingContext(ctx), req)
 	if err != nil {
-		addFailedPluginServerCall(metricsServiceIn)
+		addFailedPluginServerCall(metricsServiceIn, start)
 		return nil, err
 	}
-	addSuccessPluginServerCall(metricsServiceIn)
+	addSuccessPluginServerCall(metricsServiceIn, start)
 	return &OperationResult{
 		OperationID:   ops.OperationID,
 		OperationType: ops.Type,
@@ -165,7 +244,7 @@ func (p *pluginOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion
 }
 
 func (p *pluginOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -173,12 +252,13 @@ func (p *pluginOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersio
 	req := &plugin.ServiceOutRequest{
 		ClusterID: cluster.ID,
 	}
-	ops, err := c.ServiceOut(ctx, req)
+	start := time.Now()
+	ops, err := c.ServiceOut(outgoingContext(ctx), req)
 	if err != nil {
-		addFailedPluginServerCall(metricsServiceOut)
+		addFailedPluginServerCall(metricsServiceOut, start)
 		return nil, err
 	}
-	addSuccessPluginServerCall(metricsServiceOut)
+	addSuccessPluginServerCall(metricsServiceOut, start)
 	return &OperationResult{
 		OperationID:   ops.OperationID,
 		OperationType: ops.Type,
@@ -186,7 +266,7 @@ func (p *pluginOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersio
 }
 
 func (p *pluginOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -195,12 +275,13 @@ func (p *pluginOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVer
 		ClusterID: cluster.ID,
 		Version:   cluster.Version,
 	}
-	res, err := c.UpgradeMaster(ctx, req)
+	start := time.Now()
+	res, err := c.UpgradeMaster(outgoingContext(ctx), req)
 	if err != nil {
-		addFailedPluginServerCall(metricsUpgradeMaster)
+		addFailedPluginServerCall(metricsUpgradeMaster, start)
 		return nil, err
 	}
-	addSuccessPluginServerCall(metricsUpgradeMaster)
+	addSuccessPluginServerCall(metricsUpgradeMaster, start)
 	return &OperationResult{
 		OperationID:   res.OperationID,
 		OperationType: res.Type,
@@ -208,7 +289,7 @@ func (p *pluginOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVer
 }
 
 func (p *pluginOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
-	c, closer, err := p.newFunc(obj)
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -218,14 +299,294 @@ func (p *pluginOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterV
 		NodePoolID: nodePoolID,
 		Version:    cluster.Version,
 	}
-	res, err := c.UpgradeNodePool(ctx, req)
+	start := time.Now()
+	res, err := c.UpgradeNodePool(outgoingContext(ctx), req)
+	if err != nil {
+		addFailedPluginServerCall(metricsUpgradeNodePool, start)
+		return nil, err
+	}
+	addSuccessPluginServerCall(metricsUpgradeNodePool, start)
+	return &OperationResult{
+		OperationID:   res.OperationID,
+		OperationType: res.Type,
+	}, nil
+}
+
+// GetAvailableVersions calls GetAvailableVersions of the ClusterExtension service.
+func (p *pluginOperator) GetAvailableVersions(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, channel string) ([]string, error) {
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	req := &pluginext.GetAvailableVersionsRequest{
+		ClusterID: cluster.ID,
+		Channel:   channel,
+	}
+	start := time.Now()
+	res, err := c.GetAvailableVersions(ctx, req)
+	if err != nil {
+		addFailedPluginServerCall(metricsGetVersions, start)
+		return nil, extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsGetVersions, start)
+	return res.Versions, nil
+}
+
+// GetWorkloadVersion calls GetWorkloadVersion of the ClusterExtension service.
+func (p *pluginOperator) GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
+	if err != nil {
+		return "", err
+	}
+	defer closer()
+	req := &pluginext.GetWorkloadVersionRequest{
+		ClusterID: cluster.ID,
+		Name:      workload.Name,
+	}
+	start := time.Now()
+	res, err := c.GetWorkloadVersion(ctx, req)
+	if err != nil {
+		addFailedPluginServerCall(metricsGetWorkloadVersion, start)
+		return "", extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsGetWorkloadVersion, start)
+	return res.Version, nil
+}
+
+// UpgradeWorkload calls UpgradeWorkload of the ClusterExtension service.
+func (p *pluginOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
 	if err != nil {
-		addFailedPluginServerCall(metricsUpgradeNodePool)
 		return nil, err
 	}
-	addSuccessPluginServerCall(metricsUpgradeNodePool)
+	defer closer()
+	req := &pluginext.WorkloadVersion{
+		ClusterID: cluster.ID,
+		Name:      workload.Name,
+		Version:   workload.Version,
+	}
+	start := time.Now()
+	res, err := c.UpgradeWorkload(outgoingContext(ctx), req)
+	if err != nil {
+		addFailedPluginServerCall(metricsUpgradeWorkload, start)
+		return nil, extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsUpgradeWorkload, start)
 	return &OperationResult{
 		OperationID:   res.OperationID,
 		OperationType: res.Type,
 	}, nil
 }
+
+// GetOperationDetail calls GetOperationDetail of the ClusterExtension service.
+func (p *pluginOperator) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(obj.Status.ClusterID))
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	req := &plugin.GetOperationStatusRequest{
+		ClusterID:   obj.Status.ClusterID,
+		OperationID: obj.Status.OperationID,
+		Type:        obj.Status.OperationType,
+	}
+	start := time.Now()
+	res, err := c.GetOperationDetail(ctx, req)
+	if err != nil {
+		addFailedPluginServerCall(metricsGetOperationDetail, start)
+		return nil, extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsGetOperationDetail, start)
+	opStatus, err := toOperationStatus(res.Status)
+	if err != nil {
+		return nil, err
+	}
+	detail := &OperationDetail{
+		Status:  opStatus,
+		Message: res.Message,
+		Error:   res.Error,
+	}
+	if res.Progress != nil {
+		progress := res.Progress.Value
+		detail.Progress = &progress
+	}
+	return detail, nil
+}
+
+// ListOperations calls ListOperations of the ClusterExtension service.
+func (p *pluginOperator) ListOperations(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error) {
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(cluster.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	start := time.Now()
+	res, err := c.ListOperations(ctx, &pluginext.ListOperationsRequest{ClusterID: cluster.ID})
+	if err != nil {
+		addFailedPluginServerCall(metricsListOperations, start)
+		return nil, extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsListOperations, start)
+	operations := make([]Operation, len(res.Operations))
+	for i, op := range res.Operations {
+		opStatus, err := toOperationStatus(op.Status)
+		if err != nil {
+			return nil, err
+		}
+		operations[i] = Operation{
+			OperationID:   op.OperationID,
+			OperationType: op.Type,
+			Target:        op.Target,
+			Status:        opStatus,
+		}
+	}
+	return operations, nil
+}
+
+// GetFleetStatus calls GetFleetStatus of the ClusterExtension service of each endpoint once with its clusters.
+// The clusters of the plugin servers without the method are left out of the result.
+func (p *pluginOperator) GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error) {
+	var ret []FleetClusterStatus
+	supported := false
+	for _, group := range groupClusters(clusters, func(c opsv1.Cluster) interface{} { return obj.Spec.GetOpsEndpoint(c.ID) }) {
+		statuses, err := p.getFleetStatus(ctx, obj.Spec.GetOpsEndpoint(group[0].ID), clusterIDs(group))
+		if errors.Is(err, ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		supported = true
+		ret = append(ret, statuses...)
+	}
+	if !supported {
+		return nil, ErrNotSupported
+	}
+	return ret, nil
+}
+
+func (p *pluginOperator) getFleetStatus(ctx context.Context, endpoint opsv1.OpsEndpoint, clusterIDs []string) ([]FleetClusterStatus, error) {
+	c, closer, err := p.newFunc(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	start := time.Now()
+	res, err := c.GetFleetStatus(ctx, &pluginext.GetFleetStatusRequest{ClusterIDs: clusterIDs})
+	if err != nil {
+		addFailedPluginServerCall(metricsGetFleetStatus, start)
+		return nil, extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsGetFleetStatus, start)
+	statuses := make([]FleetClusterStatus, 0, len(res.Clusters))
+	for _, c := range res.Clusters {
+		if c.Version == nil || c.Status == nil {
+			return nil, fmt.Errorf("cluster %s: no version or status", c.ClusterID)
+		}
+		cs, err := toClusterStatus(c.Status.Status, c.Status.IsAvailable)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", c.ClusterID, err)
+		}
+		statuses = append(statuses, FleetClusterStatus{
+			ClusterID: c.ClusterID,
+			Version:   *toClusterVersion(c.Version),
+			Status:    *cs,
+		})
+	}
+	return statuses, nil
+}
+
+// WatchOperation calls WatchOperation of the ClusterExtension service, and waits for the first status
+// to know whether the plugin server serves it. The connection is closed when the stream ends.
+func (p *pluginOperator) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	c, closer, err := p.newFunc(obj.Spec.GetOpsEndpoint(obj.Status.ClusterID))
+	if err != nil {
+		return nil, err
+	}
+	req := &plugin.GetOperationStatusRequest{
+		ClusterID:   obj.Status.ClusterID,
+		OperationID: obj.Status.OperationID,
+		Type:        obj.Status.OperationType,
+	}
+	start := time.Now()
+	stream, err := c.WatchOperation(ctx, req)
+	var first *plugin.OperationStatus
+	if err == nil {
+		first, err = stream.Recv()
+	}
+	if err != nil {
+		closer()
+		addFailedPluginServerCall(metricsWatchOperation, start)
+		return nil, extensionError(err)
+	}
+	addSuccessPluginServerCall(metricsWatchOperation, start)
+
+	ch := make(chan OperationStatus)
+	go func() {
+		defer closer()
+		defer close(ch)
+		st := first
+		for {
+			opStatus, err := toOperationStatus(st.GetStatus())
+			if err != nil {
+				ctrl.Log.Error(err, "invalid operation status in the stream", "operation_id", req.OperationID)
+				return
+			}
+			select {
+			case ch <- opStatus:
+			case <-ctx.Done():
+				return
+			}
+			if st, err = stream.Recv(); err != nil {
+				// io.EOF when the operation has completed, and the controller polls the status in any case
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// extensionError returns ErrNotSupported if the plugin server doesn't serve the method of the ClusterExtension service,
+// i.e. it speaks the protocol v1.
+func extensionError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return fmt.Errorf("%w: %s", ErrNotSupported, status.Convert(err).Message())
+	}
+	return err
+}
+
+// toClusterStatus converts the cluster status of the plugin protocol, which is shared by gRPC and HTTP.
+func toClusterStatus(status plugin.ClusterStatusType, available bool) (*ClusterStatus, error) {
+	switch status {
+	case plugin.ClusterStatusType_STATUS_SERVICE_IN:
+		return &ClusterStatus{
+			Type:      ClusterStatusServiceIn,
+			Available: available,
+		}, nil
+	case plugin.ClusterStatusType_STATUS_SERVICE_OUT:
+		return &ClusterStatus{
+			Type:      ClusterStatusServiceOut,
+			Available: available,
+		}, nil
+	}
+	return &ClusterStatus{
+		Type:      ClusterStatusServiceUnknown,
+		Available: false,
+	}, fmt.Errorf("no match cluster status. status: %s", status)
+}
+
+// toOperationStatus converts the operation status of the plugin protocol, which is shared by gRPC and HTTP.
+func toOperationStatus(status plugin.OperationStatusType) (OperationStatus, error) {
+	switch status {
+	case plugin.OperationStatusType_DONE:
+		return OperationStatusDone, nil
+	case plugin.OperationStatusType_RUNNING:
+		return OperationStatusRunning, nil
+	case plugin.OperationStatusType_FAILED:
+		return OperationStatusFailed, nil
+	case plugin.OperationStatusType_UNKNOWN:
+		return OperationStatusUnknown, nil
+	}
+	return OperationStatusUnknown, fmt.Errorf("no match status of the operation. status: %s", status)
+}
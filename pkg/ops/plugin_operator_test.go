@@ -2,13 +2,27 @@ package ops
 
 import (
 	"context"
+	"errors"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
 	"github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
 	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"testing"
+	"time"
 )
 
+// mockPluginClient combines the mocks of both services. The ClusterExtension mock is nil unless the test calls it.
+type mockPluginClient struct {
+	*plugin.MockClusterClient
+	*pluginext.MockClusterExtensionClient
+}
+
 func makeClusterVersionResource() *v1.ClusterVersion {
 	return &v1.ClusterVersion{
 		Spec: v1.ClusterVersionSpec{
@@ -79,8 +93,8 @@ func TestPluginOperator_GetClusterStatus(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			req := &plugin.GetClusterStatusRequest{
 				ClusterID: obj.Spec.Clusters[0].ID,
@@ -145,8 +159,8 @@ func TestPluginOperator_GetOperationStatus(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			obj.Status.ClusterID = obj.Spec.Clusters[0].ID
 			obj.Status.OperationID = "dummy"
@@ -225,8 +239,8 @@ func TestPluginOperator_GetClusterVersion(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			req := &plugin.GetVersionRequest{
 				ClusterID: obj.Spec.Clusters[0].ID,
@@ -275,8 +289,8 @@ func TestPluginOperator_ServiceIn(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			req := &plugin.ServiceInRequest{
 				ClusterID: obj.Spec.Clusters[0].ID,
@@ -325,8 +339,8 @@ func TestPluginOperator_ServiceOut(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			req := &plugin.ServiceOutRequest{
 				ClusterID: obj.Spec.Clusters[0].ID,
@@ -375,8 +389,8 @@ func TestPluginOperator_UpgradeMaster(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			req := &plugin.MasterVersion{
 				ClusterID: obj.Spec.Clusters[0].ID,
@@ -426,8 +440,8 @@ func TestPluginOperator_UpgradeNodePool(t *testing.T) {
 				obj = makeClusterVersionResource()
 			)
 			c := plugin.NewMockClusterClient(ctrl)
-			operator := NewPluginOperator(func(obj v1.ClusterVersion) (plugin.ClusterClient, func(), error) {
-				return c, func() {}, nil
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterClient: c}, func() {}, nil
 			})
 			req := &plugin.NodePoolVersion{
 				ClusterID:  obj.Spec.Clusters[0].ID,
@@ -446,3 +460,508 @@ func TestPluginOperator_UpgradeNodePool(t *testing.T) {
 		})
 	}
 }
+
+func TestPluginOperator_OpsEndpoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	obj := makeClusterVersionResource()
+	override := v1.OpsEndpoint{Endpoint: "eks.example.com"}
+	obj.Spec.Clusters = append(obj.Spec.Clusters, v1.Cluster{ID: "eks-cluster", Version: "1.18", OpsEndpoint: &override})
+	obj.Status.ClusterID = "eks-cluster"
+
+	c := plugin.NewMockClusterClient(ctrl)
+	c.EXPECT().GetClusterStatus(gomock.Any(), gomock.Any()).Return(&plugin.ClusterStatus{Status: plugin.ClusterStatusType_STATUS_SERVICE_IN}, nil).Times(2)
+	c.EXPECT().GetOperationStatus(gomock.Any(), gomock.Any()).Return(&plugin.OperationStatus{Status: plugin.OperationStatusType_DONE}, nil).Times(1)
+	var endpoints []v1.OpsEndpoint
+	operator := NewPluginOperator(func(endpoint v1.OpsEndpoint) (PluginClient, func(), error) {
+		endpoints = append(endpoints, endpoint)
+		return &mockPluginClient{MockClusterClient: c}, func() {}, nil
+	})
+
+	_, err := operator.GetClusterStatus(ctx, *obj, obj.Spec.Clusters[0])
+	g.Expect(err).Should(BeNil())
+	_, err = operator.GetClusterStatus(ctx, *obj, obj.Spec.Clusters[1])
+	g.Expect(err).Should(BeNil())
+	_, err = operator.GetOperationStatus(ctx, *obj)
+	g.Expect(err).Should(BeNil())
+	g.Expect(endpoints).Should(Equal([]v1.OpsEndpoint{obj.Spec.OpsEndpoint, override, override}))
+}
+
+func TestPluginOperator_GetCapabilities(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ret         *pluginext.Capabilities
+		retErr      error
+		expected    *Capabilities
+		expectedErr error
+	}{
+		{
+			name: "protocol v2",
+			ret: &pluginext.Capabilities{
+				ProtocolVersion:   ProtocolVersionV2,
+				UpgradeNodePool:   false,
+				ServiceOut:        true,
+				AvailableVersions: []string{"1.0.0", "1.0.1"},
+			},
+			expected: &Capabilities{
+				ProtocolVersion:   ProtocolVersionV2,
+				UpgradeNodePool:   false,
+				ServiceOut:        true,
+				AvailableVersions: []string{"1.0.0", "1.0.1"},
+			},
+		},
+		{
+			name:     "ClusterExtension isn't served",
+			retErr:   status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension"),
+			expected: DefaultCapabilities(),
+		},
+		{
+			name:        "unsupported protocol version",
+			ret:         &pluginext.Capabilities{ProtocolVersion: "v100"},
+			expectedErr: ErrUnsupportedProtocol,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g   = NewGomegaWithT(t)
+				ctx = context.Background()
+				obj = makeClusterVersionResource()
+			)
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(CapabilitiesOperator)
+			req := &pluginext.GetCapabilitiesRequest{
+				ClusterID:        obj.Spec.Clusters[0].ID,
+				ProtocolVersions: ProtocolVersions,
+			}
+			c.EXPECT().GetCapabilities(gomock.Any(), gomock.Eq(req)).Return(testCase.ret, testCase.retErr).Times(1)
+
+			caps, err := operator.GetCapabilities(ctx, *obj, obj.Spec.Clusters[0])
+			if testCase.expectedErr != nil {
+				g.Expect(errors.Is(err, testCase.expectedErr)).Should(BeTrue())
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(caps).Should(Equal(testCase.expected))
+			// cached per endpoint and cluster
+			caps, err = operator.GetCapabilities(ctx, *obj, obj.Spec.Clusters[0])
+			g.Expect(err).Should(BeNil())
+			g.Expect(caps).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+func TestPluginOperator_GetAvailableVersions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ret         *pluginext.AvailableVersions
+		retErr      error
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:     "ret the versions",
+			ret:      &pluginext.AvailableVersions{Versions: []string{"1.0.1", "1.0.2"}},
+			expected: []string{"1.0.1", "1.0.2"},
+		},
+		{
+			name:        "ret ErrNotSupported for the protocol v1",
+			retErr:      status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension"),
+			expectedErr: ErrNotSupported,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g   = NewGomegaWithT(t)
+				ctx = context.Background()
+				obj = makeClusterVersionResource()
+			)
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(VersionOperator)
+			req := &pluginext.GetAvailableVersionsRequest{
+				ClusterID: obj.Spec.Clusters[0].ID,
+				Channel:   "REGULAR",
+			}
+			c.EXPECT().GetAvailableVersions(gomock.Any(), gomock.Eq(req)).Return(testCase.ret, testCase.retErr).Times(1)
+
+			versions, err := operator.GetAvailableVersions(ctx, *obj, obj.Spec.Clusters[0], "REGULAR")
+			if testCase.expectedErr != nil {
+				g.Expect(errors.Is(err, testCase.expectedErr)).Should(BeTrue())
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(versions).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+func TestPluginOperator_GetWorkloadVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ret         *pluginext.WorkloadVersion
+		retErr      error
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "ret the version",
+			ret:      &pluginext.WorkloadVersion{ClusterID: "test-cluster", Name: "istio", Version: "1.7.0"},
+			expected: "1.7.0",
+		},
+		{
+			name:        "ret ErrNotSupported for the protocol v1",
+			retErr:      status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension"),
+			expectedErr: ErrNotSupported,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g   = NewGomegaWithT(t)
+				ctx = context.Background()
+				obj = makeClusterVersionResource()
+			)
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(WorkloadOperator)
+			req := &pluginext.GetWorkloadVersionRequest{
+				ClusterID: obj.Spec.Clusters[0].ID,
+				Name:      "istio",
+			}
+			c.EXPECT().GetWorkloadVersion(gomock.Any(), gomock.Eq(req)).Return(testCase.ret, testCase.retErr).Times(1)
+
+			version, err := operator.GetWorkloadVersion(ctx, *obj, obj.Spec.Clusters[0], v1.Workload{Name: "istio", Version: "1.8.0"})
+			if testCase.expectedErr != nil {
+				g.Expect(errors.Is(err, testCase.expectedErr)).Should(BeTrue())
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(version).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+func TestPluginOperator_UpgradeWorkload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ret         *plugin.Operation
+		retErr      error
+		expected    *OperationResult
+		expectedErr error
+	}{
+		{
+			name:     "ret the operation",
+			ret:      &plugin.Operation{OperationID: "op-1", Type: "UPGRADE_WORKLOAD"},
+			expected: &OperationResult{OperationID: "op-1", OperationType: "UPGRADE_WORKLOAD"},
+		},
+		{
+			name:        "ret ErrNotSupported for the protocol v1",
+			retErr:      status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension"),
+			expectedErr: ErrNotSupported,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g   = NewGomegaWithT(t)
+				ctx = context.Background()
+				obj = makeClusterVersionResource()
+			)
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(WorkloadOperator)
+			req := &pluginext.WorkloadVersion{
+				ClusterID: obj.Spec.Clusters[0].ID,
+				Name:      "istio",
+				Version:   "1.8.0",
+			}
+			c.EXPECT().UpgradeWorkload(gomock.Any(), gomock.Eq(req)).Return(testCase.ret, testCase.retErr).Times(1)
+
+			res, err := operator.UpgradeWorkload(ctx, *obj, obj.Spec.Clusters[0], v1.Workload{Name: "istio", Version: "1.8.0"})
+			if testCase.expectedErr != nil {
+				g.Expect(errors.Is(err, testCase.expectedErr)).Should(BeTrue())
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(res).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+// TestPluginOperator_WatchOperation streams the status through a real gRPC stream of the fake plugin server.
+func TestPluginOperator_WatchOperation(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	server := fakeplugin.NewServer(fakeplugin.Config{
+		Settings: fakeplugin.Settings{OperationDuration: &metav1.Duration{Duration: time.Millisecond * 300}},
+		Clusters: []fakeplugin.ClusterConfig{{ID: "test-cluster", Version: "1.0.0"}},
+	})
+	operator := NewInProcessOperator(server)
+	obj := makeClusterVersionResource()
+	res, err := operator.UpgradeMaster(ctx, *obj, v1.Cluster{ID: "test-cluster", Version: "1.0.1"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	obj.Status.ClusterID = "test-cluster"
+	obj.Status.OperationID = res.OperationID
+	obj.Status.OperationType = res.OperationType
+
+	ch, err := operator.(WatchOperator).WatchOperation(ctx, *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Eventually(ch).Should(Receive(Equal(OperationStatusRunning)))
+	g.Eventually(ch, time.Second*5).Should(Receive(Equal(OperationStatusDone)))
+	g.Eventually(ch).Should(BeClosed())
+
+	// the stream is closed when ctx is done
+	res, err = operator.UpgradeMaster(ctx, *obj, v1.Cluster{ID: "test-cluster", Version: "1.0.2"})
+	g.Expect(err).ShouldNot(HaveOccurred())
+	obj.Status.OperationID = res.OperationID
+	watchCtx, cancel := context.WithCancel(ctx)
+	ch, err = operator.(WatchOperator).WatchOperation(watchCtx, *obj)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Eventually(ch).Should(Receive(Equal(OperationStatusRunning)))
+	cancel()
+	g.Eventually(ch).Should(BeClosed())
+
+	// the plugin server of the protocol v1 doesn't serve the stream
+	v1Operator := NewInProcessOperator(struct{ plugin.ClusterServer }{server})
+	_, err = v1Operator.(WatchOperator).WatchOperation(ctx, *obj)
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+}
+
+func TestPluginOperator_GetOperationDetail(t *testing.T) {
+	progress := func(v int32) *int32 { return &v }
+	testCases := []struct {
+		name        string
+		ret         *pluginext.OperationDetail
+		retErr      error
+		expected    *OperationDetail
+		expectedErr error
+	}{
+		{
+			name:     "ret the detail with the progress",
+			ret:      &pluginext.OperationDetail{Status: plugin.OperationStatusType_RUNNING, Progress: wrapperspb.Int32(40), Message: "upgrading nodes"},
+			expected: &OperationDetail{Status: OperationStatusRunning, Progress: progress(40), Message: "upgrading nodes"},
+		},
+		{
+			name:     "ret the detail without the progress",
+			ret:      &pluginext.OperationDetail{Status: plugin.OperationStatusType_FAILED, Error: "quota exceeded"},
+			expected: &OperationDetail{Status: OperationStatusFailed, Error: "quota exceeded"},
+		},
+		{
+			name:        "ret ErrNotSupported for the protocol v1",
+			retErr:      status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension"),
+			expectedErr: ErrNotSupported,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g   = NewGomegaWithT(t)
+				ctx = context.Background()
+				obj = makeClusterVersionResource()
+			)
+			obj.Status.ClusterID = "test-cluster"
+			obj.Status.OperationID = "op-1"
+			obj.Status.OperationType = "UPGRADE_MASTER"
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(OperationDetailOperator)
+			req := &plugin.GetOperationStatusRequest{
+				ClusterID:   "test-cluster",
+				OperationID: "op-1",
+				Type:        "UPGRADE_MASTER",
+			}
+			c.EXPECT().GetOperationDetail(gomock.Any(), gomock.Eq(req)).Return(testCase.ret, testCase.retErr).Times(1)
+
+			detail, err := operator.GetOperationDetail(ctx, *obj)
+			if testCase.expectedErr != nil {
+				g.Expect(errors.Is(err, testCase.expectedErr)).Should(BeTrue())
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(detail).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+func TestPluginOperator_ListOperations(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ret         *pluginext.Operations
+		retErr      error
+		expected    []Operation
+		expectedErr error
+	}{
+		{
+			name: "ret the operations",
+			ret: &pluginext.Operations{Operations: []*pluginext.ListedOperation{
+				{OperationID: "op-1", Type: "UPGRADE_MASTER", Status: plugin.OperationStatusType_RUNNING},
+				{OperationID: "op-2", Type: "UPGRADE_NODE_POOL", Target: "pool-1", Status: plugin.OperationStatusType_RUNNING},
+			}},
+			expected: []Operation{
+				{OperationID: "op-1", OperationType: "UPGRADE_MASTER", Status: OperationStatusRunning},
+				{OperationID: "op-2", OperationType: "UPGRADE_NODE_POOL", Target: "pool-1", Status: OperationStatusRunning},
+			},
+		},
+		{
+			name:     "ret no operations",
+			ret:      &pluginext.Operations{},
+			expected: []Operation{},
+		},
+		{
+			name:        "ret ErrNotSupported for the protocol v1",
+			retErr:      status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension"),
+			expectedErr: ErrNotSupported,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var (
+				g   = NewGomegaWithT(t)
+				ctx = context.Background()
+				obj = makeClusterVersionResource()
+			)
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(_ v1.OpsEndpoint) (PluginClient, func(), error) {
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(OperationListOperator)
+			req := &pluginext.ListOperationsRequest{ClusterID: obj.Spec.Clusters[0].ID}
+			c.EXPECT().ListOperations(gomock.Any(), gomock.Eq(req)).Return(testCase.ret, testCase.retErr).Times(1)
+
+			operations, err := operator.ListOperations(ctx, *obj, obj.Spec.Clusters[0])
+			if testCase.expectedErr != nil {
+				g.Expect(errors.Is(err, testCase.expectedErr)).Should(BeTrue())
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(operations).Should(Equal(testCase.expected))
+		})
+	}
+}
+
+func TestPluginOperator_GetFleetStatus(t *testing.T) {
+	other := v1.OpsEndpoint{Endpoint: "other.example.com", Insecure: true}
+	obj := makeClusterVersionResource()
+	obj.Spec.Clusters = []v1.Cluster{
+		{ID: "cluster-1"},
+		{ID: "cluster-2"},
+		{ID: "cluster-3", OpsEndpoint: &other},
+	}
+	clusterStatus := func(id string) *pluginext.FleetClusterStatus {
+		return &pluginext.FleetClusterStatus{
+			ClusterID: id,
+			Version: &plugin.ClusterVersion{
+				Master:    &plugin.MasterVersion{ClusterID: id, Version: "1.0.0"},
+				NodePools: []*plugin.NodePoolVersion{{ClusterID: id, NodePoolID: "np-1", Version: "0.9.0"}},
+			},
+			Status: &plugin.ClusterStatus{Status: plugin.ClusterStatusType_STATUS_SERVICE_IN, IsAvailable: true},
+		}
+	}
+	expected := func(id string) FleetClusterStatus {
+		return FleetClusterStatus{
+			ClusterID: id,
+			Version: ClusterVersion{
+				Master:    MasterVersion{ClusterID: id, Version: "1.0.0"},
+				NodePools: []NodePoolVersion{{NodePoolID: "np-1", Version: "0.9.0"}},
+			},
+			Status: ClusterStatus{Type: ClusterStatusServiceIn, Available: true},
+		}
+	}
+	unimplemented := status.Error(codes.Unimplemented, "unknown service plugin.ClusterExtension")
+
+	testCases := []struct {
+		name        string
+		ret         *pluginext.FleetStatus
+		retErr      error
+		otherRet    *pluginext.FleetStatus
+		otherRetErr error
+		expected    []FleetClusterStatus
+		expectedErr error
+	}{
+		{
+			name:     "call each endpoint once with its clusters",
+			ret:      &pluginext.FleetStatus{Clusters: []*pluginext.FleetClusterStatus{clusterStatus("cluster-1"), clusterStatus("cluster-2")}},
+			otherRet: &pluginext.FleetStatus{Clusters: []*pluginext.FleetClusterStatus{clusterStatus("cluster-3")}},
+			expected: []FleetClusterStatus{expected("cluster-1"), expected("cluster-2"), expected("cluster-3")},
+		},
+		{
+			name:        "leave out the clusters of the plugin server of the protocol v1",
+			ret:         &pluginext.FleetStatus{Clusters: []*pluginext.FleetClusterStatus{clusterStatus("cluster-1")}},
+			otherRetErr: unimplemented,
+			expected:    []FleetClusterStatus{expected("cluster-1")},
+		},
+		{
+			name:        "ret ErrNotSupported if no plugin server has it",
+			retErr:      unimplemented,
+			otherRetErr: unimplemented,
+			expectedErr: ErrNotSupported,
+		},
+		{
+			name:        "ret an error for the cluster without the status",
+			ret:         &pluginext.FleetStatus{Clusters: []*pluginext.FleetClusterStatus{{ClusterID: "cluster-1"}}},
+			otherRet:    &pluginext.FleetStatus{},
+			expectedErr: errors.New("cluster cluster-1: no version or status"),
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			c := pluginext.NewMockClusterExtensionClient(ctrl)
+			otherClient := pluginext.NewMockClusterExtensionClient(ctrl)
+			operator := NewPluginOperator(func(endpoint v1.OpsEndpoint) (PluginClient, func(), error) {
+				if endpoint == other {
+					return &mockPluginClient{MockClusterExtensionClient: otherClient}, func() {}, nil
+				}
+				return &mockPluginClient{MockClusterExtensionClient: c}, func() {}, nil
+			}).(FleetStatusOperator)
+			c.EXPECT().GetFleetStatus(gomock.Any(), gomock.Eq(&pluginext.GetFleetStatusRequest{ClusterIDs: []string{"cluster-1", "cluster-2"}})).
+				Return(testCase.ret, testCase.retErr).Times(1)
+			otherClient.EXPECT().GetFleetStatus(gomock.Any(), gomock.Eq(&pluginext.GetFleetStatusRequest{ClusterIDs: []string{"cluster-3"}})).
+				Return(testCase.otherRet, testCase.otherRetErr).MaxTimes(1)
+
+			statuses, err := operator.GetFleetStatus(context.Background(), *obj, obj.Spec.Clusters)
+			if testCase.expectedErr != nil {
+				g.Expect(err).Should(HaveOccurred())
+				if errors.Is(testCase.expectedErr, ErrNotSupported) {
+					g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+				} else {
+					g.Expect(err.Error()).Should(Equal(testCase.expectedErr.Error()))
+				}
+				return
+			}
+			g.Expect(err).Should(BeNil())
+			g.Expect(statuses).Should(Equal(testCase.expected))
+		})
+	}
+}
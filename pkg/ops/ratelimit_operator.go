@@ -0,0 +1,73 @@
+package ops
+
+import (
+	"context"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+// rateLimitedOperator shares a token bucket among the mutating calls of the wrapped Operator,
+// so that the rollouts of many ClusterVersions starting at once don't exhaust the API quota of the provider.
+// The other calls aren't limited.
+type rateLimitedOperator struct {
+	wrapped
+	limiter *rate.Limiter
+}
+
+var _ extendedOperator = &rateLimitedOperator{}
+
+// NewRateLimitedOperator returns the Operator which calls ServiceIn, ServiceOut, UpgradeMaster, UpgradeNodePool
+// and UpgradeWorkload of the given Operator at most qps times per second, with bursts of at most burst calls.
+// The calls wait for the token until the context is done.
+func NewRateLimitedOperator(operator Operator, qps float64, burst int) Operator {
+	return &rateLimitedOperator{
+		wrapped: wrapped{operator},
+		limiter: rate.NewLimiter(rate.Limit(qps), burst),
+	}
+}
+
+func (r *rateLimitedOperator) wait(ctx context.Context, method string) error {
+	start := time.Now()
+	err := r.limiter.Wait(ctx)
+	observeRateLimitWait(method, start)
+	return err
+}
+
+func (r *rateLimitedOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	if err := r.wait(ctx, metricsServiceIn); err != nil {
+		return nil, err
+	}
+	return r.Operator.ServiceIn(ctx, obj, cluster)
+}
+
+func (r *rateLimitedOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	if err := r.wait(ctx, metricsServiceOut); err != nil {
+		return nil, err
+	}
+	return r.Operator.ServiceOut(ctx, obj, cluster)
+}
+
+func (r *rateLimitedOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	if err := r.wait(ctx, metricsUpgradeMaster); err != nil {
+		return nil, err
+	}
+	return r.Operator.UpgradeMaster(ctx, obj, cluster)
+}
+
+func (r *rateLimitedOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+	if err := r.wait(ctx, metricsUpgradeNodePool); err != nil {
+		return nil, err
+	}
+	return r.Operator.UpgradeNodePool(ctx, obj, cluster, nodePoolID)
+}
+
+func (r *rateLimitedOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	if _, ok := r.Operator.(WorkloadOperator); !ok {
+		return nil, ErrNotSupported
+	}
+	if err := r.wait(ctx, metricsUpgradeWorkload); err != nil {
+		return nil, err
+	}
+	return r.wrapped.UpgradeWorkload(ctx, obj, cluster, workload)
+}
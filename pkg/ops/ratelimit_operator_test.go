@@ -0,0 +1,34 @@
+package ops
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedOperator(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	cluster := v1.Cluster{ID: "test-cluster", Version: "1.16.13-gke.404"}
+	// a token per hour, so only the first mutating call is allowed in the test
+	op := NewRateLimitedOperator(newFakeFleetOperator(cluster.ID, cluster.Version), 1.0/3600, 1)
+
+	_, err := op.ServiceOut(context.Background(), *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = op.ServiceIn(ctx, *obj, cluster)
+	g.Expect(err).Should(HaveOccurred())
+	_, err = op.UpgradeMaster(ctx, *obj, cluster)
+	g.Expect(err).Should(HaveOccurred())
+
+	// the other calls aren't limited
+	_, err = op.GetClusterStatus(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	operations, err := op.(OperationListOperator).ListOperations(ctx, *obj, cluster)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(operations).Should(HaveLen(1))
+}
@@ -0,0 +1,277 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"sort"
+	"sync"
+)
+
+const (
+	// ProviderGRPC is the provider of the gRPC plugin server. It's used when spec.opsEndpoint.provider is empty.
+	ProviderGRPC = "grpc"
+	// ProviderHTTP is the provider of the HTTP/JSON plugin server. It's used when spec.opsEndpoint.provider is empty
+	// and spec.opsEndpoint.protocol is "http".
+	ProviderHTTP = "http"
+)
+
+// Registry holds the Operators by their provider name.
+type Registry struct {
+	operators map[string]Operator
+	lock      sync.RWMutex
+}
+
+// DefaultRegistry is the Registry which the Go packages register their Operators to with Register.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		operators: map[string]Operator{},
+	}
+}
+
+// Register registers the Operator to DefaultRegistry. It panics if the name is already registered.
+func Register(name string, operator Operator) {
+	if err := DefaultRegistry.Register(name, operator); err != nil {
+		panic(err)
+	}
+}
+
+// Register registers the Operator under the name.
+func (r *Registry) Register(name string, operator Operator) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.operators[name]; ok {
+		return fmt.Errorf("provider %s is already registered", name)
+	}
+	r.operators[name] = operator
+	return nil
+}
+
+// Get returns the Operator registered under the name.
+func (r *Registry) Get(name string) (Operator, error) {
+	if name == "" {
+		name = ProviderGRPC
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	operator, ok := r.operators[name]
+	if !ok {
+		return nil, fmt.Errorf("provider %s isn't registered", name)
+	}
+	return operator, nil
+}
+
+// Providers returns the registered names in order.
+func (r *Registry) Providers() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	names := make([]string, 0, len(r.operators))
+	for name := range r.operators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registryOperator delegates every method to the Operator of the provider of each cluster.
+type registryOperator struct {
+	registry *Registry
+}
+
+var _ extendedOperator = &registryOperator{}
+
+// NewRegistryOperator returns the Operator which selects the Operator from the Registry
+// by spec.clusters[].opsEndpoint.provider or spec.opsEndpoint.provider.
+func NewRegistryOperator(registry *Registry) Operator {
+	return &registryOperator{
+		registry: registry,
+	}
+}
+
+func (r *registryOperator) operator(obj opsv1.ClusterVersion, clusterID string) (Operator, error) {
+	return r.registry.Get(providerOf(obj, clusterID))
+}
+
+// providerOf returns the provider of the cluster. Empty means ProviderGRPC.
+func providerOf(obj opsv1.ClusterVersion, clusterID string) string {
+	endpoint := obj.Spec.GetOpsEndpoint(clusterID)
+	if endpoint.Provider == "" && endpoint.Protocol == opsv1.OpsProtocolHTTP {
+		return ProviderHTTP
+	}
+	return endpoint.Provider
+}
+
+func (r *registryOperator) GetOperationStatus(ctx context.Context, obj opsv1.ClusterVersion) (OperationStatus, error) {
+	op, err := r.operator(obj, obj.Status.ClusterID)
+	if err != nil {
+		return OperationStatusUnknown, err
+	}
+	return op.GetOperationStatus(ctx, obj)
+}
+
+func (r *registryOperator) GetClusterVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterVersion, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	return op.GetClusterVersion(ctx, obj, cluster)
+}
+
+func (r *registryOperator) GetClusterStatus(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterStatus, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	return op.GetClusterStatus(ctx, obj, cluster)
+}
+
+func (r *registryOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	return op.ServiceIn(ctx, obj, cluster)
+}
+
+func (r *registryOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	return op.ServiceOut(ctx, obj, cluster)
+}
+
+func (r *registryOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	return op.UpgradeMaster(ctx, obj, cluster)
+}
+
+func (r *registryOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	return op.UpgradeNodePool(ctx, obj, cluster, nodePoolID)
+}
+
+func (r *registryOperator) GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return "", err
+	}
+	wo, ok := op.(WorkloadOperator)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return wo.GetWorkloadVersion(ctx, obj, cluster, workload)
+}
+
+func (r *registryOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	wo, ok := op.(WorkloadOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return wo.UpgradeWorkload(ctx, obj, cluster, workload)
+}
+
+func (r *registryOperator) GetCapabilities(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	co, ok := op.(CapabilitiesOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return co.GetCapabilities(ctx, obj, cluster)
+}
+
+func (r *registryOperator) GetAvailableVersions(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, channel string) ([]string, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	vo, ok := op.(VersionOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return vo.GetAvailableVersions(ctx, obj, cluster, channel)
+}
+
+func (r *registryOperator) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	op, err := r.operator(obj, obj.Status.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	wo, ok := op.(WatchOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return wo.WatchOperation(ctx, obj)
+}
+
+func (r *registryOperator) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	op, err := r.operator(obj, obj.Status.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	do, ok := op.(OperationDetailOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return do.GetOperationDetail(ctx, obj)
+}
+
+func (r *registryOperator) ListOperations(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error) {
+	op, err := r.operator(obj, cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+	lo, ok := op.(OperationListOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return lo.ListOperations(ctx, obj, cluster)
+}
+
+// GetFleetStatus calls the Operator of each provider once with its clusters, e.g. the gRPC plugin servers
+// and the HTTP plugin servers of a fleet in the middle of migration.
+// The clusters whose provider doesn't implement FleetStatusOperator are left out of the result.
+func (r *registryOperator) GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error) {
+	var ret []FleetClusterStatus
+	supported := false
+	for _, group := range groupClusters(clusters, func(c opsv1.Cluster) interface{} { return providerOf(obj, c.ID) }) {
+		op, err := r.registry.Get(providerOf(obj, group[0].ID))
+		if err != nil {
+			return nil, err
+		}
+		fo, ok := op.(FleetStatusOperator)
+		if !ok {
+			continue
+		}
+		statuses, err := fo.GetFleetStatus(ctx, obj, group)
+		if errors.Is(err, ErrNotSupported) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		supported = true
+		ret = append(ret, statuses...)
+	}
+	if !supported {
+		return nil, ErrNotSupported
+	}
+	return ret, nil
+}
@@ -0,0 +1,130 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/fakeplugin"
+	"testing"
+)
+
+func newFakeFleetOperator(clusterID, version string) Operator {
+	return NewInProcessOperator(fakeplugin.NewServer(fakeplugin.Config{
+		Clusters: []fakeplugin.ClusterConfig{{ID: clusterID, Version: version, NodePools: []string{"pool-1"}}},
+	}))
+}
+
+func TestRegistry(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r := NewRegistry()
+	g.Expect(r.Register(ProviderGRPC, NewPluginOperator(nil))).Should(Succeed())
+	g.Expect(r.Register("gke", newFakeFleetOperator("gke-cluster", "1.16.13-gke.404"))).Should(Succeed())
+	g.Expect(r.Register("gke", newFakeFleetOperator("gke-cluster", "1.16.13-gke.404"))).ShouldNot(Succeed())
+	g.Expect(r.Providers()).Should(Equal([]string{"gke", ProviderGRPC}))
+
+	op, err := r.Get("")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(op).Should(BeAssignableToTypeOf(&pluginOperator{}))
+	_, err = r.Get("eks")
+	g.Expect(err).Should(HaveOccurred())
+}
+
+func TestRegistryOperator(t *testing.T) {
+	r := NewRegistry()
+	g := NewGomegaWithT(t)
+	g.Expect(r.Register("gke", newFakeFleetOperator("gke-cluster", "1.16.13-gke.404"))).Should(Succeed())
+	g.Expect(r.Register("eks", onlyOperator{newFakeFleetOperator("eks-cluster", "1.18.9-eks-d1db3c")})).Should(Succeed())
+	g.Expect(r.Register(ProviderHTTP, newFakeFleetOperator("http-cluster", "1.17.14-gke.1600"))).Should(Succeed())
+	obj := v1.ClusterVersion{
+		Spec: v1.ClusterVersionSpec{
+			OpsEndpoint: v1.OpsEndpoint{Provider: "gke"},
+			Clusters: []v1.Cluster{
+				{ID: "gke-cluster"},
+				{ID: "eks-cluster", OpsEndpoint: &v1.OpsEndpoint{Provider: "eks"}},
+				{ID: "aks-cluster", OpsEndpoint: &v1.OpsEndpoint{Provider: "aks"}},
+				{ID: "http-cluster", OpsEndpoint: &v1.OpsEndpoint{Protocol: v1.OpsProtocolHTTP}},
+			},
+		},
+	}
+	operator := NewRegistryOperator(r)
+
+	testCases := []struct {
+		name            string
+		cluster         v1.Cluster
+		expectedVersion string
+		expectedHasErr  bool
+	}{
+		{
+			name:            "ret the version from the default provider",
+			cluster:         obj.Spec.Clusters[0],
+			expectedVersion: "1.16.13-gke.404",
+		},
+		{
+			name:            "ret the version from the provider of the cluster",
+			cluster:         obj.Spec.Clusters[1],
+			expectedVersion: "1.18.9-eks-d1db3c",
+		},
+		{
+			name:            "ret the version from the http provider for the http protocol",
+			cluster:         obj.Spec.Clusters[3],
+			expectedVersion: "1.17.14-gke.1600",
+		},
+		{
+			name:           "ret error for the provider which isn't registered",
+			cluster:        obj.Spec.Clusters[2],
+			expectedHasErr: true,
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			cv, err := operator.GetClusterVersion(context.Background(), obj, c.cluster)
+			if c.expectedHasErr {
+				g.Expect(err).Should(HaveOccurred())
+				return
+			}
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(cv.Master.Version).Should(Equal(c.expectedVersion))
+		})
+	}
+
+	t.Run("ret not supported for optional operations", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		_, err := operator.(WorkloadOperator).GetWorkloadVersion(context.Background(), obj, obj.Spec.Clusters[1], v1.Workload{Name: "istio"})
+		g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+	})
+}
+
+func TestRegistryOperator_GetFleetStatus(t *testing.T) {
+	g := NewGomegaWithT(t)
+	r := NewRegistry()
+	gke := &fleetOperator{countingOperator: &countingOperator{Operator: newFakeFleetOperator("gke-cluster", "1.16.13-gke.404")}}
+	g.Expect(r.Register("gke", gke)).Should(Succeed())
+	g.Expect(r.Register("fake", newFakeFleetOperator("fake-cluster", "1.17.14-gke.1600"))).Should(Succeed())
+	g.Expect(r.Register("eks", onlyOperator{newFakeFleetOperator("eks-cluster", "1.18.9-eks-d1db3c")})).Should(Succeed())
+	obj := v1.ClusterVersion{
+		Spec: v1.ClusterVersionSpec{
+			OpsEndpoint: v1.OpsEndpoint{Provider: "gke"},
+			Clusters: []v1.Cluster{
+				{ID: "gke-cluster"},
+				{ID: "eks-cluster", OpsEndpoint: &v1.OpsEndpoint{Provider: "eks"}},
+				{ID: "fake-cluster", OpsEndpoint: &v1.OpsEndpoint{Provider: "fake"}},
+			},
+		},
+	}
+	operator := NewRegistryOperator(r).(FleetStatusOperator)
+
+	// each provider is called once, and the clusters of the provider without the support are left out
+	statuses, err := operator.GetFleetStatus(context.Background(), obj, obj.Spec.Clusters)
+	g.Expect(err).ShouldNot(HaveOccurred())
+	g.Expect(statuses).Should(HaveLen(2))
+	g.Expect(statuses[0].ClusterID).Should(Equal("gke-cluster"))
+	g.Expect(statuses[0].Version.Master.Version).Should(Equal("1.16.13-gke.404"))
+	g.Expect(statuses[1].ClusterID).Should(Equal("fake-cluster"))
+	g.Expect(statuses[1].Version.Master.Version).Should(Equal("1.17.14-gke.1600"))
+	g.Expect(gke.fleetCalls).Should(Equal(1))
+
+	_, err = operator.GetFleetStatus(context.Background(), obj, obj.Spec.Clusters[1:2])
+	g.Expect(err).Should(MatchError(ErrNotSupported))
+}
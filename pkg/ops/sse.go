@@ -0,0 +1,32 @@
+package ops
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// readEvents reads the Server-Sent Events from r, and calls fn with the data of each event until fn returns false
+// or r ends. The fields other than data, e.g. event and id, are ignored.
+func readEvents(r io.Reader, fn func(data []byte) bool) error {
+	scanner := bufio.NewScanner(r)
+	var data [][]byte
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			// a blank line dispatches the event
+			if len(data) == 0 {
+				continue
+			}
+			if !fn(bytes.Join(data, []byte("\n"))) {
+				return nil
+			}
+			data = nil
+		case bytes.HasPrefix(line, []byte("data:")):
+			value := bytes.TrimPrefix(line[len("data:"):], []byte(" "))
+			data = append(data, append([]byte{}, value...))
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,211 @@
+package ops
+
+import (
+	"context"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"github.com/taisho6339/multicluster-upgrade-operator/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	attrClusterID     = label.Key("multicluster.cluster_id")
+	attrNodePoolID    = label.Key("multicluster.node_pool_id")
+	attrWorkload      = label.Key("multicluster.workload")
+	attrChannel       = label.Key("multicluster.channel")
+	attrOperationID   = label.Key("multicluster.operation_id")
+	attrOperationType = label.Key("multicluster.operation_type")
+)
+
+// tracingOperator traces every method of the wrapped Operator as a span.
+type tracingOperator struct {
+	operator Operator
+}
+
+var _ extendedOperator = &tracingOperator{}
+
+// NewTracingOperator returns the Operator which traces the given Operator.
+func NewTracingOperator(operator Operator) Operator {
+	return &tracingOperator{
+		operator: operator,
+	}
+}
+
+func startSpan(ctx context.Context, method string, attrs ...label.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "Operator."+method, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func operationAttrs(result *OperationResult) []label.KeyValue {
+	if result == nil {
+		return nil
+	}
+	return []label.KeyValue{attrOperationID.String(result.OperationID), attrOperationType.String(result.OperationType)}
+}
+
+func (t *tracingOperator) GetOperationStatus(ctx context.Context, obj opsv1.ClusterVersion) (OperationStatus, error) {
+	ctx, span := startSpan(ctx, metricsGetOperationStatus,
+		attrClusterID.String(obj.Status.ClusterID),
+		attrOperationID.String(obj.Status.OperationID),
+		attrOperationType.String(obj.Status.OperationType),
+	)
+	status, err := t.operator.GetOperationStatus(ctx, obj)
+	span.SetAttributes(label.String("multicluster.operation_status", string(status)))
+	endSpan(span, err)
+	return status, err
+}
+
+func (t *tracingOperator) GetClusterVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterVersion, error) {
+	ctx, span := startSpan(ctx, metricsGetClusterVersion, attrClusterID.String(cluster.ID))
+	cv, err := t.operator.GetClusterVersion(ctx, obj, cluster)
+	endSpan(span, err)
+	return cv, err
+}
+
+func (t *tracingOperator) GetClusterStatus(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*ClusterStatus, error) {
+	ctx, span := startSpan(ctx, metricsGetClusterStatus, attrClusterID.String(cluster.ID))
+	cs, err := t.operator.GetClusterStatus(ctx, obj, cluster)
+	endSpan(span, err)
+	return cs, err
+}
+
+func (t *tracingOperator) ServiceIn(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	ctx, span := startSpan(ctx, metricsServiceIn, attrClusterID.String(cluster.ID))
+	result, err := t.operator.ServiceIn(ctx, obj, cluster)
+	span.SetAttributes(operationAttrs(result)...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *tracingOperator) ServiceOut(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	ctx, span := startSpan(ctx, metricsServiceOut, attrClusterID.String(cluster.ID))
+	result, err := t.operator.ServiceOut(ctx, obj, cluster)
+	span.SetAttributes(operationAttrs(result)...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *tracingOperator) UpgradeMaster(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*OperationResult, error) {
+	ctx, span := startSpan(ctx, metricsUpgradeMaster, attrClusterID.String(cluster.ID))
+	result, err := t.operator.UpgradeMaster(ctx, obj, cluster)
+	span.SetAttributes(operationAttrs(result)...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *tracingOperator) UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error) {
+	ctx, span := startSpan(ctx, metricsUpgradeNodePool, attrClusterID.String(cluster.ID), attrNodePoolID.String(nodePoolID))
+	result, err := t.operator.UpgradeNodePool(ctx, obj, cluster, nodePoolID)
+	span.SetAttributes(operationAttrs(result)...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *tracingOperator) GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	wo, ok := t.operator.(WorkloadOperator)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsGetWorkloadVersion, attrClusterID.String(cluster.ID), attrWorkload.String(workload.Name))
+	version, err := wo.GetWorkloadVersion(ctx, obj, cluster, workload)
+	endSpan(span, err)
+	return version, err
+}
+
+func (t *tracingOperator) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	wo, ok := t.operator.(WorkloadOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsUpgradeWorkload, attrClusterID.String(cluster.ID), attrWorkload.String(workload.Name))
+	result, err := wo.UpgradeWorkload(ctx, obj, cluster, workload)
+	span.SetAttributes(operationAttrs(result)...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (t *tracingOperator) GetCapabilities(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error) {
+	co, ok := t.operator.(CapabilitiesOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsGetCapabilities, attrClusterID.String(cluster.ID))
+	caps, err := co.GetCapabilities(ctx, obj, cluster)
+	endSpan(span, err)
+	return caps, err
+}
+
+func (t *tracingOperator) GetAvailableVersions(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, channel string) ([]string, error) {
+	vo, ok := t.operator.(VersionOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsGetVersions, attrClusterID.String(cluster.ID), attrChannel.String(channel))
+	versions, err := vo.GetAvailableVersions(ctx, obj, cluster, channel)
+	endSpan(span, err)
+	return versions, err
+}
+
+// WatchOperation traces only opening the stream, because it lasts until the operation completes.
+func (t *tracingOperator) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	wo, ok := t.operator.(WatchOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsWatchOperation,
+		attrClusterID.String(obj.Status.ClusterID),
+		attrOperationID.String(obj.Status.OperationID),
+		attrOperationType.String(obj.Status.OperationType),
+	)
+	ch, err := wo.WatchOperation(ctx, obj)
+	endSpan(span, err)
+	return ch, err
+}
+
+func (t *tracingOperator) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	do, ok := t.operator.(OperationDetailOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsGetOperationDetail,
+		attrClusterID.String(obj.Status.ClusterID),
+		attrOperationID.String(obj.Status.OperationID),
+		attrOperationType.String(obj.Status.OperationType),
+	)
+	detail, err := do.GetOperationDetail(ctx, obj)
+	if detail != nil {
+		span.SetAttributes(label.String("multicluster.operation_status", string(detail.Status)))
+	}
+	endSpan(span, err)
+	return detail, err
+}
+
+func (t *tracingOperator) ListOperations(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error) {
+	lo, ok := t.operator.(OperationListOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsListOperations, attrClusterID.String(cluster.ID))
+	operations, err := lo.ListOperations(ctx, obj, cluster)
+	endSpan(span, err)
+	return operations, err
+}
+
+func (t *tracingOperator) GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error) {
+	fo, ok := t.operator.(FleetStatusOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	ctx, span := startSpan(ctx, metricsGetFleetStatus, label.Int("multicluster.cluster_count", len(clusters)))
+	statuses, err := fo.GetFleetStatus(ctx, obj, clusters)
+	endSpan(span, err)
+	return statuses, err
+}
@@ -0,0 +1,46 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/gomega"
+	v1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	"testing"
+)
+
+// onlyOperator hides the optional interfaces of the wrapped Operator.
+type onlyOperator struct {
+	Operator
+}
+
+func TestTracingOperator_WorkloadNotSupported(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	op, ok := NewTracingOperator(onlyOperator{NewPluginOperator(nil)}).(WorkloadOperator)
+	g.Expect(ok).Should(BeTrue())
+
+	_, err := op.GetWorkloadVersion(context.Background(), *obj, obj.Spec.Clusters[0], v1.Workload{Name: "istio"})
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+	_, err = op.UpgradeWorkload(context.Background(), *obj, obj.Spec.Clusters[0], v1.Workload{Name: "istio", Version: "1.8.1"})
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+}
+
+func TestTracingOperator_CapabilitiesNotSupported(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	op, ok := NewTracingOperator(onlyOperator{NewPluginOperator(nil)}).(CapabilitiesOperator)
+	g.Expect(ok).Should(BeTrue())
+
+	_, err := op.GetCapabilities(context.Background(), *obj, obj.Spec.Clusters[0])
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+}
+
+func TestTracingOperator_VersionsNotSupported(t *testing.T) {
+	g := NewGomegaWithT(t)
+	obj := makeClusterVersionResource()
+	op, ok := NewTracingOperator(onlyOperator{NewPluginOperator(nil)}).(VersionOperator)
+	g.Expect(ok).Should(BeTrue())
+
+	_, err := op.GetAvailableVersions(context.Background(), *obj, obj.Spec.Clusters[0], "REGULAR")
+	g.Expect(errors.Is(err, ErrNotSupported)).Should(BeTrue())
+}
@@ -2,9 +2,13 @@ package ops
 
 import (
 	"context"
+	"errors"
 	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
 )
 
+// ErrNotSupported is returned when the Operator doesn't support the requested operation.
+var ErrNotSupported = errors.New("operation is not supported by the operator")
+
 // Operator requests for the operation server to perform the cluster operations.
 type Operator interface {
 	// GetOperationStatus gets operations status.
@@ -23,6 +27,111 @@ type Operator interface {
 	UpgradeNodePool(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, nodePoolID string) (*OperationResult, error)
 }
 
+// WorkloadOperator is implemented by the Operator which can upgrade core workloads in the clusters.
+type WorkloadOperator interface {
+	// GetWorkloadVersion gets the current version of the workload in the cluster.
+	GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error)
+	// UpgradeWorkload requests the operation for upgrading the workload in the cluster.
+	UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error)
+}
+
+// CapabilitiesOperator is implemented by the Operator which reports what it supports.
+// The controller calls it on every reconcile, so the implementations should cache the capabilities.
+type CapabilitiesOperator interface {
+	// GetCapabilities gets the capabilities of the operator for the cluster.
+	GetCapabilities(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error)
+}
+
+// VersionOperator is implemented by the Operator which can list the versions the clusters can be upgraded to.
+type VersionOperator interface {
+	// GetAvailableVersions gets the valid versions of the cluster. An empty channel means all valid versions.
+	GetAvailableVersions(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, channel string) ([]string, error)
+}
+
+// WatchOperator is implemented by the Operator which can stream the status of the running operation.
+// The controller polls GetOperationStatus instead if it returns ErrNotSupported.
+type WatchOperator interface {
+	// WatchOperation streams the status of the operation in obj.Status whenever it changes.
+	// The channel is closed when the operation completes, the stream ends, or ctx is done.
+	WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error)
+}
+
+// OperationDetailOperator is implemented by the Operator which can report the progress of the running operation.
+// The controller calls GetOperationStatus instead if it returns ErrNotSupported.
+type OperationDetailOperator interface {
+	// GetOperationDetail gets the status of the operation with its progress.
+	GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error)
+}
+
+// OperationListOperator is implemented by the Operator which can list the operations of the cluster in the provider,
+// so the controller can adopt the running operation of a step instead of starting another one, e.g. the operation
+// whose ID has been lost or which has been started in the console. The OperationType of each operation should be
+// the step kind, e.g. "UPGRADE_MASTER", and the Target should be the node pool ID or the workload name to be adopted.
+type OperationListOperator interface {
+	// ListOperations lists the operations of the cluster which are running in the provider.
+	ListOperations(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error)
+}
+
+// FleetStatusOperator is implemented by the Operator which can read the versions and the statuses of many clusters
+// in one call. The controller reads them cluster by cluster instead if it returns ErrNotSupported.
+type FleetStatusOperator interface {
+	// GetFleetStatus gets the versions and the statuses of the clusters.
+	// The result may lack some of them, e.g. the ones whose provider doesn't support it.
+	GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error)
+}
+
+// Capabilities shows the operations and the versions which the operator supports.
+type Capabilities struct {
+	// ProtocolVersion is the version of the protocol which the operator speaks.
+	ProtocolVersion string
+	// UpgradeNodePool is false if the node pools are upgraded together with the master.
+	UpgradeNodePool bool
+	// ServiceOut is false if the cluster can't be removed from the routing.
+	ServiceOut bool
+	// Rollback is true if the operator can roll back the upgrade.
+	Rollback bool
+	// AvailableVersions are the versions which the cluster can be upgraded to. Empty means any version.
+	AvailableVersions []string
+	// Workloads is true if the operator can upgrade the core workloads which aren't Helm releases.
+	Workloads bool
+}
+
+// DefaultCapabilities returns the capabilities of the Operator which doesn't implement CapabilitiesOperator.
+// The protocol v1 doesn't have the workload operations.
+func DefaultCapabilities() *Capabilities {
+	return &Capabilities{
+		ProtocolVersion: ProtocolVersionV1,
+		UpgradeNodePool: true,
+		ServiceOut:      true,
+	}
+}
+
+// IsVersionAvailable returns true if the cluster can be upgraded to the version.
+func (c *Capabilities) IsVersionAvailable(version string) bool {
+	if len(c.AvailableVersions) == 0 {
+		return true
+	}
+	for _, v := range c.AvailableVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// ProtocolVersionV1 is the version of the plugin protocol which has only the Cluster service.
+	ProtocolVersionV1 = "v1"
+	// ProtocolVersionV2 is the version of the plugin protocol which has the ClusterExtension service too.
+	ProtocolVersionV2 = "v2"
+)
+
+// ProtocolVersions are the versions of the plugin protocol which the controller speaks.
+var ProtocolVersions = []string{ProtocolVersionV1, ProtocolVersionV2}
+
+// ErrUnsupportedProtocol is returned when the plugin server chooses the protocol version which the controller doesn't speak.
+var ErrUnsupportedProtocol = errors.New("protocol version of the plugin server is not supported")
+
 // ClusterStatus shows cluster status.
 type ClusterStatus struct {
 	Type      ClusterStatusType
@@ -33,6 +142,35 @@ type ClusterStatus struct {
 type OperationResult struct {
 	OperationID   string
 	OperationType string
+	// Message is the human-readable status of the started operation. It's optional.
+	Message string
+}
+
+// Operation shows the operation of the cluster in the provider.
+type Operation struct {
+	OperationID   string
+	OperationType string
+	// Target is the node pool ID of UPGRADE_NODE_POOL and the workload name of UPGRADE_WORKLOAD. It's empty for the others.
+	Target string
+	Status OperationStatus
+}
+
+// FleetClusterStatus shows the versions and the status of a cluster in the fleet.
+type FleetClusterStatus struct {
+	ClusterID string
+	Version   ClusterVersion
+	Status    ClusterStatus
+}
+
+// OperationDetail shows the status of the operation with its progress.
+type OperationDetail struct {
+	Status OperationStatus
+	// Progress is the percentage from 0 to 100, or nil if it's unknown.
+	Progress *int32
+	// Message is the human-readable status of the operation.
+	Message string
+	// Error is the error detail of the provider when the operation has failed.
+	Error string
 }
 
 // OperationStatus shows operation status.
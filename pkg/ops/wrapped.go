@@ -0,0 +1,90 @@
+package ops
+
+import (
+	"context"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+)
+
+// extendedOperator is the Operator with all optional interfaces, which the Operators wrapping another Operator implement.
+type extendedOperator interface {
+	Operator
+	WorkloadOperator
+	CapabilitiesOperator
+	VersionOperator
+	WatchOperator
+	OperationDetailOperator
+	OperationListOperator
+	FleetStatusOperator
+}
+
+// wrapped is embedded by the Operators wrapping another Operator. It forwards every call, including the ones of
+// the optional interfaces, to the wrapped Operator, so the wrapping Operator only overrides the methods it changes.
+type wrapped struct {
+	Operator
+}
+
+var _ extendedOperator = wrapped{}
+
+func (w wrapped) GetWorkloadVersion(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (string, error) {
+	wo, ok := w.Operator.(WorkloadOperator)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return wo.GetWorkloadVersion(ctx, obj, cluster, workload)
+}
+
+func (w wrapped) UpgradeWorkload(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, workload opsv1.Workload) (*OperationResult, error) {
+	wo, ok := w.Operator.(WorkloadOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return wo.UpgradeWorkload(ctx, obj, cluster, workload)
+}
+
+func (w wrapped) GetCapabilities(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (*Capabilities, error) {
+	co, ok := w.Operator.(CapabilitiesOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return co.GetCapabilities(ctx, obj, cluster)
+}
+
+func (w wrapped) GetAvailableVersions(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster, channel string) ([]string, error) {
+	vo, ok := w.Operator.(VersionOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return vo.GetAvailableVersions(ctx, obj, cluster, channel)
+}
+
+func (w wrapped) WatchOperation(ctx context.Context, obj opsv1.ClusterVersion) (<-chan OperationStatus, error) {
+	wo, ok := w.Operator.(WatchOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return wo.WatchOperation(ctx, obj)
+}
+
+func (w wrapped) GetOperationDetail(ctx context.Context, obj opsv1.ClusterVersion) (*OperationDetail, error) {
+	do, ok := w.Operator.(OperationDetailOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return do.GetOperationDetail(ctx, obj)
+}
+
+func (w wrapped) ListOperations(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]Operation, error) {
+	lo, ok := w.Operator.(OperationListOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return lo.ListOperations(ctx, obj, cluster)
+}
+
+func (w wrapped) GetFleetStatus(ctx context.Context, obj opsv1.ClusterVersion, clusters []opsv1.Cluster) ([]FleetClusterStatus, error) {
+	fo, ok := w.Operator.(FleetStatusOperator)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return fo.GetFleetStatus(ctx, obj, clusters)
+}
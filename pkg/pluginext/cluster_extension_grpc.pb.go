@@ -0,0 +1,398 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pluginext
+
+import (
+	context "context"
+	plugin "github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// ClusterExtensionClient is the client API for ClusterExtension service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ClusterExtensionClient interface {
+	// GetCapabilities negotiates the protocol version and gets what the plugin server supports for the given cluster
+	GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*Capabilities, error)
+	// GetAvailableVersions gets the versions which the given cluster can be upgraded to
+	GetAvailableVersions(ctx context.Context, in *GetAvailableVersionsRequest, opts ...grpc.CallOption) (*AvailableVersions, error)
+	// GetWorkloadVersion gets the current version of the core workload in the given cluster
+	GetWorkloadVersion(ctx context.Context, in *GetWorkloadVersionRequest, opts ...grpc.CallOption) (*WorkloadVersion, error)
+	// UpgradeWorkload requests the operation for upgrading the core workload in the given cluster
+	UpgradeWorkload(ctx context.Context, in *WorkloadVersion, opts ...grpc.CallOption) (*plugin.Operation, error)
+	// WatchOperation streams the status of the operation, first the current one and then whenever it changes.
+	// The stream ends when the operation is DONE or FAILED, or the operation is UNKNOWN
+	WatchOperation(ctx context.Context, in *plugin.GetOperationStatusRequest, opts ...grpc.CallOption) (ClusterExtension_WatchOperationClient, error)
+	// GetOperationDetail gets the status of the operation with its progress
+	GetOperationDetail(ctx context.Context, in *plugin.GetOperationStatusRequest, opts ...grpc.CallOption) (*OperationDetail, error)
+	// ListOperations lists the operations of the given cluster which are running in the provider
+	ListOperations(ctx context.Context, in *ListOperationsRequest, opts ...grpc.CallOption) (*Operations, error)
+	// GetFleetStatus gets the versions and the statuses of the given clusters in one call.
+	// The clusters which the plugin server doesn't know may be left out
+	GetFleetStatus(ctx context.Context, in *GetFleetStatusRequest, opts ...grpc.CallOption) (*FleetStatus, error)
+}
+
+type clusterExtensionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClusterExtensionClient(cc grpc.ClientConnInterface) ClusterExtensionClient {
+	return &clusterExtensionClient{cc}
+}
+
+func (c *clusterExtensionClient) GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*Capabilities, error) {
+	out := new(Capabilities)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/GetCapabilities", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterExtensionClient) GetAvailableVersions(ctx context.Context, in *GetAvailableVersionsRequest, opts ...grpc.CallOption) (*AvailableVersions, error) {
+	out := new(AvailableVersions)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/GetAvailableVersions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterExtensionClient) GetWorkloadVersion(ctx context.Context, in *GetWorkloadVersionRequest, opts ...grpc.CallOption) (*WorkloadVersion, error) {
+	out := new(WorkloadVersion)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/GetWorkloadVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterExtensionClient) UpgradeWorkload(ctx context.Context, in *WorkloadVersion, opts ...grpc.CallOption) (*plugin.Operation, error) {
+	out := new(plugin.Operation)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/UpgradeWorkload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterExtensionClient) WatchOperation(ctx context.Context, in *plugin.GetOperationStatusRequest, opts ...grpc.CallOption) (ClusterExtension_WatchOperationClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ClusterExtension_serviceDesc.Streams[0], "/plugin.ClusterExtension/WatchOperation", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &clusterExtensionWatchOperationClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ClusterExtension_WatchOperationClient interface {
+	Recv() (*plugin.OperationStatus, error)
+	grpc.ClientStream
+}
+
+type clusterExtensionWatchOperationClient struct {
+	grpc.ClientStream
+}
+
+func (x *clusterExtensionWatchOperationClient) Recv() (*plugin.OperationStatus, error) {
+	m := new(plugin.OperationStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *clusterExtensionClient) GetOperationDetail(ctx context.Context, in *plugin.GetOperationStatusRequest, opts ...grpc.CallOption) (*OperationDetail, error) {
+	out := new(OperationDetail)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/GetOperationDetail", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterExtensionClient) ListOperations(ctx context.Context, in *ListOperationsRequest, opts ...grpc.CallOption) (*Operations, error) {
+	out := new(Operations)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/ListOperations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *clusterExtensionClient) GetFleetStatus(ctx context.Context, in *GetFleetStatusRequest, opts ...grpc.CallOption) (*FleetStatus, error) {
+	out := new(FleetStatus)
+	err := c.cc.Invoke(ctx, "/plugin.ClusterExtension/GetFleetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClusterExtensionServer is the server API for ClusterExtension service.
+// All implementations must embed UnimplementedClusterExtensionServer
+// for forward compatibility
+type ClusterExtensionServer interface {
+	// GetCapabilities negotiates the protocol version and gets what the plugin server supports for the given cluster
+	GetCapabilities(context.Context, *GetCapabilitiesRequest) (*Capabilities, error)
+	// GetAvailableVersions gets the versions which the given cluster can be upgraded to
+	GetAvailableVersions(context.Context, *GetAvailableVersionsRequest) (*AvailableVersions, error)
+	// GetWorkloadVersion gets the current version of the core workload in the given cluster
+	GetWorkloadVersion(context.Context, *GetWorkloadVersionRequest) (*WorkloadVersion, error)
+	// UpgradeWorkload requests the operation for upgrading the core workload in the given cluster
+	UpgradeWorkload(context.Context, *WorkloadVersion) (*plugin.Operation, error)
+	// WatchOperation streams the status of the operation, first the current one and then whenever it changes.
+	// The stream ends when the operation is DONE or FAILED, or the operation is UNKNOWN
+	WatchOperation(*plugin.GetOperationStatusRequest, ClusterExtension_WatchOperationServer) error
+	// GetOperationDetail gets the status of the operation with its progress
+	GetOperationDetail(context.Context, *plugin.GetOperationStatusRequest) (*OperationDetail, error)
+	// ListOperations lists the operations of the given cluster which are running in the provider
+	ListOperations(context.Context, *ListOperationsRequest) (*Operations, error)
+	// GetFleetStatus gets the versions and the statuses of the given clusters in one call.
+	// The clusters which the plugin server doesn't know may be left out
+	GetFleetStatus(context.Context, *GetFleetStatusRequest) (*FleetStatus, error)
+	mustEmbedUnimplementedClusterExtensionServer()
+}
+
+// UnimplementedClusterExtensionServer must be embedded to have forward compatible implementations.
+type UnimplementedClusterExtensionServer struct {
+}
+
+func (UnimplementedClusterExtensionServer) GetCapabilities(context.Context, *GetCapabilitiesRequest) (*Capabilities, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCapabilities not implemented")
+}
+func (UnimplementedClusterExtensionServer) GetAvailableVersions(context.Context, *GetAvailableVersionsRequest) (*AvailableVersions, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAvailableVersions not implemented")
+}
+func (UnimplementedClusterExtensionServer) GetWorkloadVersion(context.Context, *GetWorkloadVersionRequest) (*WorkloadVersion, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkloadVersion not implemented")
+}
+func (UnimplementedClusterExtensionServer) UpgradeWorkload(context.Context, *WorkloadVersion) (*plugin.Operation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpgradeWorkload not implemented")
+}
+func (UnimplementedClusterExtensionServer) WatchOperation(*plugin.GetOperationStatusRequest, ClusterExtension_WatchOperationServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOperation not implemented")
+}
+func (UnimplementedClusterExtensionServer) GetOperationDetail(context.Context, *plugin.GetOperationStatusRequest) (*OperationDetail, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOperationDetail not implemented")
+}
+func (UnimplementedClusterExtensionServer) ListOperations(context.Context, *ListOperationsRequest) (*Operations, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListOperations not implemented")
+}
+func (UnimplementedClusterExtensionServer) GetFleetStatus(context.Context, *GetFleetStatusRequest) (*FleetStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFleetStatus not implemented")
+}
+func (UnimplementedClusterExtensionServer) mustEmbedUnimplementedClusterExtensionServer() {}
+
+// UnsafeClusterExtensionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ClusterExtensionServer will
+// result in compilation errors.
+type UnsafeClusterExtensionServer interface {
+	mustEmbedUnimplementedClusterExtensionServer()
+}
+
+func RegisterClusterExtensionServer(s grpc.ServiceRegistrar, srv ClusterExtensionServer) {
+	s.RegisterService(&_ClusterExtension_serviceDesc, srv)
+}
+
+func _ClusterExtension_GetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).GetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/GetCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).GetCapabilities(ctx, req.(*GetCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterExtension_GetAvailableVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAvailableVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).GetAvailableVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/GetAvailableVersions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).GetAvailableVersions(ctx, req.(*GetAvailableVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterExtension_GetWorkloadVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWorkloadVersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).GetWorkloadVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/GetWorkloadVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).GetWorkloadVersion(ctx, req.(*GetWorkloadVersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterExtension_UpgradeWorkload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkloadVersion)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).UpgradeWorkload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/UpgradeWorkload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).UpgradeWorkload(ctx, req.(*WorkloadVersion))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterExtension_WatchOperation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(plugin.GetOperationStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClusterExtensionServer).WatchOperation(m, &clusterExtensionWatchOperationServer{stream})
+}
+
+type ClusterExtension_WatchOperationServer interface {
+	Send(*plugin.OperationStatus) error
+	grpc.ServerStream
+}
+
+type clusterExtensionWatchOperationServer struct {
+	grpc.ServerStream
+}
+
+func (x *clusterExtensionWatchOperationServer) Send(m *plugin.OperationStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ClusterExtension_GetOperationDetail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(plugin.GetOperationStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).GetOperationDetail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/GetOperationDetail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).GetOperationDetail(ctx, req.(*plugin.GetOperationStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterExtension_ListOperations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListOperationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).ListOperations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/ListOperations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).ListOperations(ctx, req.(*ListOperationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClusterExtension_GetFleetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFleetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClusterExtensionServer).GetFleetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/plugin.ClusterExtension/GetFleetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClusterExtensionServer).GetFleetStatus(ctx, req.(*GetFleetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ClusterExtension_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "plugin.ClusterExtension",
+	HandlerType: (*ClusterExtensionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCapabilities",
+			Handler:    _ClusterExtension_GetCapabilities_Handler,
+		},
+		{
+			MethodName: "GetAvailableVersions",
+			Handler:    _ClusterExtension_GetAvailableVersions_Handler,
+		},
+		{
+			MethodName: "GetWorkloadVersion",
+			Handler:    _ClusterExtension_GetWorkloadVersion_Handler,
+		},
+		{
+			MethodName: "UpgradeWorkload",
+			Handler:    _ClusterExtension_UpgradeWorkload_Handler,
+		},
+		{
+			MethodName: "GetOperationDetail",
+			Handler:    _ClusterExtension_GetOperationDetail_Handler,
+		},
+		{
+			MethodName: "ListOperations",
+			Handler:    _ClusterExtension_ListOperations_Handler,
+		},
+		{
+			MethodName: "GetFleetStatus",
+			Handler:    _ClusterExtension_GetFleetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOperation",
+			Handler:       _ClusterExtension_WatchOperation_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "plugin/cluster_extension.proto",
+}
@@ -0,0 +1,593 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/taisho6339/multicluster-upgrade-operator/pkg/pluginext (interfaces: ClusterExtensionClient,ClusterExtensionServer,ClusterExtension_WatchOperationClient,ClusterExtension_WatchOperationServer)
+
+// Package pluginext is a generated GoMock package.
+package pluginext
+
+import (
+	context "context"
+	gomock "github.com/golang/mock/gomock"
+	plugin "github.com/taisho6339/multicluster-upgrade-operator-proto/go/plugin"
+	grpc "google.golang.org/grpc"
+	metadata "google.golang.org/grpc/metadata"
+	reflect "reflect"
+)
+
+// MockClusterExtensionClient is a mock of ClusterExtensionClient interface
+type MockClusterExtensionClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterExtensionClientMockRecorder
+}
+
+// MockClusterExtensionClientMockRecorder is the mock recorder for MockClusterExtensionClient
+type MockClusterExtensionClientMockRecorder struct {
+	mock *MockClusterExtensionClient
+}
+
+// NewMockClusterExtensionClient creates a new mock instance
+func NewMockClusterExtensionClient(ctrl *gomock.Controller) *MockClusterExtensionClient {
+	mock := &MockClusterExtensionClient{ctrl: ctrl}
+	mock.recorder = &MockClusterExtensionClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClusterExtensionClient) EXPECT() *MockClusterExtensionClientMockRecorder {
+	return m.recorder
+}
+
+// GetAvailableVersions mocks base method
+func (m *MockClusterExtensionClient) GetAvailableVersions(arg0 context.Context, arg1 *GetAvailableVersionsRequest, arg2 ...grpc.CallOption) (*AvailableVersions, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAvailableVersions", varargs...)
+	ret0, _ := ret[0].(*AvailableVersions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAvailableVersions indicates an expected call of GetAvailableVersions
+func (mr *MockClusterExtensionClientMockRecorder) GetAvailableVersions(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAvailableVersions", reflect.TypeOf((*MockClusterExtensionClient)(nil).GetAvailableVersions), varargs...)
+}
+
+// GetCapabilities mocks base method
+func (m *MockClusterExtensionClient) GetCapabilities(arg0 context.Context, arg1 *GetCapabilitiesRequest, arg2 ...grpc.CallOption) (*Capabilities, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetCapabilities", varargs...)
+	ret0, _ := ret[0].(*Capabilities)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCapabilities indicates an expected call of GetCapabilities
+func (mr *MockClusterExtensionClientMockRecorder) GetCapabilities(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCapabilities", reflect.TypeOf((*MockClusterExtensionClient)(nil).GetCapabilities), varargs...)
+}
+
+// GetFleetStatus mocks base method
+func (m *MockClusterExtensionClient) GetFleetStatus(arg0 context.Context, arg1 *GetFleetStatusRequest, arg2 ...grpc.CallOption) (*FleetStatus, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFleetStatus", varargs...)
+	ret0, _ := ret[0].(*FleetStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFleetStatus indicates an expected call of GetFleetStatus
+func (mr *MockClusterExtensionClientMockRecorder) GetFleetStatus(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFleetStatus", reflect.TypeOf((*MockClusterExtensionClient)(nil).GetFleetStatus), varargs...)
+}
+
+// GetOperationDetail mocks base method
+func (m *MockClusterExtensionClient) GetOperationDetail(arg0 context.Context, arg1 *plugin.GetOperationStatusRequest, arg2 ...grpc.CallOption) (*OperationDetail, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetOperationDetail", varargs...)
+	ret0, _ := ret[0].(*OperationDetail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOperationDetail indicates an expected call of GetOperationDetail
+func (mr *MockClusterExtensionClientMockRecorder) GetOperationDetail(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOperationDetail", reflect.TypeOf((*MockClusterExtensionClient)(nil).GetOperationDetail), varargs...)
+}
+
+// GetWorkloadVersion mocks base method
+func (m *MockClusterExtensionClient) GetWorkloadVersion(arg0 context.Context, arg1 *GetWorkloadVersionRequest, arg2 ...grpc.CallOption) (*WorkloadVersion, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetWorkloadVersion", varargs...)
+	ret0, _ := ret[0].(*WorkloadVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkloadVersion indicates an expected call of GetWorkloadVersion
+func (mr *MockClusterExtensionClientMockRecorder) GetWorkloadVersion(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkloadVersion", reflect.TypeOf((*MockClusterExtensionClient)(nil).GetWorkloadVersion), varargs...)
+}
+
+// ListOperations mocks base method
+func (m *MockClusterExtensionClient) ListOperations(arg0 context.Context, arg1 *ListOperationsRequest, arg2 ...grpc.CallOption) (*Operations, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListOperations", varargs...)
+	ret0, _ := ret[0].(*Operations)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOperations indicates an expected call of ListOperations
+func (mr *MockClusterExtensionClientMockRecorder) ListOperations(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOperations", reflect.TypeOf((*MockClusterExtensionClient)(nil).ListOperations), varargs...)
+}
+
+// UpgradeWorkload mocks base method
+func (m *MockClusterExtensionClient) UpgradeWorkload(arg0 context.Context, arg1 *WorkloadVersion, arg2 ...grpc.CallOption) (*plugin.Operation, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpgradeWorkload", varargs...)
+	ret0, _ := ret[0].(*plugin.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpgradeWorkload indicates an expected call of UpgradeWorkload
+func (mr *MockClusterExtensionClientMockRecorder) UpgradeWorkload(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpgradeWorkload", reflect.TypeOf((*MockClusterExtensionClient)(nil).UpgradeWorkload), varargs...)
+}
+
+// WatchOperation mocks base method
+func (m *MockClusterExtensionClient) WatchOperation(arg0 context.Context, arg1 *plugin.GetOperationStatusRequest, arg2 ...grpc.CallOption) (ClusterExtension_WatchOperationClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WatchOperation", varargs...)
+	ret0, _ := ret[0].(ClusterExtension_WatchOperationClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchOperation indicates an expected call of WatchOperation
+func (mr *MockClusterExtensionClientMockRecorder) WatchOperation(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchOperation", reflect.TypeOf((*MockClusterExtensionClient)(nil).WatchOperation), varargs...)
+}
+
+// MockClusterExtensionServer is a mock of ClusterExtensionServer interface
+type MockClusterExtensionServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterExtensionServerMockRecorder
+}
+
+// MockClusterExtensionServerMockRecorder is the mock recorder for MockClusterExtensionServer
+type MockClusterExtensionServerMockRecorder struct {
+	mock *MockClusterExtensionServer
+}
+
+// NewMockClusterExtensionServer creates a new mock instance
+func NewMockClusterExtensionServer(ctrl *gomock.Controller) *MockClusterExtensionServer {
+	mock := &MockClusterExtensionServer{ctrl: ctrl}
+	mock.recorder = &MockClusterExtensionServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClusterExtensionServer) EXPECT() *MockClusterExtensionServerMockRecorder {
+	return m.recorder
+}
+
+// GetAvailableVersions mocks base method
+func (m *MockClusterExtensionServer) GetAvailableVersions(arg0 context.Context, arg1 *GetAvailableVersionsRequest) (*AvailableVersions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAvailableVersions", arg0, arg1)
+	ret0, _ := ret[0].(*AvailableVersions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAvailableVersions indicates an expected call of GetAvailableVersions
+func (mr *MockClusterExtensionServerMockRecorder) GetAvailableVersions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAvailableVersions", reflect.TypeOf((*MockClusterExtensionServer)(nil).GetAvailableVersions), arg0, arg1)
+}
+
+// GetCapabilities mocks base method
+func (m *MockClusterExtensionServer) GetCapabilities(arg0 context.Context, arg1 *GetCapabilitiesRequest) (*Capabilities, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCapabilities", arg0, arg1)
+	ret0, _ := ret[0].(*Capabilities)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCapabilities indicates an expected call of GetCapabilities
+func (mr *MockClusterExtensionServerMockRecorder) GetCapabilities(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCapabilities", reflect.TypeOf((*MockClusterExtensionServer)(nil).GetCapabilities), arg0, arg1)
+}
+
+// GetFleetStatus mocks base method
+func (m *MockClusterExtensionServer) GetFleetStatus(arg0 context.Context, arg1 *GetFleetStatusRequest) (*FleetStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFleetStatus", arg0, arg1)
+	ret0, _ := ret[0].(*FleetStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFleetStatus indicates an expected call of GetFleetStatus
+func (mr *MockClusterExtensionServerMockRecorder) GetFleetStatus(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFleetStatus", reflect.TypeOf((*MockClusterExtensionServer)(nil).GetFleetStatus), arg0, arg1)
+}
+
+// GetOperationDetail mocks base method
+func (m *MockClusterExtensionServer) GetOperationDetail(arg0 context.Context, arg1 *plugin.GetOperationStatusRequest) (*OperationDetail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOperationDetail", arg0, arg1)
+	ret0, _ := ret[0].(*OperationDetail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOperationDetail indicates an expected call of GetOperationDetail
+func (mr *MockClusterExtensionServerMockRecorder) GetOperationDetail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOperationDetail", reflect.TypeOf((*MockClusterExtensionServer)(nil).GetOperationDetail), arg0, arg1)
+}
+
+// GetWorkloadVersion mocks base method
+func (m *MockClusterExtensionServer) GetWorkloadVersion(arg0 context.Context, arg1 *GetWorkloadVersionRequest) (*WorkloadVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkloadVersion", arg0, arg1)
+	ret0, _ := ret[0].(*WorkloadVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkloadVersion indicates an expected call of GetWorkloadVersion
+func (mr *MockClusterExtensionServerMockRecorder) GetWorkloadVersion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkloadVersion", reflect.TypeOf((*MockClusterExtensionServer)(nil).GetWorkloadVersion), arg0, arg1)
+}
+
+// ListOperations mocks base method
+func (m *MockClusterExtensionServer) ListOperations(arg0 context.Context, arg1 *ListOperationsRequest) (*Operations, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOperations", arg0, arg1)
+	ret0, _ := ret[0].(*Operations)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOperations indicates an expected call of ListOperations
+func (mr *MockClusterExtensionServerMockRecorder) ListOperations(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOperations", reflect.TypeOf((*MockClusterExtensionServer)(nil).ListOperations), arg0, arg1)
+}
+
+// UpgradeWorkload mocks base method
+func (m *MockClusterExtensionServer) UpgradeWorkload(arg0 context.Context, arg1 *WorkloadVersion) (*plugin.Operation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpgradeWorkload", arg0, arg1)
+	ret0, _ := ret[0].(*plugin.Operation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpgradeWorkload indicates an expected call of UpgradeWorkload
+func (mr *MockClusterExtensionServerMockRecorder) UpgradeWorkload(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpgradeWorkload", reflect.TypeOf((*MockClusterExtensionServer)(nil).UpgradeWorkload), arg0, arg1)
+}
+
+// WatchOperation mocks base method
+func (m *MockClusterExtensionServer) WatchOperation(arg0 *plugin.GetOperationStatusRequest, arg1 ClusterExtension_WatchOperationServer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchOperation", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WatchOperation indicates an expected call of WatchOperation
+func (mr *MockClusterExtensionServerMockRecorder) WatchOperation(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchOperation", reflect.TypeOf((*MockClusterExtensionServer)(nil).WatchOperation), arg0, arg1)
+}
+
+// mustEmbedUnimplementedClusterExtensionServer mocks base method
+func (m *MockClusterExtensionServer) mustEmbedUnimplementedClusterExtensionServer() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "mustEmbedUnimplementedClusterExtensionServer")
+}
+
+// mustEmbedUnimplementedClusterExtensionServer indicates an expected call of mustEmbedUnimplementedClusterExtensionServer
+func (mr *MockClusterExtensionServerMockRecorder) mustEmbedUnimplementedClusterExtensionServer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "mustEmbedUnimplementedClusterExtensionServer", reflect.TypeOf((*MockClusterExtensionServer)(nil).mustEmbedUnimplementedClusterExtensionServer))
+}
+
+// MockClusterExtension_WatchOperationClient is a mock of ClusterExtension_WatchOperationClient interface
+type MockClusterExtension_WatchOperationClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterExtension_WatchOperationClientMockRecorder
+}
+
+// MockClusterExtension_WatchOperationClientMockRecorder is the mock recorder for MockClusterExtension_WatchOperationClient
+type MockClusterExtension_WatchOperationClientMockRecorder struct {
+	mock *MockClusterExtension_WatchOperationClient
+}
+
+// NewMockClusterExtension_WatchOperationClient creates a new mock instance
+func NewMockClusterExtension_WatchOperationClient(ctrl *gomock.Controller) *MockClusterExtension_WatchOperationClient {
+	mock := &MockClusterExtension_WatchOperationClient{ctrl: ctrl}
+	mock.recorder = &MockClusterExtension_WatchOperationClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClusterExtension_WatchOperationClient) EXPECT() *MockClusterExtension_WatchOperationClientMockRecorder {
+	return m.recorder
+}
+
+// CloseSend mocks base method
+func (m *MockClusterExtension_WatchOperationClient) CloseSend() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseSend")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseSend indicates an expected call of CloseSend
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) CloseSend() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseSend", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).CloseSend))
+}
+
+// Context mocks base method
+func (m *MockClusterExtension_WatchOperationClient) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).Context))
+}
+
+// Header mocks base method
+func (m *MockClusterExtension_WatchOperationClient) Header() (metadata.MD, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Header")
+	ret0, _ := ret[0].(metadata.MD)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Header indicates an expected call of Header
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) Header() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Header", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).Header))
+}
+
+// Recv mocks base method
+func (m *MockClusterExtension_WatchOperationClient) Recv() (*plugin.OperationStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Recv")
+	ret0, _ := ret[0].(*plugin.OperationStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Recv indicates an expected call of Recv
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) Recv() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Recv", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).Recv))
+}
+
+// RecvMsg mocks base method
+func (m *MockClusterExtension_WatchOperationClient) RecvMsg(arg0 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecvMsg", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) RecvMsg(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).RecvMsg), arg0)
+}
+
+// SendMsg mocks base method
+func (m *MockClusterExtension_WatchOperationClient) SendMsg(arg0 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMsg", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) SendMsg(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).SendMsg), arg0)
+}
+
+// Trailer mocks base method
+func (m *MockClusterExtension_WatchOperationClient) Trailer() metadata.MD {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trailer")
+	ret0, _ := ret[0].(metadata.MD)
+	return ret0
+}
+
+// Trailer indicates an expected call of Trailer
+func (mr *MockClusterExtension_WatchOperationClientMockRecorder) Trailer() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trailer", reflect.TypeOf((*MockClusterExtension_WatchOperationClient)(nil).Trailer))
+}
+
+// MockClusterExtension_WatchOperationServer is a mock of ClusterExtension_WatchOperationServer interface
+type MockClusterExtension_WatchOperationServer struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterExtension_WatchOperationServerMockRecorder
+}
+
+// MockClusterExtension_WatchOperationServerMockRecorder is the mock recorder for MockClusterExtension_WatchOperationServer
+type MockClusterExtension_WatchOperationServerMockRecorder struct {
+	mock *MockClusterExtension_WatchOperationServer
+}
+
+// NewMockClusterExtension_WatchOperationServer creates a new mock instance
+func NewMockClusterExtension_WatchOperationServer(ctrl *gomock.Controller) *MockClusterExtension_WatchOperationServer {
+	mock := &MockClusterExtension_WatchOperationServer{ctrl: ctrl}
+	mock.recorder = &MockClusterExtension_WatchOperationServerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClusterExtension_WatchOperationServer) EXPECT() *MockClusterExtension_WatchOperationServerMockRecorder {
+	return m.recorder
+}
+
+// Context mocks base method
+func (m *MockClusterExtension_WatchOperationServer) Context() context.Context {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Context")
+	ret0, _ := ret[0].(context.Context)
+	return ret0
+}
+
+// Context indicates an expected call of Context
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) Context() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).Context))
+}
+
+// RecvMsg mocks base method
+func (m *MockClusterExtension_WatchOperationServer) RecvMsg(arg0 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecvMsg", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecvMsg indicates an expected call of RecvMsg
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) RecvMsg(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecvMsg", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).RecvMsg), arg0)
+}
+
+// Send mocks base method
+func (m *MockClusterExtension_WatchOperationServer) Send(arg0 *plugin.OperationStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) Send(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).Send), arg0)
+}
+
+// SendHeader mocks base method
+func (m *MockClusterExtension_WatchOperationServer) SendHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendHeader indicates an expected call of SendHeader
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) SendHeader(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendHeader", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).SendHeader), arg0)
+}
+
+// SendMsg mocks base method
+func (m *MockClusterExtension_WatchOperationServer) SendMsg(arg0 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMsg", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMsg indicates an expected call of SendMsg
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) SendMsg(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMsg", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).SendMsg), arg0)
+}
+
+// SetHeader mocks base method
+func (m *MockClusterExtension_WatchOperationServer) SetHeader(arg0 metadata.MD) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHeader", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetHeader indicates an expected call of SetHeader
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) SetHeader(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHeader", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).SetHeader), arg0)
+}
+
+// SetTrailer mocks base method
+func (m *MockClusterExtension_WatchOperationServer) SetTrailer(arg0 metadata.MD) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTrailer", arg0)
+}
+
+// SetTrailer indicates an expected call of SetTrailer
+func (mr *MockClusterExtension_WatchOperationServerMockRecorder) SetTrailer(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTrailer", reflect.TypeOf((*MockClusterExtension_WatchOperationServer)(nil).SetTrailer), arg0)
+}
@@ -0,0 +1,159 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+// Kubeconfig reads the kubeconfig of the cluster from the Secret referred by the cluster.
+// The Secret must be in the same namespace as the ClusterVersion.
+// The kubeconfig is rejected unless its credentials are inline, because exec and auth-provider would run a command
+// in the controller, and the file paths would make the controller send its own files, e.g. its service account token.
+func Kubeconfig(ctx context.Context, reader client.Reader, obj opsv1.ClusterVersion, cluster opsv1.Cluster) ([]byte, error) {
+	ref := cluster.KubeconfigSecretRef
+	if ref == nil {
+		return nil, fmt.Errorf("cluster %s doesn't have kubeconfigSecretRef", cluster.ID)
+	}
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: obj.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, err
+	}
+	kubeconfig, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s doesn't have key %s", ref.Name, ref.Key)
+	}
+	raw, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s has invalid kubeconfig: %w", ref.Name, err)
+	}
+	if err := validateKubeconfig(raw); err != nil {
+		return nil, fmt.Errorf("the kubeconfig in secret %s isn't allowed: %w", ref.Name, err)
+	}
+	return kubeconfig, nil
+}
+
+// validateKubeconfig returns an error if any cluster or user of the kubeconfig has other than the inline data
+// and the token.
+func validateKubeconfig(raw *clientcmdapi.Config) error {
+	for name, cluster := range raw.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster %s uses certificate-authority. use certificate-authority-data instead", name)
+		}
+	}
+	for name, user := range raw.AuthInfos {
+		var fields []string
+		for field, used := range map[string]bool{
+			"client-certificate": user.ClientCertificate != "",
+			"client-key":         user.ClientKey != "",
+			"tokenFile":          user.TokenFile != "",
+			"as":                 user.Impersonate != "",
+			"as-groups":          len(user.ImpersonateGroups) > 0,
+			"as-user-extra":      len(user.ImpersonateUserExtra) > 0,
+			"username":           user.Username != "",
+			"password":           user.Password != "",
+			"auth-provider":      user.AuthProvider != nil,
+			"exec":               user.Exec != nil,
+		} {
+			if used {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			sort.Strings(fields)
+			return fmt.Errorf("user %s uses %s. only client-certificate-data, client-key-data and token are allowed", name, strings.Join(fields, ", "))
+		}
+	}
+	return nil
+}
+
+// ClientsetFunc returns the clientset of the cluster.
+type ClientsetFunc func(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (kubernetes.Interface, error)
+
+// NewClientsetFunc returns the ClientsetFunc which connects to the cluster with the kubeconfig read by the reader.
+func NewClientsetFunc(reader client.Reader) ClientsetFunc {
+	return func(ctx context.Context, obj opsv1.ClusterVersion, cluster opsv1.Cluster) (kubernetes.Interface, error) {
+		kubeconfig, err := Kubeconfig(ctx, reader, obj, cluster)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+		if err != nil {
+			return nil, err
+		}
+		return kubernetes.NewForConfig(cfg)
+	}
+}
+
+// kubeconfigGetter loads the clients of the cluster from the kubeconfig.
+type kubeconfigGetter struct {
+	ctx       context.Context
+	config    clientcmd.ClientConfig
+	namespace string
+}
+
+var _ genericclioptions.RESTClientGetter = &kubeconfigGetter{}
+
+// NewRESTClientGetter returns the RESTClientGetter for the kubeconfig whose default namespace is the given one.
+// The clients refuse new requests after ctx is done, so the long-running calls, e.g. waiting for the resources, end.
+func NewRESTClientGetter(ctx context.Context, kubeconfig []byte, namespace string) (genericclioptions.RESTClientGetter, error) {
+	raw, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	overrides := &clientcmd.ConfigOverrides{}
+	overrides.Context.Namespace = namespace
+	return &kubeconfigGetter{
+		ctx:       ctx,
+		config:    clientcmd.NewDefaultClientConfig(*raw, overrides),
+		namespace: namespace,
+	}, nil
+}
+
+func (k *kubeconfigGetter) ToRESTConfig() (*rest.Config, error) {
+	cfg, err := k.config.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.WrapTransport = transport.Wrappers(cfg.WrapTransport, transport.ContextCanceller(k.ctx, context.Canceled))
+	return cfg, nil
+}
+
+func (k *kubeconfigGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	cfg, err := k.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(dc), nil
+}
+
+func (k *kubeconfigGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := k.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(dc)
+	return restmapper.NewShortcutExpander(mapper, dc), nil
+}
+
+func (k *kubeconfigGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return k.config
+}
@@ -0,0 +1,165 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: target
+  cluster:
+    server: https://target.example.com
+contexts:
+- name: target
+  context:
+    cluster: target
+    user: admin
+current-context: target
+users:
+- name: admin
+  user:
+    token: dummy
+`
+
+// testUser replaces the user of testKubeconfig.
+func testUser(user string) string {
+	return strings.Replace(testKubeconfig, "    token: dummy\n", user, 1)
+}
+
+// testCluster replaces the cluster of testKubeconfig.
+func testCluster(cluster string) string {
+	return strings.Replace(testKubeconfig, "    server: https://target.example.com\n", cluster, 1)
+}
+
+func TestKubeconfig(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster-1-kubeconfig"},
+		Data: map[string][]byte{
+			"value": []byte(testKubeconfig),
+			"exec": []byte(testUser(`    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: /bin/sh
+`)),
+			"auth-provider": []byte(testUser(`    auth-provider:
+      name: gcp
+      config:
+        cmd-path: /bin/sh
+`)),
+			"inline": []byte(testUser(`    client-certificate-data: ZHVtbXk=
+    client-key-data: ZHVtbXk=
+`)),
+		},
+	}
+	reader := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, secret)
+	obj := opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sample"}}
+	ref := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name}, Key: key}
+	}
+	testCases := []struct {
+		name        string
+		cluster     opsv1.Cluster
+		expectedErr bool
+	}{
+		{
+			name:    "read kubeconfig",
+			cluster: opsv1.Cluster{ID: "cluster-1", KubeconfigSecretRef: ref("value")},
+		},
+		{
+			name:        "ret error without secret ref",
+			cluster:     opsv1.Cluster{ID: "cluster-1"},
+			expectedErr: true,
+		},
+		{
+			name:        "ret error without key",
+			cluster:     opsv1.Cluster{ID: "cluster-1", KubeconfigSecretRef: ref("missing")},
+			expectedErr: true,
+		},
+		{
+			name:        "ret error for the user running a command",
+			cluster:     opsv1.Cluster{ID: "cluster-1", KubeconfigSecretRef: ref("exec")},
+			expectedErr: true,
+		},
+		{
+			name:        "ret error for the user of an auth provider",
+			cluster:     opsv1.Cluster{ID: "cluster-1", KubeconfigSecretRef: ref("auth-provider")},
+			expectedErr: true,
+		},
+		{
+			name:    "read kubeconfig with the inline client certificate",
+			cluster: opsv1.Cluster{ID: "cluster-1", KubeconfigSecretRef: ref("inline")},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			kubeconfig, err := Kubeconfig(context.Background(), reader, obj, c.cluster)
+			if c.expectedErr {
+				g.Expect(err).Should(HaveOccurred())
+				return
+			}
+			g.Expect(err).ShouldNot(HaveOccurred())
+
+			getter, err := NewRESTClientGetter(context.Background(), kubeconfig, "istio-system")
+			g.Expect(err).ShouldNot(HaveOccurred())
+			cfg, err := getter.ToRESTConfig()
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(cfg.Host).Should(Equal("https://target.example.com"))
+			namespace, _, err := getter.ToRawKubeConfigLoader().Namespace()
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(namespace).Should(Equal("istio-system"))
+		})
+	}
+}
+
+func TestKubeconfig_NotInline(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster-1-kubeconfig"},
+		Data: map[string][]byte{
+			"tokenFile":             []byte(testUser("    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token\n")),
+			"client-certificate":    []byte(testUser("    client-certificate: /etc/ssl/client.crt\n")),
+			"client-key":            []byte(testUser("    token: dummy\n    client-key: /etc/ssl/client.key\n")),
+			"as":                    []byte(testUser("    token: dummy\n    as: system:admin\n")),
+			"as-groups":             []byte(testUser("    token: dummy\n    as-groups: [\"system:masters\"]\n")),
+			"as-user-extra":         []byte(testUser("    token: dummy\n    as-user-extra: {scopes: [\"all\"]}\n")),
+			"username":              []byte(testUser("    username: admin\n")),
+			"password":              []byte(testUser("    password: dummy\n")),
+			"certificate-authority": []byte(testCluster("    server: https://target.example.com\n    certificate-authority: /var/run/secrets/kubernetes.io/serviceaccount/ca.crt\n")),
+		},
+	}
+	reader := fake.NewFakeClientWithScheme(clientgoscheme.Scheme, secret)
+	obj := opsv1.ClusterVersion{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sample"}}
+	for field := range secret.Data {
+		t.Run(field, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			ref := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name}, Key: field}
+			_, err := Kubeconfig(context.Background(), reader, obj, opsv1.Cluster{ID: "cluster-1", KubeconfigSecretRef: ref})
+			g.Expect(err).Should(MatchError(ContainSubstring("uses "+field)))
+		})
+	}
+}
+
+func TestRESTClientGetter_Canceled(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	getter, err := NewRESTClientGetter(ctx, []byte(testKubeconfig), "istio-system")
+	g.Expect(err).ShouldNot(HaveOccurred())
+	cfg, err := getter.ToRESTConfig()
+	g.Expect(err).ShouldNot(HaveOccurred())
+	clientset, err := kubernetes.NewForConfig(cfg)
+	g.Expect(err).ShouldNot(HaveOccurred())
+
+	cancel()
+	_, err = clientset.CoreV1().Namespaces().Get(context.Background(), "istio-system", metav1.GetOptions{})
+	g.Expect(errors.Is(err, context.Canceled)).Should(BeTrue())
+}
@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type spanContextIDsKey struct{}
+
+// contextWithSpanContextIDs makes the root span started with the context have the given IDs.
+func contextWithSpanContextIDs(ctx context.Context, sc trace.SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextIDsKey{}, sc)
+}
+
+// rolloutIDGenerator generates random IDs except for the rollout span,
+// whose IDs have been decided when the rollout started.
+type rolloutIDGenerator struct{}
+
+var _ sdktrace.IDGenerator = &rolloutIDGenerator{}
+
+func newRolloutIDGenerator() sdktrace.IDGenerator {
+	return &rolloutIDGenerator{}
+}
+
+func (g *rolloutIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if sc, ok := ctx.Value(spanContextIDsKey{}).(trace.SpanContext); ok && sc.IsValid() {
+		return sc.TraceID, sc.SpanID
+	}
+	tid := trace.TraceID{}
+	_, _ = crand.Read(tid[:])
+	return tid, g.NewSpanID(ctx, tid)
+}
+
+func (g *rolloutIDGenerator) NewSpanID(_ context.Context, _ trace.TraceID) trace.SpanID {
+	sid := trace.SpanID{}
+	_, _ = crand.Read(sid[:])
+	return sid
+}
@@ -0,0 +1,124 @@
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const (
+	// TracerName is the name of the tracer of this controller.
+	TracerName = "github.com/taisho6339/multicluster-upgrade-operator"
+	// ServiceName is the service name which spans are reported as.
+	ServiceName = "multicluster-upgrade-operator"
+
+	rolloutSpanName = "Rollout"
+)
+
+// rootSampler decides whether a rollout is sampled. Ratio based sampling is deterministic by the trace id,
+// so the rollout span and its descendants get the same decision.
+var rootSampler = sdktrace.AlwaysSample()
+
+// Tracer returns the tracer of this controller from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Setup configures the global tracer provider to export spans to the OTLP collector.
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, endpoint string, insecure bool, sampleRatio float64) (func(context.Context) error, error) {
+	opts := []otlp.ExporterOption{otlp.WithAddress(endpoint)}
+	if insecure {
+		opts = append(opts, otlp.WithInsecure())
+	}
+	exp, err := otlp.NewExporter(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter. err: %w", err)
+	}
+	rootSampler = sdktrace.TraceIDRatioBased(sampleRatio)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(rootSampler)}),
+		sdktrace.WithResource(sdkresource.NewWithAttributes(semconv.ServiceNameKey.String(ServiceName))),
+		sdktrace.WithIDGenerator(newRolloutIDGenerator()),
+		sdktrace.WithBatcher(exp),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return func(ctx context.Context) error {
+		if err := tp.Shutdown(ctx); err != nil {
+			return err
+		}
+		return exp.Shutdown(ctx)
+	}, nil
+}
+
+// NewRolloutTraceParent returns a new W3C traceparent which identifies the span of a rollout.
+// A rollout lasts over many reconciliations and even controller restarts,
+// so the span is kept in the resource as a traceparent and reported when the rollout ends.
+func NewRolloutTraceParent() string {
+	var sc trace.SpanContext
+	_, _ = crand.Read(sc.TraceID[:])
+	_, _ = crand.Read(sc.SpanID[:])
+	if rootSampler.ShouldSample(sdktrace.SamplingParameters{TraceID: sc.TraceID, Name: rolloutSpanName}).Decision == sdktrace.RecordAndSample {
+		sc.TraceFlags = trace.FlagsSampled
+	}
+	return formatTraceParent(sc)
+}
+
+// ContextWithRollout returns the context whose parent span is the rollout span.
+// The context is returned as it is if the traceparent is empty or invalid.
+func ContextWithRollout(ctx context.Context, traceParent string) context.Context {
+	sc, ok := parseTraceParent(traceParent)
+	if !ok {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// EndRollout reports the rollout span identified by the traceparent which has started at the given time.
+func EndRollout(ctx context.Context, traceParent string, start time.Time, attrs ...label.KeyValue) {
+	sc, ok := parseTraceParent(traceParent)
+	if !ok {
+		return
+	}
+	ctx = contextWithSpanContextIDs(ctx, sc)
+	_, span := Tracer().Start(ctx, rolloutSpanName,
+		trace.WithNewRoot(),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+	)
+	span.End()
+}
+
+func formatTraceParent(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+func parseTraceParent(traceParent string) (trace.SpanContext, bool) {
+	if traceParent == "" {
+		return trace.SpanContext{}, false
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), mapCarrier{"traceparent": traceParent})
+	sc := trace.RemoteSpanContextFromContext(ctx)
+	return sc, sc.IsValid()
+}
+
+// mapCarrier is the TextMapCarrier to extract the traceparent kept in the resource.
+type mapCarrier map[string]string
+
+func (c mapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c mapCarrier) Set(key string, value string) {
+	c[key] = value
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingExporter struct {
+	spans []*exporttrace.SpanData
+	lock  sync.Mutex
+}
+
+func (e *recordingExporter) ExportSpans(_ context.Context, spans []*exporttrace.SpanData) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(_ context.Context) error {
+	return nil
+}
+
+func TestRolloutSpan(t *testing.T) {
+	g := NewGomegaWithT(t)
+	exp := &recordingExporter{}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.ParentBased(sdktrace.AlwaysSample())}),
+		sdktrace.WithIDGenerator(newRolloutIDGenerator()),
+		sdktrace.WithSyncer(exp),
+	))
+	ctx := context.Background()
+	start := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	traceParent := NewRolloutTraceParent()
+	rollout, ok := parseTraceParent(traceParent)
+	g.Expect(ok).Should(BeTrue())
+	g.Expect(formatTraceParent(rollout)).Should(Equal(traceParent))
+
+	// a reconciliation during the rollout
+	_, span := Tracer().Start(ContextWithRollout(ctx, traceParent), "Reconcile")
+	span.End()
+	// the end of the rollout
+	EndRollout(ctx, traceParent, start)
+
+	g.Expect(exp.spans).Should(HaveLen(2))
+	reconcile, end := exp.spans[0], exp.spans[1]
+	g.Expect(reconcile.SpanContext.TraceID).Should(Equal(rollout.TraceID))
+	g.Expect(reconcile.ParentSpanID).Should(Equal(rollout.SpanID))
+	g.Expect(end.Name).Should(Equal(rolloutSpanName))
+	g.Expect(end.SpanContext.TraceID).Should(Equal(rollout.TraceID))
+	g.Expect(end.SpanContext.SpanID).Should(Equal(rollout.SpanID))
+	g.Expect(end.ParentSpanID.IsValid()).Should(BeFalse())
+	g.Expect(end.StartTime).Should(Equal(start))
+}
+
+func TestContextWithRollout_Invalid(t *testing.T) {
+	g := NewGomegaWithT(t)
+	ctx := context.Background()
+	for _, tp := range []string{"", "invalid", "00-00000000000000000000000000000000-0000000000000000-01"} {
+		g.Expect(trace.RemoteSpanContextFromContext(ContextWithRollout(ctx, tp)).IsValid()).Should(BeFalse())
+	}
+}
@@ -0,0 +1,150 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"strings"
+)
+
+const (
+	// ReasonVerified shows the cluster has passed the verification.
+	ReasonVerified = "Verified"
+	// ReasonNodeNotReady shows some nodes aren't Ready or not at the desired version.
+	ReasonNodeNotReady = "NodeNotReady"
+	// ReasonWorkloadNotRolledOut shows some Deployments or DaemonSets haven't been rolled out.
+	ReasonWorkloadNotRolledOut = "WorkloadNotRolledOut"
+	// ReasonPodCrashLooping shows some pods are in CrashLoopBackOff.
+	ReasonPodCrashLooping = "PodCrashLooping"
+
+	kindDeployment = "Deployment"
+	kindDaemonSet  = "DaemonSet"
+
+	waitingReasonCrashLoopBackOff = "CrashLoopBackOff"
+)
+
+// Result is the result of the verification.
+type Result struct {
+	Verified bool
+	Reason   string
+	Message  string
+}
+
+func failed(reason string, format string, args ...interface{}) *Result {
+	return &Result{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// Verify checks that all nodes are Ready at the desired kubelet version,
+// the workloads of the policy are fully rolled out and no pods in the namespaces of the policy are in CrashLoopBackOff.
+// It returns the first failure found.
+func Verify(ctx context.Context, client kubernetes.Interface, version string, policy opsv1.VerificationPolicy) (*Result, error) {
+	if r, err := verifyNodes(ctx, client, version); r != nil || err != nil {
+		return r, err
+	}
+	for _, w := range policy.Workloads {
+		if r, err := verifyWorkload(ctx, client, w); r != nil || err != nil {
+			return r, err
+		}
+	}
+	for _, ns := range policy.Namespaces {
+		if r, err := verifyPods(ctx, client, ns); r != nil || err != nil {
+			return r, err
+		}
+	}
+	return &Result{Verified: true, Reason: ReasonVerified, Message: "all checks have passed"}, nil
+}
+
+func verifyNodes(ctx context.Context, client kubernetes.Interface, version string) (*Result, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes.Items {
+		if !isNodeReady(node) {
+			return failed(ReasonNodeNotReady, "node %s isn't ready", node.Name), nil
+		}
+		if kubelet := node.Status.NodeInfo.KubeletVersion; !matchVersion(kubelet, version) {
+			return failed(ReasonNodeNotReady, "kubelet version of node %s is %s, not %s", node.Name, kubelet, version), nil
+		}
+	}
+	return nil, nil
+}
+
+func isNodeReady(node corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// matchVersion returns true if the kubelet version is the desired version or its patch, e.g. "v1.18.9-eks-d1db3c" for "1.18".
+func matchVersion(kubelet string, version string) bool {
+	kubelet = strings.TrimPrefix(kubelet, "v")
+	version = strings.TrimPrefix(version, "v")
+	return kubelet == version || strings.HasPrefix(kubelet, version+".") || strings.HasPrefix(kubelet, version+"-")
+}
+
+func verifyWorkload(ctx context.Context, client kubernetes.Interface, ref opsv1.WorkloadReference) (*Result, error) {
+	switch ref.Kind {
+	case kindDeployment:
+		d, err := client.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if !isDeploymentRolledOut(d) {
+			return failed(ReasonWorkloadNotRolledOut, "deployment %s/%s hasn't been rolled out", ref.Namespace, ref.Name), nil
+		}
+	case kindDaemonSet:
+		ds, err := client.AppsV1().DaemonSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if !isDaemonSetRolledOut(ds) {
+			return failed(ReasonWorkloadNotRolledOut, "daemonset %s/%s hasn't been rolled out", ref.Namespace, ref.Name), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown workload kind: %s", ref.Kind)
+	}
+	return nil, nil
+}
+
+func isDeploymentRolledOut(d *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	st := d.Status
+	return st.ObservedGeneration >= d.Generation &&
+		st.UpdatedReplicas == replicas &&
+		st.Replicas == replicas &&
+		st.AvailableReplicas == replicas
+}
+
+func isDaemonSetRolledOut(ds *appsv1.DaemonSet) bool {
+	st := ds.Status
+	return st.ObservedGeneration >= ds.Generation &&
+		st.UpdatedNumberScheduled == st.DesiredNumberScheduled &&
+		st.NumberAvailable == st.DesiredNumberScheduled
+}
+
+func verifyPods(ctx context.Context, client kubernetes.Interface, namespace string) (*Result, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.State.Waiting != nil && cs.State.Waiting.Reason == waitingReasonCrashLoopBackOff {
+				return failed(ReasonPodCrashLooping, "container %s of pod %s/%s is in CrashLoopBackOff", cs.Name, namespace, pod.Name), nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,116 @@
+package verify
+
+import (
+	"context"
+	. "github.com/onsi/gomega"
+	opsv1 "github.com/taisho6339/multicluster-upgrade-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func makeNode(name string, ready corev1.ConditionStatus, kubelet string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: kubelet},
+		},
+	}
+}
+
+func makeDeployment(updated int32) *appsv1.Deployment {
+	replicas := int32(2)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "istiod", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: updated, AvailableReplicas: 2},
+	}
+}
+
+func makePod(waitingReason string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "istiod-1"}}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "discovery", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: waitingReason}}},
+	}
+	return pod
+}
+
+func TestVerify(t *testing.T) {
+	policy := opsv1.VerificationPolicy{
+		Workloads:  []opsv1.WorkloadReference{{Kind: "Deployment", Namespace: "istio-system", Name: "istiod"}},
+		Namespaces: []string{"istio-system"},
+	}
+	testCases := []struct {
+		name     string
+		version  string
+		objects  []runtime.Object
+		expected *Result
+	}{
+		{
+			name:    "ret verified",
+			version: "1.16.15-gke.4301",
+			objects: []runtime.Object{
+				makeNode("node-1", corev1.ConditionTrue, "v1.16.15-gke.4301"),
+				makeDeployment(2),
+				makePod("ContainerCreating"),
+			},
+			expected: &Result{Verified: true, Reason: ReasonVerified, Message: "all checks have passed"},
+		},
+		{
+			name:    "ret verified with the patch of the version",
+			version: "1.18",
+			objects: []runtime.Object{
+				makeNode("node-1", corev1.ConditionTrue, "v1.18.9-eks-d1db3c"),
+				makeDeployment(2),
+			},
+			expected: &Result{Verified: true, Reason: ReasonVerified, Message: "all checks have passed"},
+		},
+		{
+			name:    "ret node not ready",
+			version: "1.16.15-gke.4301",
+			objects: []runtime.Object{
+				makeNode("node-1", corev1.ConditionFalse, "v1.16.15-gke.4301"),
+			},
+			expected: &Result{Reason: ReasonNodeNotReady, Message: "node node-1 isn't ready"},
+		},
+		{
+			name:    "ret node at the old version",
+			version: "1.16.15-gke.4301",
+			objects: []runtime.Object{
+				makeNode("node-1", corev1.ConditionTrue, "v1.16.15-gke.4300"),
+			},
+			expected: &Result{Reason: ReasonNodeNotReady, Message: "kubelet version of node node-1 is v1.16.15-gke.4300, not 1.16.15-gke.4301"},
+		},
+		{
+			name:    "ret workload not rolled out",
+			version: "1.16.15-gke.4301",
+			objects: []runtime.Object{
+				makeNode("node-1", corev1.ConditionTrue, "v1.16.15-gke.4301"),
+				makeDeployment(1),
+			},
+			expected: &Result{Reason: ReasonWorkloadNotRolledOut, Message: "deployment istio-system/istiod hasn't been rolled out"},
+		},
+		{
+			name:    "ret pod crash looping",
+			version: "1.16.15-gke.4301",
+			objects: []runtime.Object{
+				makeNode("node-1", corev1.ConditionTrue, "v1.16.15-gke.4301"),
+				makeDeployment(2),
+				makePod("CrashLoopBackOff"),
+			},
+			expected: &Result{Reason: ReasonPodCrashLooping, Message: "container discovery of pod istio-system/istiod-1 is in CrashLoopBackOff"},
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewGomegaWithT(t)
+			ret, err := Verify(context.Background(), fake.NewSimpleClientset(c.objects...), c.version, policy)
+			g.Expect(err).ShouldNot(HaveOccurred())
+			g.Expect(ret).Should(Equal(c.expected))
+		})
+	}
+}